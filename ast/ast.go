@@ -50,11 +50,16 @@ func (p *Program) String() string {
 	return out.String()
 }
 
-// LetStatement Are used to assign a value to an identifier.
+// LetStatement Are used to assign a value to an identifier, or to the identifiers of
+// a pattern the value is destructured with. A const statement is a LetStatement
+// whose token is const.
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
-	Value Expression
+	// Pattern is the array or hash pattern the value is destructured with, if Name
+	// is nil.
+	Pattern Pattern
+	Value   Expression
 }
 
 // TokenLiteral The literal value of the token.
@@ -65,7 +70,13 @@ func (ls *LetStatement) TokenLiteral() string {
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
-	out.WriteString(ls.TokenLiteral() + " " + ls.Name.String() + " = ")
+	out.WriteString(ls.TokenLiteral() + " ")
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
+	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
 	}
@@ -76,6 +87,20 @@ func (ls *LetStatement) String() string {
 
 func (ls *LetStatement) statementNode() {}
 
+// IsConst Reports whether the identifiers the statement binds are constants, which
+// can't be bound again in the same scope.
+func (ls *LetStatement) IsConst() bool {
+	return ls.Token.Type == token.CONST
+}
+
+// Names The identifiers the statement binds.
+func (ls *LetStatement) Names() []*Identifier {
+	if ls.Pattern != nil {
+		return Bindings(ls.Pattern)
+	}
+	return []*Identifier{ls.Name}
+}
+
 // Identifier The name a value is assigned to.
 type Identifier struct {
 	Token token.Token
@@ -135,6 +160,79 @@ func (rs *ReturnStatement) String() string {
 
 func (rs *ReturnStatement) statementNode() {}
 
+// ImportStatement Binds a module, read from the file at Path, to Name.
+type ImportStatement struct {
+	Token token.Token // the import token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+// TokenLiteral The literal value of the token.
+func (is *ImportStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *ImportStatement) String() string {
+	return is.TokenLiteral() + " \"" + is.Path.Value + "\" as " + is.Name.String() + ";"
+}
+
+func (is *ImportStatement) statementNode() {}
+
+// ExportStatement A let statement or function declaration whose bindings are
+// exported from its module.
+type ExportStatement struct {
+	Token     token.Token // the export token
+	Statement Statement   // a *LetStatement or a *FunctionDeclaration
+}
+
+// Names Returns the identifiers the statement exports.
+func (es *ExportStatement) Names() []*Identifier {
+	switch stmt := es.Statement.(type) {
+	case *LetStatement:
+		return stmt.Names()
+	case *FunctionDeclaration:
+		return []*Identifier{stmt.Name}
+	}
+	return nil
+}
+
+// Declaration Returns the statement s exports if it is an export statement, or
+// s itself.
+func Declaration(s Statement) Statement {
+	if export, ok := s.(*ExportStatement); ok {
+		return export.Statement
+	}
+	return s
+}
+
+// TokenLiteral The literal value of the token.
+func (es *ExportStatement) TokenLiteral() string {
+	return es.Token.Literal
+}
+
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+func (es *ExportStatement) statementNode() {}
+
+// ThrowStatement Throws a value, unwinding to the nearest catch clause.
+type ThrowStatement struct {
+	Token token.Token // the throw token
+	Value Expression
+}
+
+// TokenLiteral The literal value of the token.
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+func (ts *ThrowStatement) statementNode() {}
+
 // ExpressionStatement An expression is something that returns a value.
 type ExpressionStatement struct {
 	Token      token.Token // first token of the expression
@@ -245,6 +343,42 @@ func (b *Boolean) String() string {
 
 func (b *Boolean) expressionNode() {}
 
+// NullLiteral The null value.
+type NullLiteral struct {
+	Token token.Token
+}
+
+// TokenLiteral The literal value of the token.
+func (nl *NullLiteral) TokenLiteral() string {
+	return nl.Token.Literal
+}
+
+func (nl *NullLiteral) String() string {
+	return nl.Token.Literal
+}
+
+func (nl *NullLiteral) expressionNode() {}
+
+// TernaryExpression An expression that is one of two, depending on a condition, like
+// an if expression.
+type TernaryExpression struct {
+	Token       token.Token // the ? token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+// TokenLiteral The literal value of the token.
+func (te *TernaryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+
+func (te *TernaryExpression) String() string {
+	return "(" + te.Condition.String() + " ? " + te.Consequence.String() + " : " + te.Alternative.String() + ")"
+}
+
+func (te *TernaryExpression) expressionNode() {}
+
 // IfExpression An if expression.
 type IfExpression struct {
 	Token       token.Token // the 'if' token
@@ -273,6 +407,87 @@ func (ie *IfExpression) String() string {
 
 func (ie *IfExpression) expressionNode() {}
 
+// MatchExpression Matches Value against the pattern of each arm in turn, and
+// evaluates to the body of the first arm that matches.
+type MatchExpression struct {
+	Token token.Token // the 'match' token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+// TokenLiteral The literal value of the token.
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MatchExpression) String() string {
+	var arms []string
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	return "match (" + me.Value.String() + ") { " + strings.Join(arms, ", ") + " }"
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+// MatchArm An arm of a match expression. It applies if the value matches Pattern
+// and Guard, if there is one, is truthy.
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression
+	Body    *BlockStatement
+}
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if " + ma.Guard.String())
+	}
+	out.WriteString(" => " + ma.Body.String())
+
+	return out.String()
+}
+
+// TryExpression A try expression. Catch, which binds the caught exception to
+// Parameter if it is set, and Finally are optional, but not both.
+type TryExpression struct {
+	Token     token.Token // the 'try' token
+	Block     *BlockStatement
+	Parameter *Identifier
+	Catch     *BlockStatement
+	Finally   *BlockStatement
+}
+
+// TokenLiteral The literal value of the token.
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try { " + te.Block.String() + " }")
+
+	if te.Catch != nil {
+		out.WriteString(" catch ")
+		if te.Parameter != nil {
+			out.WriteString("(" + te.Parameter.String() + ") ")
+		}
+		out.WriteString("{ " + te.Catch.String() + " }")
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally { " + te.Finally.String() + " }")
+	}
+
+	return out.String()
+}
+
+func (te *TryExpression) expressionNode() {}
+
 // BlockStatement A block of code.
 type BlockStatement struct {
 	Token      token.Token // the { token
@@ -298,9 +513,16 @@ func (bs *BlockStatement) statementNode() {}
 
 // FunctionLiteral A function literal.
 type FunctionLiteral struct {
-	Token      token.Token // The 'fn' token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token token.Token // The 'fn' token
+	// Parameters are identifiers, or array and hash patterns the arguments are
+	// destructured with.
+	Parameters []Pattern
+	// Defaults are the default values of the parameters, nil for those without
+	// one. It is either empty or as long as Parameters.
+	Defaults []Expression
+	// Rest is the parameter the remaining arguments are passed in, if any.
+	Rest *Identifier
+	Body *BlockStatement
 }
 
 // TokenLiteral The literal value of the token.
@@ -310,15 +532,10 @@ func (fl *FunctionLiteral) TokenLiteral() string {
 
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
-	var params []string
-
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(FormatParameters(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(") { ")
 	out.WriteString(fl.Body.String())
 	out.WriteString(" }")
@@ -328,6 +545,32 @@ func (fl *FunctionLiteral) String() string {
 
 func (fl *FunctionLiteral) expressionNode() {}
 
+// Default The default value of the parameter at index i, or nil if it has none.
+func (fl *FunctionLiteral) Default(i int) Expression {
+	if i < len(fl.Defaults) {
+		return fl.Defaults[i]
+	}
+	return nil
+}
+
+// FormatParameters Formats parameters as they are written in a function literal.
+func FormatParameters(params []Pattern, defaults []Expression, rest *Identifier) string {
+	var out []string
+
+	for i, p := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			out = append(out, p.String()+" = "+defaults[i].String())
+			continue
+		}
+		out = append(out, p.String())
+	}
+	if rest != nil {
+		out = append(out, "..."+rest.String())
+	}
+
+	return strings.Join(out, ", ")
+}
+
 // CallExpression A call expression.
 type CallExpression struct {
 	Token     token.Token // the '(' token
@@ -358,6 +601,71 @@ func (ce *CallExpression) String() string {
 
 func (ce *CallExpression) expressionNode() {}
 
+// FunctionDeclaration Declares a named function. Declarations are hoisted: the
+// function is bound before the other statements of its block run.
+type FunctionDeclaration struct {
+	Token    token.Token // The 'fn' token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+// TokenLiteral The literal value of the token.
+func (fd *FunctionDeclaration) TokenLiteral() string {
+	return fd.Token.Literal
+}
+
+func (fd *FunctionDeclaration) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fd.TokenLiteral() + " " + fd.Name.String())
+	out.WriteString("(")
+	out.WriteString(FormatParameters(fd.Function.Parameters, fd.Function.Defaults, fd.Function.Rest))
+	out.WriteString(") { ")
+	out.WriteString(fd.Function.Body.String())
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+func (fd *FunctionDeclaration) statementNode() {}
+
+// SpreadExpression An argument of a call that passes the elements of an array as
+// arguments.
+type SpreadExpression struct {
+	Token token.Token // the ... token
+	Value Expression
+}
+
+// TokenLiteral The literal value of the token.
+func (se *SpreadExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
+func (se *SpreadExpression) expressionNode() {}
+
+// NamedArgument An argument of a call that is passed to the parameter of the same
+// name.
+type NamedArgument struct {
+	Token token.Token // the name's token
+	Name  *Identifier
+	Value Expression
+}
+
+// TokenLiteral The literal value of the token.
+func (na *NamedArgument) TokenLiteral() string {
+	return na.Token.Literal
+}
+
+func (na *NamedArgument) String() string {
+	return na.Name.String() + ": " + na.Value.String()
+}
+
+func (na *NamedArgument) expressionNode() {}
+
 // ArrayLiteral An array literal.
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
@@ -406,9 +714,12 @@ func (hl *HashLiteral) expressionNode() {}
 
 // IndexExpression An expression to get a value in an array.
 type IndexExpression struct {
-	Token token.Token // the [ token
+	Token token.Token // the [ token, or the ?. token before it
 	Left  Expression
 	Index Expression
+	// Optional is set for left?.[index], which ends the OptionalChain it is in if
+	// left is null, without evaluating index.
+	Optional bool
 }
 
 // TokenLiteral The literal value of the token.
@@ -417,7 +728,118 @@ func (ie *IndexExpression) TokenLiteral() string {
 }
 
 func (ie *IndexExpression) String() string {
+	if ie.Optional {
+		return "(" + ie.Left.String() + "?.[" + ie.Index.String() + "])"
+	}
 	return "(" + ie.Left.String() + "[" + ie.Index.String() + "])"
 }
 
 func (ie *IndexExpression) expressionNode() {}
+
+// SliceExpression An expression to get part of an array, string or range,
+// left[start:end]. Start and End are nil if they are left out.
+type SliceExpression struct {
+	Token token.Token // the [ token, or the ?. token before it
+	Left  Expression
+	Start Expression
+	End   Expression
+	// Optional is set for left?.[start:end], which ends the OptionalChain it is in
+	// if left is null, without evaluating the bounds.
+	Optional bool
+}
+
+// TokenLiteral The literal value of the token.
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(" + se.Left.String())
+	if se.Optional {
+		out.WriteString("?.")
+	}
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+func (se *SliceExpression) expressionNode() {}
+
+// RangeExpression An expression for the integers from Start to End, Step apart,
+// start..end or start..<end, optionally followed by step and the step. Step is nil
+// without one.
+type RangeExpression struct {
+	Token     token.Token // the .. or ..< token
+	Start     Expression
+	End       Expression
+	Step      Expression
+	Inclusive bool
+}
+
+// TokenLiteral The literal value of the token.
+func (re *RangeExpression) TokenLiteral() string {
+	return re.Token.Literal
+}
+
+func (re *RangeExpression) String() string {
+	out := "(" + re.Start.String() + re.Token.Literal + re.End.String()
+	if re.Step != nil {
+		out += " step " + re.Step.String()
+	}
+	return out + ")"
+}
+
+func (re *RangeExpression) expressionNode() {}
+
+// OptionalChain An expression of member, index, slice and call expressions, at least
+// one of them optional, like a?.b.c(). If the left side of an optional one is null,
+// the rest of the chain is skipped and the chain is null.
+type OptionalChain struct {
+	Token      token.Token // the first ?. token
+	Expression Expression
+}
+
+// TokenLiteral The literal value of the token.
+func (oc *OptionalChain) TokenLiteral() string {
+	return oc.Token.Literal
+}
+
+func (oc *OptionalChain) String() string {
+	return oc.Expression.String()
+}
+
+func (oc *OptionalChain) expressionNode() {}
+
+// MemberExpression An expression to get an attribute of an object.
+type MemberExpression struct {
+	Token    token.Token // the . or ?. token
+	Object   Expression
+	Property *Identifier
+	// Optional is set for object?.property, which ends the OptionalChain it is in
+	// if object is null.
+	Optional bool
+}
+
+// TokenLiteral The literal value of the token.
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MemberExpression) String() string {
+	if me.Optional {
+		return "(" + me.Object.String() + "?." + me.Property.String() + ")"
+	}
+	return "(" + me.Object.String() + "." + me.Property.String() + ")"
+}
+
+func (me *MemberExpression) expressionNode() {}
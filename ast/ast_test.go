@@ -107,12 +107,12 @@ func TestFunctionLiteral(t *testing.T) {
 				Token: token.Token{Type: token.IDENT, Literal: "fn"},
 				Expression: &FunctionLiteral{
 					Token: token.Token{Type: token.IDENT, Literal: "add"},
-					Parameters: []*Identifier{
-						{
+					Parameters: []Pattern{
+						&Identifier{
 							Token: token.Token{Type: token.IDENT, Literal: "a"},
 							Value: "a",
 						},
-						{
+						&Identifier{
 							Token: token.Token{Type: token.IDENT, Literal: "b"},
 							Value: "b",
 						},
@@ -165,10 +165,10 @@ func TestFunctionLiteral(t *testing.T) {
 		t.Fatalf("exp not *FunctionLiteral. got=%T", stmt.Expression)
 	}
 
-	if !testIdentifier(t, fl.Parameters[0], "a") {
+	if !testIdentifier(t, fl.Parameters[0].(*Identifier), "a") {
 		return
 	}
-	if !testIdentifier(t, fl.Parameters[1], "b") {
+	if !testIdentifier(t, fl.Parameters[1].(*Identifier), "b") {
 		return
 	}
 
@@ -0,0 +1,158 @@
+package ast
+
+import (
+	"strings"
+
+	"Gengo/token"
+)
+
+// Pattern A pattern that values are matched against. Matching binds the identifiers
+// in the pattern to the parts of the value they match.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// An identifier in a pattern matches any value and binds it.
+func (i *Identifier) patternNode() {}
+
+// WildcardPattern The pattern _, which matches any value without binding it.
+type WildcardPattern struct {
+	Token token.Token // the _ token
+}
+
+// TokenLiteral The literal value of the token.
+func (wp *WildcardPattern) TokenLiteral() string {
+	return wp.Token.Literal
+}
+
+func (wp *WildcardPattern) String() string {
+	return "_"
+}
+
+func (wp *WildcardPattern) patternNode() {}
+
+// LiteralPattern Matches values equal to Value, an integer, float, string or boolean
+// literal, of the same type.
+type LiteralPattern struct {
+	Token token.Token // the first token of the literal
+	Value Expression
+}
+
+// TokenLiteral The literal value of the token.
+func (lp *LiteralPattern) TokenLiteral() string {
+	return lp.Token.Literal
+}
+
+func (lp *LiteralPattern) String() string {
+	return literalString(lp.Value)
+}
+
+func (lp *LiteralPattern) patternNode() {}
+
+// ArrayPattern Matches arrays with an element matching each of Elements. Without a
+// Rest the array must have exactly as many elements; with one, the elements after
+// them are matched, as an array, against Rest.
+type ArrayPattern struct {
+	Token    token.Token // the [ token
+	Elements []Pattern
+	Rest     Pattern
+}
+
+// TokenLiteral The literal value of the token.
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+
+func (ap *ArrayPattern) String() string {
+	var elements []string
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (ap *ArrayPattern) patternNode() {}
+
+// HashPatternPair A key of a hash pattern and the pattern its value must match.
+type HashPatternPair struct {
+	Key   Expression
+	Value Pattern
+}
+
+// IsShorthand Whether the pair binds the value of a string key to an identifier of
+// the same name, which is written as just the identifier.
+func (pair HashPatternPair) IsShorthand() bool {
+	key, ok := pair.Key.(*StringLiteral)
+	if !ok {
+		return false
+	}
+	ident, ok := pair.Value.(*Identifier)
+	return ok && ident.Value == key.Value
+}
+
+// HashPattern Matches hashes that have each of the keys, an integer, string or
+// boolean literal, with a value matching its pattern. Other keys are ignored.
+type HashPattern struct {
+	Token token.Token // the { token
+	Pairs []HashPatternPair
+}
+
+// TokenLiteral The literal value of the token.
+func (hp *HashPattern) TokenLiteral() string {
+	return hp.Token.Literal
+}
+
+func (hp *HashPattern) String() string {
+	var pairs []string
+	for _, pair := range hp.Pairs {
+		if pair.IsShorthand() {
+			pairs = append(pairs, pair.Value.String())
+			continue
+		}
+		pairs = append(pairs, literalString(pair.Key)+": "+pair.Value.String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func (hp *HashPattern) patternNode() {}
+
+// Bindings The identifiers pattern binds, in the order they appear in it.
+func Bindings(pattern Pattern) []*Identifier {
+	var bindings []*Identifier
+
+	var walk func(Pattern)
+	walk = func(pattern Pattern) {
+		switch pattern := pattern.(type) {
+		case *Identifier:
+			bindings = append(bindings, pattern)
+		case *ArrayPattern:
+			for _, el := range pattern.Elements {
+				walk(el)
+			}
+			if pattern.Rest != nil {
+				walk(pattern.Rest)
+			}
+		case *HashPattern:
+			for _, pair := range pattern.Pairs {
+				walk(pair.Value)
+			}
+		}
+	}
+	walk(pattern)
+
+	return bindings
+}
+
+// literalString Quotes string literals, which would otherwise read like identifiers.
+func literalString(exp Expression) string {
+	if str, ok := exp.(*StringLiteral); ok {
+		return `"` + str.Value + `"`
+	}
+	return exp.String()
+}
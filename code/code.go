@@ -50,33 +50,162 @@ const (
 	OpGetGlobal
 	// OpSetGlobal stores the index of a global variable in the global variables array.
 	OpSetGlobal
+	// OpConstantWide is OpConstant with a 4-byte index, used once the constant pool
+	// outgrows what a 2-byte operand can address.
+	OpConstantWide
+	// OpPow tells the VM to raise the second topmost element to the power of the topmost.
+	OpPow
+	// OpArray builds an array out of the given number of topmost elements.
+	OpArray
+	// OpHash builds a hash out of the given number of topmost elements, which
+	// alternate between keys and values.
+	OpHash
+	// OpIndex pops an index and the object being indexed and pushes the element.
+	OpIndex
+	// OpCall calls the function below the given number of arguments on the stack.
+	OpCall
+	// OpReturnValue returns the topmost element from the current function.
+	OpReturnValue
+	// OpReturn returns from the current function without a value.
+	OpReturn
+	// OpGetLocal pushes the local binding with the given index.
+	OpGetLocal
+	// OpSetLocal pops the topmost element into the local binding with the given index.
+	OpSetLocal
+	// OpGetBuiltin pushes the builtin function with the given index.
+	OpGetBuiltin
+	// OpClosure wraps the compiled function constant with the given index in a closure
+	// over the given number of free variables on top of the stack.
+	OpClosure
+	// OpGetFree pushes the free variable with the given index of the current closure.
+	OpGetFree
+	// OpCurrentClosure pushes the closure that is currently executing.
+	OpCurrentClosure
+	// OpGetAttribute pops an attribute name and an object and pushes the attribute.
+	OpGetAttribute
+	// OpModule builds a module out of a path and the given number of exports on top
+	// of the stack, each a name followed by its value.
+	OpModule
+	// OpTry installs a handler that catches errors until the matching OpEndTry, by
+	// resuming at the given position with the exception on top of the stack.
+	OpTry
+	// OpEndTry removes the handler installed by the last OpTry.
+	OpEndTry
+	// OpThrow pops a value and throws it.
+	OpThrow
+	// OpMatch matches the value on top of the stack, which it leaves there, against
+	// the pattern constant with the given index. On a match it pushes the values
+	// the pattern binds followed by true, and otherwise it pushes false.
+	OpMatch
+	// OpDestructure pops a value and pushes the values the pattern constant with the
+	// given index binds in it, or fails if the value doesn't match the pattern.
+	OpDestructure
+	// OpMissing pushes whether the local binding with the given index, a parameter
+	// with a default value, was passed no argument.
+	OpMissing
+	// OpApply pops named arguments, a hash or null, an array of arrays of
+	// positional arguments and a function, and calls the function with them.
+	OpApply
+	// OpPatchFree pops a value and a closure, and sets the free variable of the
+	// closure with the given index to the value.
+	OpPatchFree
+	// OpTailCall is OpCall for a call whose value the current function returns. A
+	// closure called with it takes the place of the current frame.
+	OpTailCall
+	// OpTailApply is OpApply for a call whose value the current function returns.
+	OpTailApply
+	// OpJumpNull jumps to the instruction if the value on top of the stack is null,
+	// leaving the value on the stack either way.
+	OpJumpNull
+	// OpJumpNotNull jumps to the instruction if the value on top of the stack is not
+	// null, leaving the value on the stack either way.
+	OpJumpNotNull
+	// OpRange pops a step, or null for a step of 1, an end and a start, and pushes
+	// the range between them, which includes the end if the operand is 1.
+	OpRange
+	// OpSlice pops an end and a start, either of which may be null, and the object
+	// being sliced, and pushes the slice.
+	OpSlice
+	// OpGetGlobalWide is OpGetGlobal with a 4-byte index, used once the globals
+	// outgrow what a 2-byte operand can address.
+	OpGetGlobalWide
+	// OpSetGlobalWide is OpSetGlobal with a 4-byte index.
+	OpSetGlobalWide
+	// OpUndefined fails with an identifier not found error for the name constant with
+	// the given index, an identifier the compiler couldn't resolve.
+	OpUndefined
+)
+
+// The largest values that fit in each operand width.
+const (
+	MaxUint8  = 1<<8 - 1
+	MaxUint16 = 1<<16 - 1
+	MaxUint32 = 1<<32 - 1
 )
 
 // Definition of each opcode.
+//
+// Jump targets, and the constant indexes of instructions other than OpConstant,
+// take 4 bytes, so neither the instructions of a function nor the constant pool
+// are limited to what 2 bytes can address. OpConstant and the global instructions,
+// the most common ones, have wide variants instead.
 type Definition struct {
 	Name          string
 	OperandWidths []int
 }
 
 var definitions = map[Opcode]*Definition{
-	OpConstant:      {"OpConstant", []int{2}},
-	OpPop:           {"OpPop", []int{}},
-	OpAdd:           {"OpAdd", []int{}},
-	OpSub:           {"OpSub", []int{}},
-	OpMul:           {"OpMul", []int{}},
-	OpDiv:           {"OpDiv", []int{}},
-	OpTrue:          {"OpTrue", []int{}},
-	OpFalse:         {"OpFalse", []int{}},
-	OpEqual:         {"OpEqual", []int{}},
-	OpNotEqual:      {"OpNotEqual", []int{}},
-	OpGreaterThan:   {"OpGreaterThan", []int{}},
-	OpMinus:         {"OpMinus", []int{}},
-	OpBang:          {"OpBang", []int{}},
-	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
-	OpJump:          {"OpJump", []int{2}},
-	OpNull:          {"OpNull", []int{}},
-	OpGetGlobal:     {"OpGetGlobal", []int{2}},
-	OpSetGlobal:     {"OpSetGlobal", []int{2}},
+	OpConstant:       {"OpConstant", []int{2}},
+	OpPop:            {"OpPop", []int{}},
+	OpAdd:            {"OpAdd", []int{}},
+	OpSub:            {"OpSub", []int{}},
+	OpMul:            {"OpMul", []int{}},
+	OpDiv:            {"OpDiv", []int{}},
+	OpTrue:           {"OpTrue", []int{}},
+	OpFalse:          {"OpFalse", []int{}},
+	OpEqual:          {"OpEqual", []int{}},
+	OpNotEqual:       {"OpNotEqual", []int{}},
+	OpGreaterThan:    {"OpGreaterThan", []int{}},
+	OpMinus:          {"OpMinus", []int{}},
+	OpBang:           {"OpBang", []int{}},
+	OpJumpNotTruthy:  {"OpJumpNotTruthy", []int{4}},
+	OpJump:           {"OpJump", []int{4}},
+	OpNull:           {"OpNull", []int{}},
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpConstantWide:   {"OpConstantWide", []int{4}},
+	OpPow:            {"OpPow", []int{}},
+	OpArray:          {"OpArray", []int{2}},
+	OpHash:           {"OpHash", []int{2}},
+	OpIndex:          {"OpIndex", []int{}},
+	OpCall:           {"OpCall", []int{1}},
+	OpReturnValue:    {"OpReturnValue", []int{}},
+	OpReturn:         {"OpReturn", []int{}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{2}},
+	OpClosure:        {"OpClosure", []int{4, 1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpGetAttribute:   {"OpGetAttribute", []int{}},
+	OpModule:         {"OpModule", []int{4}},
+	OpTry:            {"OpTry", []int{4}},
+	OpEndTry:         {"OpEndTry", []int{}},
+	OpThrow:          {"OpThrow", []int{}},
+	OpMatch:          {"OpMatch", []int{4}},
+	OpDestructure:    {"OpDestructure", []int{4}},
+	OpMissing:        {"OpMissing", []int{1}},
+	OpApply:          {"OpApply", []int{}},
+	OpPatchFree:      {"OpPatchFree", []int{1}},
+	OpTailCall:       {"OpTailCall", []int{1}},
+	OpTailApply:      {"OpTailApply", []int{}},
+	OpJumpNull:       {"OpJumpNull", []int{4}},
+	OpJumpNotNull:    {"OpJumpNotNull", []int{4}},
+	OpRange:          {"OpRange", []int{1}},
+	OpSlice:          {"OpSlice", []int{}},
+	OpGetGlobalWide:  {"OpGetGlobalWide", []int{4}},
+	OpSetGlobalWide:  {"OpSetGlobalWide", []int{4}},
+	OpUndefined:      {"OpUndefined", []int{4}},
 }
 
 // Lookup returns the definition for a given opcode.
@@ -89,6 +218,10 @@ func Lookup(op byte) (*Definition, error) {
 }
 
 // Make a bytecode
+//
+// Make panics if an operand does not fit in its operand width instead of silently
+// truncating it. Callers emitting operands that aren't known to be small should
+// check them with Fits first.
 func Make(op Opcode, operands ...int) []byte {
 	def, ok := definitions[op]
 	if !ok {
@@ -106,9 +239,17 @@ func Make(op Opcode, operands ...int) []byte {
 	offset := 1
 	for i, o := range operands {
 		width := def.OperandWidths[i]
+		if !Fits(width, o) {
+			panic(fmt.Sprintf("operand %d of %s does not fit in %d bytes", o, def.Name, width))
+		}
+
 		switch width {
+		case 1:
+			instruction[offset] = byte(o)
 		case 2:
 			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 4:
+			binary.BigEndian.PutUint32(instruction[offset:], uint32(o))
 		}
 		offset += width
 	}
@@ -116,6 +257,24 @@ func Make(op Opcode, operands ...int) []byte {
 	return instruction
 }
 
+// Fits reports whether operand can be encoded in an operand of width bytes.
+func Fits(width int, operand int) bool {
+	if operand < 0 {
+		return false
+	}
+
+	switch width {
+	case 1:
+		return operand <= MaxUint8
+	case 2:
+		return operand <= MaxUint16
+	case 4:
+		return int64(operand) <= MaxUint32
+	default:
+		return false
+	}
+}
+
 func (ins Instructions) String() string {
 	var out bytes.Buffer
 
@@ -147,6 +306,8 @@ func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
 		return def.Name
 	case 1:
 		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
 	}
 
 	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
@@ -158,8 +319,12 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 
 	for i, width := range def.OperandWidths {
 		switch width {
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
 		case 2:
 			operands[i] = int(ReadUint16(ins[offset:]))
+		case 4:
+			operands[i] = int(ReadUint32(ins[offset:]))
 		}
 
 		offset += width
@@ -168,6 +333,14 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 	return operands, offset
 }
 
+func ReadUint8(ins Instructions) uint8 {
+	return ins[0]
+}
+
 func ReadUint16(ins Instructions) uint16 {
 	return binary.BigEndian.Uint16(ins)
 }
+
+func ReadUint32(ins Instructions) uint32 {
+	return binary.BigEndian.Uint32(ins)
+}
@@ -10,6 +10,9 @@ func TestMake(t *testing.T) {
 	}{
 		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
 		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpConstantWide, []int{65536}, []byte{byte(OpConstantWide), 0, 1, 0, 0}},
+		{OpJump, []int{65536}, []byte{byte(OpJump), 0, 1, 0, 0}},
+		{OpClosure, []int{65536, 255}, []byte{byte(OpClosure), 0, 1, 0, 0, 255}},
 	}
 
 	for _, tt := range tests {
@@ -32,11 +35,13 @@ func TestInstructionsString(t *testing.T) {
 		Make(OpAdd),
 		Make(OpConstant, 2),
 		Make(OpConstant, 65535),
+		Make(OpConstantWide, 4294967295),
 	}
 
 	expected := `0000 OpAdd
 0001 OpConstant 2
 0004 OpConstant 65535
+0007 OpConstantWide 4294967295
 `
 
 	concatenated := Instructions{}
@@ -56,6 +61,7 @@ func TestReadOperands(t *testing.T) {
 		bytesRead int
 	}{
 		{OpConstant, []int{65535}, 2},
+		{OpConstantWide, []int{4294967295}, 4},
 	}
 
 	for _, tt := range tests {
@@ -77,3 +83,55 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestReadOperandWidths(t *testing.T) {
+	def := &Definition{"OpTest", []int{1, 2, 4}}
+	ins := Instructions{255, 1, 0, 0, 1, 0, 0}
+
+	operands, n := ReadOperands(def, ins)
+	if n != 7 {
+		t.Fatalf("n wrong. want=%d, got=%d", 7, n)
+	}
+
+	expected := []int{255, 256, 65536}
+	for i, want := range expected {
+		if operands[i] != want {
+			t.Errorf("operand %d wrong. want=%d, got=%d", i, want, operands[i])
+		}
+	}
+}
+
+func TestFits(t *testing.T) {
+	tests := []struct {
+		width    int
+		operand  int
+		expected bool
+	}{
+		{1, 0, true},
+		{1, 255, true},
+		{1, 256, false},
+		{2, 65535, true},
+		{2, 65536, false},
+		{4, 65536, true},
+		{4, 4294967295, true},
+		{4, 4294967296, false},
+		{2, -1, false},
+		{3, 1, false},
+	}
+
+	for _, tt := range tests {
+		if got := Fits(tt.width, tt.operand); got != tt.expected {
+			t.Errorf("Fits(%d, %d) wrong. want=%t, got=%t", tt.width, tt.operand, tt.expected, got)
+		}
+	}
+}
+
+func TestMakeOverflowPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Make did not panic on an operand that does not fit")
+		}
+	}()
+
+	Make(OpConstant, 65536)
+}
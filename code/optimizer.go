@@ -0,0 +1,268 @@
+package code
+
+// instruction is a decoded instruction used by the optimizer.
+type instruction struct {
+	op       Opcode
+	operands []int
+	pos      int // position in the original Instructions
+	removed  bool
+}
+
+// Optimize runs a peephole pass over ins and returns the rewritten instructions.
+//
+// The pass removes redundancies the compiler leaves behind:
+//   - jumps whose target is an unconditional jump are threaded to the final target
+//   - `OpTrue; OpJumpNotTruthy` never jumps and is removed
+//   - `OpFalse; OpJumpNotTruthy` and `OpNull; OpJumpNotTruthy` become a single `OpJump`
+//   - unreachable instructions following an unconditional jump are removed
+//   - jumps to the instruction directly after them are removed, or replaced by
+//     an `OpPop` if they pop a condition
+//
+// Jump targets, and the catch code of OpTry, are recomputed after the rewrites. The
+// input is not modified.
+func Optimize(ins Instructions) Instructions {
+	decoded, ok := decode(ins)
+	if !ok {
+		return ins
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, rewrite := range []func([]*instruction) bool{
+			threadJumps,
+			foldConstantConditions,
+			removeUnreachable,
+			removeJumpsToNext,
+		} {
+			if rewrite(decoded) {
+				changed = true
+			}
+		}
+	}
+
+	return encode(decoded, len(ins))
+}
+
+func decode(ins Instructions) ([]*instruction, bool) {
+	var decoded []*instruction
+
+	for i := 0; i < len(ins); {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			return nil, false
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		decoded = append(decoded, &instruction{op: Opcode(ins[i]), operands: operands, pos: i})
+		i += 1 + read
+	}
+
+	return decoded, true
+}
+
+func encode(decoded []*instruction, end int) Instructions {
+	// Map every original position, including the end of the instructions,
+	// to the position of the first surviving instruction at or after it.
+	newPositions := make(map[int]int, len(decoded)+1)
+	pos := 0
+	for _, ins := range decoded {
+		if !ins.removed {
+			pos += len(Make(ins.op, ins.operands...))
+		}
+	}
+	newPositions[end] = pos
+
+	for i := len(decoded) - 1; i >= 0; i-- {
+		ins := decoded[i]
+		if !ins.removed {
+			pos -= len(Make(ins.op, ins.operands...))
+		}
+		newPositions[ins.pos] = pos
+	}
+
+	out := Instructions{}
+	for _, ins := range decoded {
+		if ins.removed {
+			continue
+		}
+
+		if hasTarget(ins.op) {
+			out = append(out, Make(ins.op, newPositions[ins.operands[0]])...)
+		} else {
+			out = append(out, Make(ins.op, ins.operands...)...)
+		}
+	}
+
+	return out
+}
+
+func isJump(op Opcode) bool {
+	return op == OpJump || op == OpJumpNotTruthy || op == OpJumpNull || op == OpJumpNotNull
+}
+
+// hasTarget reports whether the operand of op is a position execution may continue
+// at: that of a jump, or of the catch code of an OpTry.
+func hasTarget(op Opcode) bool {
+	return isJump(op) || op == OpTry
+}
+
+// live returns the instructions that have not been removed.
+func live(decoded []*instruction) []*instruction {
+	var out []*instruction
+	for _, ins := range decoded {
+		if !ins.removed {
+			out = append(out, ins)
+		}
+	}
+	return out
+}
+
+// positions maps the original position of every instruction to the first live
+// instruction at or after it. Each pass builds it once, so looking up a jump target
+// doesn't scan the instructions.
+type positions map[int]*instruction
+
+// indexPositions builds the positions of decoded.
+func indexPositions(decoded []*instruction) positions {
+	index := make(positions, len(decoded))
+	var next *instruction
+	for i := len(decoded) - 1; i >= 0; i-- {
+		if !decoded[i].removed {
+			next = decoded[i]
+		}
+		index[decoded[i].pos] = next
+	}
+	return index
+}
+
+// resolve returns the first live instruction at or after the original position pos,
+// or nil if pos is past the last live instruction. Instructions removed after the
+// index was built may still be returned.
+func (p positions) resolve(pos int) *instruction {
+	return p[pos]
+}
+
+// jumpTargets returns the set of original positions targeted by live jumps and try
+// handlers.
+func jumpTargets(decoded []*instruction, index positions) map[int]bool {
+	targets := make(map[int]bool)
+	for _, ins := range live(decoded) {
+		if hasTarget(ins.op) {
+			if target := index.resolve(ins.operands[0]); target != nil {
+				targets[target.pos] = true
+			}
+		}
+	}
+	return targets
+}
+
+func threadJumps(decoded []*instruction) bool {
+	changed := false
+	index := indexPositions(decoded)
+
+	for _, ins := range live(decoded) {
+		if !isJump(ins.op) {
+			continue
+		}
+
+		// Guard against jump cycles by never following more jumps than exist.
+		for i := 0; i < len(decoded); i++ {
+			target := index.resolve(ins.operands[0])
+			if target == nil || target == ins || target.op != OpJump {
+				break
+			}
+			if target.operands[0] == ins.operands[0] {
+				break
+			}
+			ins.operands[0] = target.operands[0]
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+func foldConstantConditions(decoded []*instruction) bool {
+	changed := false
+	instructions := live(decoded)
+	targets := jumpTargets(decoded, indexPositions(decoded))
+
+	for i := 0; i+1 < len(instructions); i++ {
+		cond, jump := instructions[i], instructions[i+1]
+		if jump.op != OpJumpNotTruthy || targets[jump.pos] {
+			continue
+		}
+
+		switch cond.op {
+		case OpTrue:
+			cond.removed = true
+			jump.removed = true
+		case OpFalse, OpNull:
+			cond.removed = true
+			jump.op = OpJump
+		default:
+			continue
+		}
+
+		changed = true
+		i++
+	}
+
+	return changed
+}
+
+func removeUnreachable(decoded []*instruction) bool {
+	changed := false
+	instructions := live(decoded)
+	targets := jumpTargets(decoded, indexPositions(decoded))
+
+	reachable := true
+	for _, ins := range instructions {
+		if targets[ins.pos] {
+			reachable = true
+		}
+
+		if !reachable {
+			ins.removed = true
+			changed = true
+			continue
+		}
+
+		if ins.op == OpJump {
+			reachable = false
+		}
+	}
+
+	return changed
+}
+
+func removeJumpsToNext(decoded []*instruction) bool {
+	changed := false
+	instructions := live(decoded)
+	index := indexPositions(decoded)
+
+	for i, ins := range instructions {
+		if !isJump(ins.op) {
+			continue
+		}
+
+		var next *instruction
+		if i+1 < len(instructions) {
+			next = instructions[i+1]
+		}
+		if index.resolve(ins.operands[0]) != next {
+			continue
+		}
+
+		if ins.op == OpJumpNotTruthy {
+			// The condition still has to be popped off the stack.
+			ins.op = OpPop
+			ins.operands = []int{}
+		} else {
+			ins.removed = true
+		}
+		changed = true
+	}
+
+	return changed
+}
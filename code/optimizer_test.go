@@ -0,0 +1,215 @@
+package code
+
+import "testing"
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []Instructions
+		expected string
+	}{
+		{
+			name: "no redundancies",
+			input: []Instructions{
+				Make(OpConstant, 0),
+				Make(OpConstant, 1),
+				Make(OpAdd),
+				Make(OpPop),
+			},
+			expected: `0000 OpConstant 0
+0003 OpConstant 1
+0006 OpAdd
+0007 OpPop
+`,
+		},
+		{
+			// if (true) { 10 }; 3333;
+			name: "true condition",
+			input: []Instructions{
+				Make(OpTrue),
+				Make(OpJumpNotTruthy, 14),
+				Make(OpConstant, 0),
+				Make(OpJump, 15),
+				Make(OpNull),
+				Make(OpPop),
+				Make(OpConstant, 1),
+				Make(OpPop),
+			},
+			expected: `0000 OpConstant 0
+0003 OpPop
+0004 OpConstant 1
+0007 OpPop
+`,
+		},
+		{
+			// if (false) { 10 } else { 20 }; 3333;
+			name: "false condition",
+			input: []Instructions{
+				Make(OpFalse),
+				Make(OpJumpNotTruthy, 14),
+				Make(OpConstant, 0),
+				Make(OpJump, 17),
+				Make(OpConstant, 1),
+				Make(OpPop),
+				Make(OpConstant, 2),
+				Make(OpPop),
+			},
+			expected: `0000 OpConstant 1
+0003 OpPop
+0004 OpConstant 2
+0007 OpPop
+`,
+		},
+		{
+			// if (x) { if (y) { 1 } else { 2 } } else { 3 }
+			name: "jump to jump",
+			input: []Instructions{
+				// 0000
+				Make(OpGetGlobal, 0),
+				// 0003
+				Make(OpJumpNotTruthy, 36),
+				// 0008
+				Make(OpGetGlobal, 1),
+				// 0011
+				Make(OpJumpNotTruthy, 24),
+				// 0016
+				Make(OpConstant, 0),
+				// 0019
+				Make(OpJump, 27),
+				// 0024
+				Make(OpConstant, 1),
+				// 0027
+				Make(OpJump, 39),
+				// 0032
+				Make(OpConstant, 2),
+				// 0035
+				Make(OpPop),
+				// 0036
+				Make(OpConstant, 3),
+				// 0039
+				Make(OpPop),
+			},
+			expected: `0000 OpGetGlobal 0
+0003 OpJumpNotTruthy 32
+0008 OpGetGlobal 1
+0011 OpJumpNotTruthy 24
+0016 OpConstant 0
+0019 OpJump 35
+0024 OpConstant 1
+0027 OpJump 35
+0032 OpConstant 3
+0035 OpPop
+`,
+		},
+		{
+			name: "conditional jump to next",
+			input: []Instructions{
+				Make(OpGetGlobal, 0),
+				Make(OpJumpNotTruthy, 8),
+				Make(OpNull),
+			},
+			expected: `0000 OpGetGlobal 0
+0003 OpPop
+0004 OpNull
+`,
+		},
+		{
+			name: "null jump to next",
+			input: []Instructions{
+				Make(OpGetGlobal, 0),
+				Make(OpJumpNotNull, 8),
+				Make(OpNull),
+			},
+			expected: `0000 OpGetGlobal 0
+0003 OpNull
+`,
+		},
+		{
+			name: "jump to end",
+			input: []Instructions{
+				Make(OpNull),
+				Make(OpJump, 6),
+			},
+			expected: `0000 OpNull
+`,
+		},
+		{
+			name: "jump into folded condition is kept",
+			input: []Instructions{
+				// 0000
+				Make(OpGetGlobal, 0),
+				// 0003
+				Make(OpJumpNotTruthy, 14),
+				// 0008
+				Make(OpFalse),
+				// 0009
+				Make(OpJump, 15),
+				// 0014
+				Make(OpTrue),
+				// 0015
+				Make(OpJumpNotTruthy, 24),
+				// 0020
+				Make(OpConstant, 0),
+				// 0023
+				Make(OpPop),
+				// 0024
+				Make(OpNull),
+			},
+			expected: `0000 OpGetGlobal 0
+0003 OpJumpNotTruthy 14
+0008 OpFalse
+0009 OpJump 15
+0014 OpTrue
+0015 OpJumpNotTruthy 24
+0020 OpConstant 0
+0023 OpPop
+0024 OpNull
+`,
+		},
+		{
+			name: "catch code is kept and its position updated",
+			input: []Instructions{
+				// 0000
+				Make(OpTrue),
+				// 0001
+				Make(OpJumpNotTruthy, 6),
+				// 0006
+				Make(OpTry, 20),
+				// 0011
+				Make(OpConstant, 0),
+				// 0014
+				Make(OpEndTry),
+				// 0015
+				Make(OpJump, 21),
+				// 0020
+				Make(OpPop),
+				// 0021
+				Make(OpNull),
+			},
+			expected: `0000 OpTry 14
+0005 OpConstant 0
+0008 OpEndTry
+0009 OpJump 15
+0014 OpPop
+0015 OpNull
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		input := Instructions{}
+		for _, ins := range tt.input {
+			input = append(input, ins...)
+		}
+		original := append(Instructions{}, input...)
+
+		optimized := Optimize(input)
+		if optimized.String() != tt.expected {
+			t.Errorf("%s: wrong optimized instructions.\nwant=\n%s\ngot =\n%s", tt.name, tt.expected, optimized.String())
+		}
+
+		if input.String() != original.String() {
+			t.Errorf("%s: input was modified.\nwant=\n%s\ngot =\n%s", tt.name, original.String(), input.String())
+		}
+	}
+}
@@ -2,9 +2,13 @@ package compiler
 
 import (
 	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
 
 	"Gengo/ast"
 	"Gengo/code"
+	"Gengo/module"
 	"Gengo/object"
 )
 
@@ -13,21 +17,64 @@ type EmittedInstruction struct {
 	Position int
 }
 
-type Compiler struct {
+// CompilationScope holds the instructions of the function currently being compiled.
+type CompilationScope struct {
 	instructions        code.Instructions
-	constants           []object.Object
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
-	symbolTable         *SymbolTable
+
+	// tries holds the try expressions being compiled, outermost first.
+	tries []tryBlock
+}
+
+// tryBlock is a try expression a return statement may leave. Leaving it removes the
+// handler it installed, if one is installed at the return, and runs its finally
+// block.
+type tryBlock struct {
+	handled bool
+	finally *ast.BlockStatement
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// globals is the global symbol table of the program. The modules it imports are
+	// cached in global bindings, so each is only run once.
+	globals *SymbolTable
+	// loader resolves imports relative to file, the file being compiled.
+	loader *module.Loader
+	file   string
+	// importing holds the files being imported, outermost first, to detect cycles.
+	importing []string
+	// optimize is set if the instructions of each scope are optimized as it is left.
+	optimize bool
+	// chains holds the positions of the OpJumpNull instructions that skip the rest
+	// of each optional chain being compiled, innermost last.
+	chains [][]int
 }
 
+// moduleKey prefixes the path of a module to name the global binding caching it. It
+// can't be the name of a binding in a program.
+const moduleKey = "module:"
+
 func New() *Compiler {
-	return &Compiler{
+	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
-		constants:           []object.Object{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
-		symbolTable:         NewSymbolTable(),
+	}
+
+	symbolTable := NewSymbolTableWithBuiltins()
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+		globals:     symbolTable,
 	}
 }
 
@@ -35,12 +82,51 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 	compiler := New()
 	compiler.symbolTable = s
 	compiler.constants = constants
+	compiler.globals = s
 	return compiler
 }
 
+// SetLoader sets the loader that resolves imports, and the file the compiled
+// programs were read from, which imports are relative to. Without a loader, modules
+// are read from the file system without a search path.
+func (c *Compiler) SetLoader(loader *module.Loader, file string) {
+	c.loader = loader
+	c.file = file
+}
+
+// SetOptimize sets whether the instructions of each function and module, and of the
+// program, are run through code.Optimize once they are compiled. Without it the
+// instructions follow the source as they are emitted.
+func (c *Compiler) SetOptimize(optimize bool) {
+	c.optimize = optimize
+}
+
+// NewSymbolTableWithBuiltins returns a global symbol table that resolves the builtins
+// of the default registry, including those registered after it was created, for use
+// with NewWithState.
+func NewSymbolTableWithBuiltins() *SymbolTable {
+	return NewSymbolTableWithRegistry(nil)
+}
+
+// NewSymbolTableWithRegistry returns a global symbol table like
+// NewSymbolTableWithBuiltins, that resolves the builtins of registry instead. The
+// programs compiled with it import the modules of registry, and must be run by a VM
+// using it.
+func NewSymbolTableWithRegistry(registry *object.Registry) *SymbolTable {
+	symbolTable := NewSymbolTable()
+	symbolTable.builtins = true
+	symbolTable.registry = registry
+	return symbolTable
+}
+
 func (c *Compiler) Compile(node ast.Node) error {
 	switch node := node.(type) {
 	case *ast.Program:
+		err := c.hoistFunctions(node.Statements)
+		if err != nil {
+			return err
+		}
+
 		for _, s := range node.Statements {
 			err := c.Compile(s)
 			if err != nil {
@@ -66,15 +152,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
-		if c.lastInstructionIsPop() {
+		if c.lastInstructionIs(code.OpPop) {
 			c.removeLastPop()
 		}
 
 		// Emit an `OpJump` with a bogus value.
 		jumpPos := c.emit(code.OpJump, 9999)
 
-		afterConsequencePos := len(c.instructions)
-		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+		afterConsequencePos := len(c.currentInstructions())
+		err = c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+		if err != nil {
+			return err
+		}
 
 		if node.Alternative == nil {
 			c.emit(code.OpNull)
@@ -84,21 +173,56 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 
-			if c.lastInstructionIsPop() {
+			if c.lastInstructionIs(code.OpPop) {
 				c.removeLastPop()
 			}
 		}
 
-		afterAlternativePos := len(c.instructions)
-		c.changeOperand(jumpPos, afterAlternativePos)
+		afterAlternativePos := len(c.currentInstructions())
+		err = c.changeOperand(jumpPos, afterAlternativePos)
+		if err != nil {
+			return err
+		}
+	case *ast.TernaryExpression:
+		return c.compileTernary(node)
+	case *ast.TryExpression:
+		return c.compileTry(node)
+	case *ast.MatchExpression:
+		return c.compileMatch(node)
 	case *ast.BlockStatement:
+		err := c.hoistFunctions(node.Statements)
+		if err != nil {
+			return err
+		}
+
 		for _, s := range node.Statements {
+			switch s.(type) {
+			case *ast.ImportStatement, *ast.ExportStatement:
+				return fmt.Errorf("%s is only allowed at the top level", s.TokenLiteral())
+			}
+
 			err := c.Compile(s)
 			if err != nil {
 				return err
 			}
 		}
+
+		// Like in the evaluator, a block that doesn't end in an expression evaluates to null.
+		if len(node.Statements) == 0 {
+			c.emit(code.OpNull)
+			c.emit(code.OpPop)
+		} else {
+			switch node.Statements[len(node.Statements)-1].(type) {
+			case *ast.LetStatement, *ast.FunctionDeclaration:
+				c.emit(code.OpNull)
+				c.emit(code.OpPop)
+			}
+		}
 	case *ast.InfixExpression:
+		if node.Operator == "??" {
+			return c.compileCoalesce(node)
+		}
+
 		if node.Operator == "<" {
 			err := c.Compile(node.Right)
 			if err != nil {
@@ -132,6 +256,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpMul)
 		case "/":
 			c.emit(code.OpDiv)
+		case "**":
+			c.emit(code.OpPow)
 		case ">":
 			c.emit(code.OpGreaterThan)
 		case "==":
@@ -155,91 +281,1157 @@ func (c *Compiler) Compile(node ast.Node) error {
 		default:
 			return fmt.Errorf("unknown operator %s", node.Operator)
 		}
+	case *ast.FunctionDeclaration:
+		// The function was compiled at the start of its block.
+		return nil
 	case *ast.LetStatement:
-		err := c.Compile(node.Value)
+		if node.Pattern != nil {
+			err := c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+			return c.compileDestructure(node.Pattern, node.IsConst())
+		}
+
+		var err error
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+			_, err = c.compileFunctionLiteral(fn, node.Name.Value)
+		} else {
+			err = c.Compile(node.Value)
+		}
+		if err != nil {
+			return err
+		}
+
+		symbol, err := c.define(node.Name.Value, node.IsConst())
+		if err != nil {
+			return err
+		}
+		err = c.storeSymbol(symbol)
 		if err != nil {
 			return err
 		}
-		symbol := c.symbolTable.Define(node.Name.Value)
-		c.emit(code.OpSetGlobal, symbol.Index)
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+			// Like the evaluator, only fail if the identifier is evaluated, with an
+			// error try can catch.
+			nameIndex := c.addConstant(&object.String{Value: node.Value})
+			if !code.Fits(4, nameIndex) {
+				return fmt.Errorf("too many constants, the limit is %d", int64(code.MaxUint32)+1)
+			}
+			c.emit(code.OpUndefined, nameIndex)
+		} else {
+			c.loadSymbol(symbol)
 		}
-		c.emit(code.OpGetGlobal, symbol.Index)
 	case *ast.Boolean:
 		if node.Value {
 			c.emit(code.OpTrue)
 		} else {
 			c.emit(code.OpFalse)
 		}
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
 	case *ast.IntegerLiteral:
 		integer := &object.Integer{Value: node.Value}
-		c.emit(code.OpConstant, c.addConstant(integer))
+		err := c.emitConstant(integer)
+		if err != nil {
+			return err
+		}
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		err := c.emitConstant(float)
+		if err != nil {
+			return err
+		}
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		err := c.emitConstant(str)
+		if err != nil {
+			return err
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			err := c.Compile(el)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !code.Fits(2, len(node.Elements)) {
+			return fmt.Errorf("too many elements in array literal, the limit is %d", code.MaxUint16)
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		var keys []ast.Expression
+		for k := range node.Pairs {
+			keys = append(keys, k)
+		}
+
+		// Go doesn't guarantee a consistent order when iterating over a map,
+		// sort the keys so the emitted instructions are deterministic.
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		for _, k := range keys {
+			err := c.Compile(k)
+			if err != nil {
+				return err
+			}
+			err = c.Compile(node.Pairs[k])
+			if err != nil {
+				return err
+			}
+		}
+
+		if !code.Fits(2, len(node.Pairs)*2) {
+			return fmt.Errorf("too many pairs in hash literal, the limit is %d", code.MaxUint16/2)
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		if node.Optional {
+			c.skipChainIfNull()
+		}
+
+		err = c.Compile(node.Index)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpIndex)
+
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		if node.Optional {
+			c.skipChainIfNull()
+		}
+
+		err = c.compileOptional(node.Start, node.End)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpSlice)
+
+	case *ast.RangeExpression:
+		err := c.compileOptional(node.Start, node.End, node.Step)
+		if err != nil {
+			return err
+		}
+
+		inclusive := 0
+		if node.Inclusive {
+			inclusive = 1
+		}
+		c.emit(code.OpRange, inclusive)
+	case *ast.MemberExpression:
+		err := c.Compile(node.Object)
+		if err != nil {
+			return err
+		}
+
+		if node.Optional {
+			c.skipChainIfNull()
+		}
+
+		err = c.emitConstant(&object.String{Value: node.Property.Value})
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpGetAttribute)
+
+	case *ast.OptionalChain:
+		return c.compileOptionalChain(node)
+	case *ast.FunctionLiteral:
+		_, err := c.compileFunctionLiteral(node, "")
+		return err
+	case *ast.ImportStatement:
+		return c.compileImport(node)
+	case *ast.ExportStatement:
+		return c.Compile(node.Statement)
+	case *ast.ReturnStatement:
+		err := c.Compile(node.ReturnValue)
+		if err != nil {
+			return err
+		}
+
+		err = c.leaveTries()
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpReturnValue)
+	case *ast.ThrowStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpThrow)
+	case *ast.CallExpression:
+		err := c.Compile(node.Function)
+		if err != nil {
+			return err
+		}
+
+		if hasSpreadOrNamed(node.Arguments) {
+			return c.compileApply(node.Arguments)
+		}
+
+		for _, a := range node.Arguments {
+			err := c.Compile(a)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !code.Fits(1, len(node.Arguments)) {
+			return fmt.Errorf("too many arguments in call, the limit is %d", code.MaxUint8)
+		}
+		c.emit(code.OpCall, len(node.Arguments))
 	}
 
 	return nil
 }
 
-func (c *Compiler) addConstant(obj object.Object) int {
-	c.constants = append(c.constants, obj)
-	return len(c.constants) - 1
-}
+// hoistFunctions compiles the functions declared among statements, before the
+// statements, so they can be called before they are declared and call each other.
+func (c *Compiler) hoistFunctions(statements []ast.Statement) error {
+	// Like in the evaluator, a function can't have the name of a constant declared
+	// in the same block.
+	constants := make(map[string]bool)
+	for _, s := range statements {
+		if let, ok := ast.Declaration(s).(*ast.LetStatement); ok && let.IsConst() {
+			for _, name := range let.Names() {
+				constants[name.Value] = true
+			}
+		}
+	}
 
-func (c *Compiler) emit(op code.Opcode, operands ...int) int {
-	ins := code.Make(op, operands...)
-	pos := c.addInstruction(ins)
+	var decls []*ast.FunctionDeclaration
+	var symbols []Symbol
+	for _, s := range statements {
+		if decl, ok := ast.Declaration(s).(*ast.FunctionDeclaration); ok {
+			if constants[decl.Name.Value] {
+				return fmt.Errorf("cannot reassign constant %s", decl.Name.Value)
+			}
+			symbol, err := c.define(decl.Name.Value, false)
+			if err != nil {
+				return err
+			}
+			decls = append(decls, decl)
+			symbols = append(symbols, symbol)
+		}
+	}
 
-	c.setLastInstruction(op, pos)
+	// Like in the evaluator, the functions can use the globals defined by the let
+	// statements after them, so those are declared first. Their let statements
+	// define them again in the same slots.
+	if len(decls) > 0 && c.symbolTable.Outer == nil {
+		for _, s := range statements {
+			if let, ok := ast.Declaration(s).(*ast.LetStatement); ok {
+				for _, name := range let.Names() {
+					if _, ok := c.symbolTable.Resolve(name.Value); !ok {
+						c.symbolTable.Define(name.Value)
+					}
+				}
+			}
+		}
+	}
 
-	return pos
-}
+	// A local function that captures one declared after it captures it before it
+	// is created, so the closure is patched once all of them are.
+	type patch struct {
+		closure, value Symbol
+		freeIndex      int
+	}
+	var patches []patch
 
-func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
-	previous := c.lastInstruction
-	last := EmittedInstruction{Opcode: op, Position: pos}
+	for i, decl := range decls {
+		free, err := c.compileFunctionLiteral(decl.Function, decl.Name.Value)
+		if err != nil {
+			return err
+		}
+		err = c.storeSymbol(symbols[i])
+		if err != nil {
+			return err
+		}
 
-	c.previousInstruction = previous
-	c.lastInstruction = last
-}
+		for freeIndex, s := range free {
+			for _, later := range symbols[i+1:] {
+				if s.Scope == LocalScope && s == later {
+					patches = append(patches, patch{closure: symbols[i], value: later, freeIndex: freeIndex})
+				}
+			}
+		}
+	}
 
-func (c *Compiler) addInstruction(ins []byte) int {
-	posNewInstruction := len(c.instructions)
-	c.instructions = append(c.instructions, ins...)
-	return posNewInstruction
+	for _, p := range patches {
+		c.loadSymbol(p.closure)
+		c.loadSymbol(p.value)
+		c.emit(code.OpPatchFree, p.freeIndex)
+	}
+
+	return nil
 }
 
-func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
-	for i := 0; i < len(newInstruction); i++ {
-		c.instructions[pos+i] = newInstruction[i]
+// hasSpreadOrNamed reports whether args has spread or named arguments.
+func hasSpreadOrNamed(args []ast.Expression) bool {
+	for _, arg := range args {
+		switch arg.(type) {
+		case *ast.SpreadExpression, *ast.NamedArgument:
+			return true
+		}
 	}
+	return false
 }
 
-func (c *Compiler) changeOperand(opPos int, operand int) {
-	op := code.Opcode(c.instructions[opPos])
-	newInstruction := code.Make(op, operand)
+// compileApply compiles the arguments of a call with spread or named arguments,
+// and the call. The positional arguments are passed as an array of arrays, those of
+// spread arguments and of the other arguments between them, and the named ones as
+// a hash, or null if there are none.
+func (c *Compiler) compileApply(args []ast.Expression) error {
+	if !code.Fits(2, len(args)) {
+		return fmt.Errorf("too many arguments in call, the limit is %d", code.MaxUint16)
+	}
 
-	c.replaceInstruction(opPos, newInstruction)
-}
+	numGroups, groupSize := 0, 0
+	endGroup := func() {
+		if groupSize > 0 {
+			c.emit(code.OpArray, groupSize)
+			numGroups, groupSize = numGroups+1, 0
+		}
+	}
 
-func (c *Compiler) lastInstructionIsPop() bool {
-	return c.lastInstruction.Opcode == code.OpPop
-}
+	var named []*ast.NamedArgument
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case *ast.SpreadExpression:
+			endGroup()
+			err := c.Compile(arg.Value)
+			if err != nil {
+				return err
+			}
+			numGroups++
+		case *ast.NamedArgument:
+			named = append(named, arg)
+		default:
+			err := c.Compile(arg)
+			if err != nil {
+				return err
+			}
+			groupSize++
+		}
+	}
+	endGroup()
+	c.emit(code.OpArray, numGroups)
 
-func (c *Compiler) removeLastPop() {
-	c.instructions = c.instructions[:c.lastInstruction.Position]
-	c.lastInstruction = c.previousInstruction
+	if len(named) == 0 {
+		c.emit(code.OpNull)
+	} else {
+		for _, arg := range named {
+			err := c.emitConstant(&object.String{Value: arg.Name.Value})
+			if err != nil {
+				return err
+			}
+			err = c.Compile(arg.Value)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(named)*2)
+	}
+
+	c.emit(code.OpApply)
+	return nil
 }
 
-type Bytecode struct {
-	Instructions code.Instructions
-	Constants    []object.Object
+// compileFunctionLiteral compiles fn into a closure. name is the name the function is
+// bound to, if any, so the function can call itself. It returns the symbols of the
+// free variables the closure captures.
+func (c *Compiler) compileFunctionLiteral(fn *ast.FunctionLiteral, name string) ([]Symbol, error) {
+	c.enterScope()
+
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+
+	// Parameters destructured with a pattern hold the argument in a binding no
+	// identifier can name, and are destructured once all the arguments are bound.
+	for i, p := range fn.Parameters {
+		if ident, ok := p.(*ast.Identifier); ok {
+			c.symbolTable.Define(ident.Value)
+		} else {
+			c.symbolTable.Define(strconv.Itoa(i))
+		}
+	}
+	if fn.Rest != nil {
+		c.symbolTable.Define(fn.Rest.Value)
+	}
+
+	// Parameters left without an argument get their default value, in order, so
+	// defaults can refer to the parameters before them.
+	for i, p := range fn.Parameters {
+		if def := fn.Default(i); def != nil {
+			c.emit(code.OpMissing, i)
+			jumpPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+			err := c.Compile(def)
+			if err != nil {
+				return nil, err
+			}
+			c.emit(code.OpSetLocal, i)
+
+			err = c.changeOperand(jumpPos, len(c.currentInstructions()))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if _, ok := p.(*ast.Identifier); !ok {
+			c.emit(code.OpGetLocal, i)
+			err := c.compileDestructure(p, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err := c.Compile(fn.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+	markTailCalls(c.currentInstructions())
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	instructions := c.leaveScope()
+
+	if !code.Fits(1, numLocals) {
+		return nil, fmt.Errorf("too many local bindings in function, the limit is %d", code.MaxUint8+1)
+	}
+	if !code.Fits(1, len(freeSymbols)) {
+		return nil, fmt.Errorf("too many free variables in function, the limit is %d", code.MaxUint8)
+	}
+
+	for _, s := range freeSymbols {
+		c.loadSymbol(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Name:          name,
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(fn.Parameters),
+		Signature:     object.NewSignature(fn.Parameters, fn.Defaults, fn.Rest),
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	if !code.Fits(4, fnIndex) {
+		return nil, fmt.Errorf("too many constants, the limit is %d", int64(code.MaxUint32)+1)
+	}
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return freeSymbols, nil
+}
+
+// markTailCalls turns the calls in ins whose value the function returns right away,
+// maybe after unconditional jumps, into tail calls. A call in a try block is followed
+// by OpEndTry, so it never is one.
+func markTailCalls(ins code.Instructions) {
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return
+		}
+		_, read := code.ReadOperands(def, ins[i+1:])
+		next := i + 1 + read
+
+		if returnsAt(ins, next) {
+			switch code.Opcode(ins[i]) {
+			case code.OpCall:
+				ins[i] = byte(code.OpTailCall)
+			case code.OpApply:
+				ins[i] = byte(code.OpTailApply)
+			}
+		}
+
+		i = next
+	}
+}
+
+// returnsAt reports whether the instruction at pos, or the one the jumps starting
+// there lead to, returns the value on top of the stack.
+func returnsAt(ins code.Instructions, pos int) bool {
+	for pos < len(ins) && code.Opcode(ins[pos]) == code.OpJump {
+		pos = int(code.ReadUint32(ins[pos+1:]))
+	}
+	return pos < len(ins) && code.Opcode(ins[pos]) == code.OpReturnValue
+}
+
+// compileOptionalChain compiles the expression of chain, whose optional member,
+// index and slice expressions jump past it, leaving null on the stack, if their left
+// side is null.
+func (c *Compiler) compileOptionalChain(chain *ast.OptionalChain) error {
+	c.chains = append(c.chains, nil)
+	err := c.Compile(chain.Expression)
+	jumps := c.chains[len(c.chains)-1]
+	c.chains = c.chains[:len(c.chains)-1]
+	if err != nil {
+		return err
+	}
+
+	for _, pos := range jumps {
+		if err := c.changeOperand(pos, len(c.currentInstructions())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipChainIfNull emits the jump of an optional expression past the rest of the
+// optional chain it is in.
+func (c *Compiler) skipChainIfNull() {
+	pos := c.emit(code.OpJumpNull, 9999)
+	c.chains[len(c.chains)-1] = append(c.chains[len(c.chains)-1], pos)
+}
+
+// compileOptional compiles each of nodes, and null for those that are nil, which
+// the parser leaves for the parts of an expression that were left out.
+func (c *Compiler) compileOptional(nodes ...ast.Expression) error {
+	for _, node := range nodes {
+		if node == nil {
+			c.emit(code.OpNull)
+			continue
+		}
+		if err := c.Compile(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileTernary compiles cond ? a : b like an if expression, with an expression
+// rather than a block in each branch.
+func (c *Compiler) compileTernary(node *ast.TernaryExpression) error {
+	err := c.Compile(node.Condition)
+	if err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	err = c.Compile(node.Consequence)
+	if err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	err = c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	if err != nil {
+		return err
+	}
+
+	err = c.Compile(node.Alternative)
+	if err != nil {
+		return err
+	}
+
+	return c.changeOperand(jumpPos, len(c.currentInstructions()))
+}
+
+// compileCoalesce compiles left ?? right, which only evaluates right if left is
+// null.
+func (c *Compiler) compileCoalesce(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+	jumpNotNullPos := c.emit(code.OpJumpNotNull, 9999)
+	c.emit(code.OpPop)
+
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+
+	return c.changeOperand(jumpNotNullPos, len(c.currentInstructions()))
+}
+
+// compileImport binds the module the import names. The first import of a module
+// compiles it and runs it, and later ones load it from the global binding it is
+// cached in. Modules registered in Go are constants.
+func (c *Compiler) compileImport(node *ast.ImportStatement) error {
+	if mod, ok := c.globals.registry.GetModule(node.Path.Value); ok {
+		err := c.emitConstant(mod)
+		if err != nil {
+			return err
+		}
+
+		symbol, err := c.define(node.Name.Value, false)
+		if err != nil {
+			return err
+		}
+		return c.storeSymbol(symbol)
+	}
+
+	loader := c.loader
+	if loader == nil {
+		loader = module.NewLoader()
+	}
+
+	source, err := loader.Load(c.file, node.Path.Value)
+	if err != nil {
+		return err
+	}
+
+	cached, ok := c.globals.Resolve(moduleKey + source.Path)
+	if !ok {
+		err = c.compileModule(source)
+		if err != nil {
+			return err
+		}
+
+		cached = c.globals.Define(moduleKey + source.Path)
+		err = c.storeSymbol(cached)
+		if err != nil {
+			return err
+		}
+	}
+
+	symbol, err := c.define(node.Name.Value, false)
+	if err != nil {
+		return err
+	}
+	c.loadSymbol(cached)
+	return c.storeSymbol(symbol)
+}
+
+// compileModule compiles a module into a function that runs its statements in a
+// scope of its own and returns the module, and emits the call of the function.
+func (c *Compiler) compileModule(source *module.Source) error {
+	importing := c.importing
+	if len(importing) == 0 && c.file != "" {
+		importing = []string{filepath.Clean(c.file)}
+	}
+	if err := module.Cycle(importing, source.Path); err != nil {
+		return err
+	}
+
+	file, symbolTable := c.file, c.symbolTable
+	c.file, c.importing = source.Path, append(importing, source.Path)
+	defer func() {
+		c.file, c.importing = file, importing
+	}()
+
+	c.enterScope()
+	// A module only sees its own bindings and the builtins.
+	c.symbolTable = NewEnclosedSymbolTable(NewSymbolTableWithRegistry(c.globals.registry))
+
+	err := c.hoistFunctions(source.Program.Statements)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range source.Program.Statements {
+		err := c.Compile(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	exports := module.Exports(source.Program)
+	err = c.emitConstant(&object.String{Value: source.Path})
+	if err != nil {
+		return err
+	}
+	for _, name := range exports {
+		err = c.emitConstant(&object.String{Value: name})
+		if err != nil {
+			return err
+		}
+
+		symbol, _ := c.symbolTable.Resolve(name)
+		c.loadSymbol(symbol)
+	}
+	if !code.Fits(4, len(exports)) {
+		return fmt.Errorf("too many exports in module %s, the limit is %d", source.Path, int64(code.MaxUint32))
+	}
+	c.emit(code.OpModule, len(exports))
+	c.emit(code.OpReturnValue)
+
+	numLocals := c.symbolTable.numDefinitions
+	instructions := c.leaveScope()
+	c.symbolTable = symbolTable
+
+	if !code.Fits(1, numLocals) {
+		return fmt.Errorf("too many local bindings in module %s, the limit is %d", source.Path, code.MaxUint8+1)
+	}
+
+	fnIndex := c.addConstant(&object.CompiledFunction{Name: source.Path, Instructions: instructions, NumLocals: numLocals})
+	if !code.Fits(4, fnIndex) {
+		return fmt.Errorf("too many constants, the limit is %d", int64(code.MaxUint32)+1)
+	}
+	c.emit(code.OpClosure, fnIndex, 0)
+	c.emit(code.OpCall, 0)
+
+	return nil
+}
+
+// compileTry compiles a try expression. The try block runs with a handler installed
+// that resumes at the catch block, with the exception on the stack. With a finally
+// block, the catch block runs with a handler too, and both handlers resume at code
+// that runs the finally block and throws the exception again. The finally block is
+// also compiled after the try and catch blocks and at every return leaving them. A
+// block leaves the stack as it found it, so its value doesn't have to be popped.
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	tryPos := c.emit(code.OpTry, 9999)
+
+	err := c.compileTryBlock(node.Block, node.Finally)
+	if err != nil {
+		return err
+	}
+	jumps := []int{c.emit(code.OpJump, 9999)}
+	handlerPos := tryPos
+
+	if node.Catch != nil {
+		err = c.changeOperand(tryPos, len(c.currentInstructions()))
+		if err != nil {
+			return err
+		}
+
+		// The parameter is only bound in the catch block.
+		c.enterBlock()
+		if node.Parameter != nil {
+			symbol, err := c.define(node.Parameter.Value, false)
+			if err != nil {
+				return err
+			}
+			err = c.storeSymbol(symbol)
+			if err != nil {
+				return err
+			}
+		} else {
+			c.emit(code.OpPop)
+		}
+
+		if node.Finally == nil {
+			err = c.compileBlockValue(node.Catch)
+			if err != nil {
+				return err
+			}
+		} else {
+			handlerPos = c.emit(code.OpTry, 9999)
+
+			err = c.compileTryBlock(node.Catch, node.Finally)
+			if err != nil {
+				return err
+			}
+		}
+		c.leaveBlock()
+		jumps = append(jumps, c.emit(code.OpJump, 9999))
+	}
+
+	if node.Finally != nil {
+		err = c.changeOperand(handlerPos, len(c.currentInstructions()))
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(node.Finally)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	}
+
+	afterTryPos := len(c.currentInstructions())
+	for _, pos := range jumps {
+		err = c.changeOperand(pos, afterTryPos)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compileMatch compiles a match expression. The value stays on the stack while the
+// arms are tried. An arm matches it with OpMatch, binds what the pattern bound,
+// checks the guard and pops the value before its body. Without a matching arm the
+// value is popped and the match evaluates to null.
+func (c *Compiler) compileMatch(node *ast.MatchExpression) error {
+	err := c.Compile(node.Value)
+	if err != nil {
+		return err
+	}
+
+	var endJumps []int
+	for _, arm := range node.Arms {
+		patternIndex := c.addConstant(&object.Pattern{Pattern: arm.Pattern})
+		if !code.Fits(4, patternIndex) {
+			return fmt.Errorf("too many constants, the limit is %d", int64(code.MaxUint32)+1)
+		}
+		c.emit(code.OpMatch, patternIndex)
+		nextArmJumps := []int{c.emit(code.OpJumpNotTruthy, 9999)}
+
+		// Each arm binds its names in a scope of its own.
+		c.enterBlock()
+
+		// The bound values are pushed in order, so the last one is on top.
+		bindings := ast.Bindings(arm.Pattern)
+		for i := len(bindings) - 1; i >= 0; i-- {
+			symbol, err := c.define(bindings[i].Value, false)
+			if err != nil {
+				return err
+			}
+			err = c.storeSymbol(symbol)
+			if err != nil {
+				return err
+			}
+		}
+
+		if arm.Guard != nil {
+			err = c.Compile(arm.Guard)
+			if err != nil {
+				return err
+			}
+			nextArmJumps = append(nextArmJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		c.emit(code.OpPop)
+		err = c.compileBlockValue(arm.Body)
+		if err != nil {
+			return err
+		}
+		c.leaveBlock()
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+
+		nextArmPos := len(c.currentInstructions())
+		for _, pos := range nextArmJumps {
+			err = c.changeOperand(pos, nextArmPos)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+
+	afterMatchPos := len(c.currentInstructions())
+	for _, pos := range endJumps {
+		err = c.changeOperand(pos, afterMatchPos)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compileDestructure destructures the value on top of the stack with pattern, and
+// binds its identifiers, as constants if constant is set.
+func (c *Compiler) compileDestructure(pattern ast.Pattern, constant bool) error {
+	patternIndex := c.addConstant(&object.Pattern{Pattern: pattern})
+	if !code.Fits(4, patternIndex) {
+		return fmt.Errorf("too many constants, the limit is %d", int64(code.MaxUint32)+1)
+	}
+	c.emit(code.OpDestructure, patternIndex)
+
+	// The bound values are pushed in order, so the last one is on top.
+	bindings := ast.Bindings(pattern)
+	for i := len(bindings) - 1; i >= 0; i-- {
+		symbol, err := c.define(bindings[i].Value, constant)
+		if err != nil {
+			return err
+		}
+		err = c.storeSymbol(symbol)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compileTryBlock compiles a block run with the handler installed by the OpTry just
+// emitted. The handler is removed and the finally block, if any, runs after it.
+func (c *Compiler) compileTryBlock(block, finally *ast.BlockStatement) error {
+	tries := c.scopes[c.scopeIndex].tries
+	c.scopes[c.scopeIndex].tries = append(tries, tryBlock{handled: true, finally: finally})
+
+	err := c.compileBlockValue(block)
+	c.scopes[c.scopeIndex].tries = tries
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpEndTry)
+	if finally != nil {
+		return c.Compile(finally)
+	}
+	return nil
+}
+
+// compileBlockValue compiles a block that leaves its value on the stack.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	err := c.Compile(block)
+	if err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	}
+	return nil
+}
+
+// leaveTries compiles leaving the try expressions a return is in, innermost first.
+// A return or a throw in one of their finally blocks only leaves the try expressions
+// around that one.
+func (c *Compiler) leaveTries() error {
+	tries := c.scopes[c.scopeIndex].tries
+	defer func() {
+		c.scopes[c.scopeIndex].tries = tries
+	}()
+
+	for i := len(tries) - 1; i >= 0; i-- {
+		c.scopes[c.scopeIndex].tries = tries[:i]
+
+		if tries[i].handled {
+			c.emit(code.OpEndTry)
+		}
+		if tries[i].finally != nil {
+			err := c.Compile(tries[i].finally)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// define defines name in the current scope, as a constant if constant is set. A
+// constant can't be defined again in the scope it was defined in.
+func (c *Compiler) define(name string, constant bool) (Symbol, error) {
+	if c.symbolTable.IsConst(name) {
+		return Symbol{}, fmt.Errorf("cannot reassign constant %s", name)
+	}
+	if constant {
+		return c.symbolTable.DefineConst(name), nil
+	}
+	return c.symbolTable.Define(name), nil
+}
+
+func (c *Compiler) storeSymbol(s Symbol) error {
+	switch s.Scope {
+	case GlobalScope:
+		switch {
+		case code.Fits(2, s.Index):
+			c.emit(code.OpSetGlobal, s.Index)
+		case code.Fits(4, s.Index):
+			c.emit(code.OpSetGlobalWide, s.Index)
+		default:
+			return fmt.Errorf("too many global bindings, %s exceeds the limit of %d", s.Name, int64(code.MaxUint32)+1)
+		}
+	default:
+		if !code.Fits(1, s.Index) {
+			return fmt.Errorf("too many local bindings, %s exceeds the limit of %d", s.Name, code.MaxUint8+1)
+		}
+		c.emit(code.OpSetLocal, s.Index)
+	}
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		if code.Fits(2, s.Index) {
+			c.emit(code.OpGetGlobal, s.Index)
+		} else {
+			c.emit(code.OpGetGlobalWide, s.Index)
+		}
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emitConstant adds obj to the constant pool and emits the instruction loading it,
+// switching to OpConstantWide once the index no longer fits in 2 bytes.
+func (c *Compiler) emitConstant(obj object.Object) error {
+	index := c.addConstant(obj)
+
+	switch {
+	case code.Fits(2, index):
+		c.emit(code.OpConstant, index)
+	case code.Fits(4, index):
+		c.emit(code.OpConstantWide, index)
+	default:
+		return fmt.Errorf("too many constants, the limit is %d", int64(code.MaxUint32)+1)
+	}
+
+	return nil
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) error {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		return err
+	}
+
+	if !code.Fits(def.OperandWidths[0], operand) {
+		return fmt.Errorf("%s target %d exceeds the maximum jump target of %d", def.Name, operand, int64(code.MaxUint32))
+	}
+
+	newInstruction := code.Make(op, operand)
+	c.replaceInstruction(opPos, newInstruction)
+	return nil
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	newIns := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = newIns
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+// enterBlock gives the bindings compiled until leaveBlock a scope of their own.
+func (c *Compiler) enterBlock() {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlock() {
+	c.symbolTable = c.symbolTable.Outer
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.optimized(c.currentInstructions())
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	// Globals holds the names of the global bindings by index, for errors.
+	Globals []string
+}
+
+// optimized returns ins optimized if the compiler optimizes. The jump targets in a
+// scope are positions in its own instructions, so each is optimized on its own.
+func (c *Compiler) optimized(ins code.Instructions) code.Instructions {
+	if !c.optimize {
+		return ins
+	}
+	return code.Optimize(ins)
 }
 
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
-		Instructions: c.instructions,
+		Instructions: c.optimized(c.currentInstructions()),
 		Constants:    c.constants,
+		Globals:      c.globals.globalNames(),
 	}
 }
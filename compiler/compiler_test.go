@@ -1,7 +1,9 @@
 package compiler
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
 	"Gengo/ast"
@@ -184,18 +186,18 @@ func TestConditionals(t *testing.T) {
 				// 0000
 				code.Make(code.OpTrue),
 				// 0001
-				code.Make(code.OpJumpNotTruthy, 10),
-				// 0004
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0006
 				code.Make(code.OpConstant, 0),
-				// 0007
-				code.Make(code.OpJump, 11),
-				// 0010
+				// 0009
+				code.Make(code.OpJump, 15),
+				// 0014
 				code.Make(code.OpNull),
-				// 0011
+				// 0015
 				code.Make(code.OpPop),
-				// 0012
+				// 0016
 				code.Make(code.OpConstant, 1),
-				// 0015
+				// 0019
 				code.Make(code.OpPop),
 			},
 		},
@@ -206,21 +208,478 @@ func TestConditionals(t *testing.T) {
 				// 0000
 				code.Make(code.OpTrue),
 				// 0001
-				code.Make(code.OpJumpNotTruthy, 10),
-				// 0004
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0006
+				code.Make(code.OpConstant, 0),
+				// 0009
+				code.Make(code.OpJump, 17),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpPop),
+				// 0018
+				code.Make(code.OpConstant, 2),
+				// 0021
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestRangesAndSlices(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1..<3 step 2;",
+			expectedConstants: []interface{}{1, 3, 2},
+			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpRange, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1..3;",
+			expectedConstants: []interface{}{1, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpNull),
+				code.Make(code.OpRange, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1][1:];",
+			expectedConstants: []interface{}{1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "null?.[:1];",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpNull),
+				// 0001
+				code.Make(code.OpJumpNull, 11),
+				// 0006
+				code.Make(code.OpNull),
 				// 0007
-				code.Make(code.OpJump, 13),
+				code.Make(code.OpConstant, 0),
 				// 0010
+				code.Make(code.OpSlice),
+				// 0011
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionalOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true ? 10 : 20;",
+			expectedConstants: []interface{}{10, 20},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0006
+				code.Make(code.OpConstant, 0),
+				// 0009
+				code.Make(code.OpJump, 17),
+				// 0014
 				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "null ?? 10;",
+			expectedConstants: []interface{}{10},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpNull),
+				// 0001
+				code.Make(code.OpJumpNotNull, 10),
+				// 0006
+				code.Make(code.OpPop),
+				// 0007
+				code.Make(code.OpConstant, 0),
+				// 0010
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "null?.[0];",
+			expectedConstants: []interface{}{0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpNull),
+				// 0001
+				code.Make(code.OpJumpNull, 10),
+				// 0006
+				code.Make(code.OpConstant, 0),
+				// 0009
+				code.Make(code.OpIndex),
+				// 0010
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "null?.a;",
+			expectedConstants: []interface{}{"a"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpNull),
+				// 0001
+				code.Make(code.OpJumpNull, 10),
+				// 0006
+				code.Make(code.OpConstant, 0),
+				// 0009
+				code.Make(code.OpGetAttribute),
+				// 0010
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// The jump skips the rest of the chain, the call included.
+			input:             "null?.a();",
+			expectedConstants: []interface{}{"a"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpNull),
+				// 0001
+				code.Make(code.OpJumpNull, 12),
+				// 0006
+				code.Make(code.OpConstant, 0),
+				// 0009
+				code.Make(code.OpGetAttribute),
+				// 0010
+				code.Make(code.OpCall, 0),
+				// 0012
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "match (1) { x => x }",
+			expectedConstants: []interface{}{1, "x"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpMatch, 1),
+				// 0008
+				code.Make(code.OpJumpNotTruthy, 25),
 				// 0013
+				code.Make(code.OpSetGlobal, 0),
+				// 0016
 				code.Make(code.OpPop),
-				// 0014
+				// 0017
+				code.Make(code.OpGetGlobal, 0),
+				// 0020
+				code.Make(code.OpJump, 27),
+				// 0025
+				code.Make(code.OpPop),
+				// 0026
+				code.Make(code.OpNull),
+				// 0027
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let [a, b] = [1, 2];",
+			expectedConstants: []interface{}{1, 2, "[a, b]"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpDestructure, 2),
+				// b is on top, so it is bound first.
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+		{
+			input: "fn(x, {y}) { x + y }",
+			expectedConstants: []interface{}{
+				"{y}",
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpDestructure, 0),
+					code.Make(code.OpSetLocal, 2),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 2),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "f(); fn f() { 1 }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpCall, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { fn a() { b() } fn b() { a() } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpTailCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpTailCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					// a captures b before b is created.
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpSetLocal, 1),
+					// Once it is, a is patched.
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpPatchFree, 0),
+					code.Make(code.OpNull),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(f) { if (true) { f(1) } else { f(...[2]) } }",
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpTrue),
+					code.Make(code.OpJumpNotTruthy, 18),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpJump, 31),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpArray, 1),
+					code.Make(code.OpArray, 1),
+					code.Make(code.OpNull),
+					code.Make(code.OpTailApply),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// The handler has to be removed after the call.
+			input: "fn(f) { try { return f() } catch { 0 } }",
+			expectedConstants: []interface{}{
+				0,
+				[]code.Instructions{
+					code.Make(code.OpTry, 17),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpEndTry),
+					code.Make(code.OpReturnValue),
+					code.Make(code.OpEndTry),
+					code.Make(code.OpJump, 26),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpJump, 26),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a, b = 2) { b }",
+			expectedConstants: []interface{}{
+				2,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpMissing, 1),
+					// 0002
+					code.Make(code.OpJumpNotTruthy, 12),
+					// 0007
+					code.Make(code.OpConstant, 0),
+					// 0010
+					code.Make(code.OpSetLocal, 1),
+					// 0012
+					code.Make(code.OpGetLocal, 1),
+					// 0014
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let f = 0; let xs = 0; f(1, ...xs, 2, 3, b: 4)",
+			expectedConstants: []interface{}{0, 0, 1, 2, 3, "b", 4},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpArray, 3),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpConstant, 6),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpApply),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "try { 1 } catch (e) { e }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 14),
+				// 0005
+				code.Make(code.OpConstant, 0),
+				// 0008
+				code.Make(code.OpEndTry),
+				// 0009
+				code.Make(code.OpJump, 25),
+				// 0014
+				code.Make(code.OpSetGlobal, 0),
 				// 0017
+				code.Make(code.OpGetGlobal, 0),
+				// 0020
+				code.Make(code.OpJump, 25),
+				// 0025
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "try { 1 } finally { 2 }",
+			expectedConstants: []interface{}{1, 2, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 18),
+				// 0005
+				code.Make(code.OpConstant, 0),
+				// 0008
+				code.Make(code.OpEndTry),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpPop),
+				// 0013
+				code.Make(code.OpJump, 23),
+				// 0018
+				code.Make(code.OpConstant, 2),
+				// 0021
+				code.Make(code.OpPop),
+				// 0022
+				code.Make(code.OpThrow),
+				// 0023
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `throw "boom"`,
+			expectedConstants: []interface{}{"boom"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpThrow),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -275,6 +734,435 @@ func TestGlobalLetStatements(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestStringExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `"gengo"`,
+			expectedConstants: []interface{}{"gengo"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"gen" + "go"`,
+			expectedConstants: []interface{}{"gen", "go"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[]",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1, 2 + 3]",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "{}",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{1: 2, 3: 4}",
+			expectedConstants: []interface{}{1, 2, 3, 4},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[1, 2][1]",
+			expectedConstants: []interface{}{1, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMemberExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `{"a": 1}.a`,
+			expectedConstants: []interface{}{"a", 1, "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpGetAttribute),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn() { return 5 + 10 }",
+			expectedConstants: []interface{}{
+				5,
+				10,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { 1; 2 }",
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpNull),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let f = fn(a, b) { let c = a; c + b }; f(1, 2);",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 2),
+					code.Make(code.OpGetLocal, 2),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				2,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestBuiltins(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "len([]); push([], 1);",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetBuiltin, 4),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let countDown = fn(x) { countDown(x - 1); };",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSub),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestWideOperands(t *testing.T) {
+	constants := make([]object.Object, code.MaxUint16+1)
+	compiler := NewWithState(NewSymbolTable(), constants)
+
+	err := compiler.Compile(parse("1"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := []code.Instructions{
+		code.Make(code.OpConstantWide, 65536),
+		code.Make(code.OpPop),
+	}
+
+	err = testInstructions(expected, compiler.Bytecode().Instructions)
+	if err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+
+	symbolTable := NewSymbolTable()
+	for i := 0; i <= code.MaxUint16; i++ {
+		symbolTable.Define(fmt.Sprintf("g%d", i))
+	}
+	compiler = NewWithState(symbolTable, []object.Object{})
+
+	err = compiler.Compile(parse("let a = 1; a; fn() { 2 }"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected = []code.Instructions{
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpSetGlobalWide, 65536),
+		code.Make(code.OpGetGlobalWide, 65536),
+		code.Make(code.OpPop),
+		code.Make(code.OpClosure, 2, 0),
+		code.Make(code.OpPop),
+	}
+
+	err = testInstructions(expected, compiler.Bytecode().Instructions)
+	if err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+}
+
+func TestLongJumps(t *testing.T) {
+	// Each statement is 4 bytes of instructions and a constant, so the body of the
+	// if takes more than 64KiB and the function comes after more than 65536
+	// constants.
+	input := "let x = true; if (x) { " + strings.Repeat("1; ", code.MaxUint16+1) + "} fn() { 2 }"
+
+	compiler := New()
+	err := compiler.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	// The jump to the missing else branch, which is null, follows x.
+	ins := bytecode.Instructions
+	pos := len(code.Make(code.OpTrue)) + len(code.Make(code.OpSetGlobal, 0)) + len(code.Make(code.OpGetGlobal, 0))
+	def, _ := code.Lookup(ins[pos])
+	operands, _ := code.ReadOperands(def, ins[pos+1:])
+	if def.Name != "OpJumpNotTruthy" || operands[0] <= code.MaxUint16 || code.Opcode(ins[operands[0]]) != code.OpNull {
+		t.Errorf("wrong jump past the body of the if. got=%s %v", def.Name, operands)
+	}
+
+	closure := code.Make(code.OpClosure, len(bytecode.Constants)-1, 0)
+	if !bytes.HasSuffix(bytecode.Instructions, append(closure, byte(code.OpPop))) {
+		t.Errorf("function not loaded with its constant index %d", len(bytecode.Constants)-1)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	input := "fn() { if (true) { 1 } else { 2 } }; if (false) { 3 }"
+
+	compiler := New()
+	compiler.SetOptimize(true)
+	err := compiler.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	// Function bodies are optimized too, each with its own jump targets.
+	expectedConstants := []interface{}{
+		1,
+		2,
+		[]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpReturnValue),
+		},
+		3,
+	}
+	expectedInstructions := []code.Instructions{
+		code.Make(code.OpClosure, 2, 0),
+		code.Make(code.OpPop),
+		code.Make(code.OpNull),
+		code.Make(code.OpPop),
+	}
+
+	if err := testInstructions(expectedInstructions, bytecode.Instructions); err != nil {
+		t.Errorf("testInstructions failed: %s", err)
+	}
+	if err := testConstants(t, expectedConstants, bytecode.Constants); err != nil {
+		t.Errorf("testConstants failed: %s", err)
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedErr string
+	}{
+		{"const x = 1; let x = 2;", "cannot reassign constant x"},
+		{"const x = 1; const x = 2;", "cannot reassign constant x"},
+		{`const [a, b] = [1, 2]; let {b} = {"b": 3};`, "cannot reassign constant b"},
+		{"const x = 1; if (true) { let x = 2; }", "cannot reassign constant x"},
+		{"const x = 1; match (2) { x => x }", ""},
+		{"const e = 1; try { 1 } catch (e) { 2 }", ""},
+		{"let f = fn() { const y = 1; if (true) { fn y() { 2 } } };", "cannot reassign constant y"},
+		{"const x = 1; let f = fn() { let x = 2; x };", ""},
+		{"let x = 1; const x = 2;", ""},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		if tt.expectedErr == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected compiler error: %s", tt.input, err)
+			}
+			continue
+		}
+
+		if err == nil || err.Error() != tt.expectedErr {
+			t.Errorf("%q: wrong compiler error. want=%q, got=%v", tt.input, tt.expectedErr, err)
+		}
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedErr string
+	}{
+		{
+			input:       "[" + strings.Repeat("1, ", code.MaxUint16) + "1]",
+			expectedErr: "too many elements in array literal, the limit is 65535",
+		},
+		{
+			input:       "len(" + strings.Repeat("1, ", code.MaxUint8) + "1)",
+			expectedErr: "too many arguments in call, the limit is 255",
+		},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		if err == nil {
+			t.Fatalf("expected compiler error %q, got none", tt.expectedErr)
+		}
+
+		if err.Error() != tt.expectedErr {
+			t.Errorf("wrong compiler error. want=%q, got=%q", tt.expectedErr, err)
+		}
+	}
+}
+
 func runCompilerTests(t *testing.T, tests []compilerTestCase) {
 	t.Helper()
 
@@ -314,6 +1202,30 @@ func testConstants(t *testing.T, expected []interface{}, actual []object.Object)
 			if err != nil {
 				return fmt.Errorf("constant %d - testIntegerObject failed: %s", i, err)
 			}
+		case string:
+			if pattern, ok := actual[i].(*object.Pattern); ok {
+				if pattern.Inspect() != constant {
+					return fmt.Errorf("constant %d - wrong pattern. got=%q, want=%q", i, pattern.Inspect(), constant)
+				}
+				continue
+			}
+			str, ok := actual[i].(*object.String)
+			if !ok {
+				return fmt.Errorf("constant %d - not a String: %T", i, actual[i])
+			}
+			if str.Value != constant {
+				return fmt.Errorf("constant %d - wrong value. got=%q, want=%q", i, str.Value, constant)
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("constant %d - not a function: %T", i, actual[i])
+			}
+
+			err := testInstructions(constant, fn.Instructions)
+			if err != nil {
+				return fmt.Errorf("constant %d - testInstructions failed: %s", i, err)
+			}
 		}
 	}
 
@@ -1,8 +1,16 @@
 package compiler
 
+import "Gengo/object"
+
 type SymbolScope string
 
-const GlobalScope SymbolScope = "GLOBAL"
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
 
 type Symbol struct {
 	Name  string
@@ -11,8 +19,23 @@ type Symbol struct {
 }
 
 type SymbolTable struct {
+	Outer *SymbolTable
+
 	store          map[string]Symbol
 	numDefinitions int
+
+	FreeSymbols []Symbol
+
+	// consts holds the names defined as constants in this table.
+	consts map[string]bool
+
+	// builtins is set if names not defined in the table resolve to the builtins of
+	// registry.
+	builtins bool
+	registry *object.Registry
+
+	// block is set for the table of a block, see NewBlockSymbolTable.
+	block bool
 }
 
 func NewSymbolTable() *SymbolTable {
@@ -20,14 +43,130 @@ func NewSymbolTable() *SymbolTable {
 	return &SymbolTable{store: s}
 }
 
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// NewBlockSymbolTable returns the table for a block nested in outer, like a match
+// arm or a catch block, whose names are only visible inside it. They take the slots
+// of the function, or of the globals, that outer belongs to.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
 func (s *SymbolTable) Define(name string) Symbol {
-	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: GlobalScope}
+	owner := s
+	for owner.block {
+		owner = owner.Outer
+	}
+
+	// Defining a global again reuses its slot, so code compiled before, like a
+	// function declared before the let statement defining a global it uses, sees the
+	// new binding.
+	if existing, ok := s.store[name]; ok && existing.Scope == GlobalScope {
+		return existing
+	}
+
+	symbol := Symbol{Name: name, Index: owner.numDefinitions}
+	if owner.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
 	s.store[name] = symbol
-	s.numDefinitions++
+	owner.numDefinitions++
+	return symbol
+}
+
+// DefineConst defines name as a constant, which IsConst reports can't be defined
+// again in this table.
+func (s *SymbolTable) DefineConst(name string) Symbol {
+	if s.consts == nil {
+		s.consts = make(map[string]bool)
+	}
+	s.consts[name] = true
+	return s.Define(name)
+}
+
+// IsConst reports whether name is a constant defined in this table, rather than in
+// an outer one.
+func (s *SymbolTable) IsConst(name string) bool {
+	return s.consts[name]
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineFunctionName defines the name a function literal is bound to inside its own
+// body, so the function can refer to itself before the binding exists.
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
 	return symbol
 }
 
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := s.store[name]
-	return obj, ok
+	if ok {
+		return obj, ok
+	}
+
+	if s.Outer == nil {
+		return s.resolveBuiltin(name)
+	}
+
+	obj, ok = s.Outer.Resolve(name)
+	if !ok {
+		return obj, ok
+	}
+
+	// A block shares the frame of the function it is in, so it needs no free
+	// variables.
+	if s.block || obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+		return obj, ok
+	}
+
+	return s.defineFree(obj), true
+}
+
+// globalNames returns the names of the globals defined in the table by index.
+func (s *SymbolTable) globalNames() []string {
+	names := make([]string, s.numDefinitions)
+	for name, symbol := range s.store {
+		if symbol.Scope == GlobalScope && symbol.Index < len(names) {
+			names[symbol.Index] = name
+		}
+	}
+	return names
+}
+
+// resolveBuiltin defines name as a builtin the first time it is used, so builtins
+// registered after the table was created resolve too.
+func (s *SymbolTable) resolveBuiltin(name string) (Symbol, bool) {
+	if !s.builtins {
+		return Symbol{}, false
+	}
+
+	index, ok := s.registry.GetBuiltinIndex(name)
+	if !ok {
+		return Symbol{}, false
+	}
+
+	return s.DefineBuiltin(index, name), true
 }
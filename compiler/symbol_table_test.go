@@ -1,6 +1,10 @@
 package compiler
 
-import "testing"
+import (
+	"testing"
+
+	"Gengo/object"
+)
 
 func TestDefine(t *testing.T) {
 	expected := map[string]Symbol{
@@ -19,6 +23,33 @@ func TestDefine(t *testing.T) {
 	if b != expected["b"] {
 		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
 	}
+
+	// A global defined again keeps its slot.
+	if again := global.Define("a"); again != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], again)
+	}
+	if names := global.globalNames(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("wrong global names. got=%v", names)
+	}
+}
+
+func TestDefineConst(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.DefineConst("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("wrong symbol for a. got=%+v", a)
+	}
+	global.Define("b")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("a")
+
+	if !global.IsConst("a") || global.IsConst("b") {
+		t.Errorf("wrong constants in global table")
+	}
+	if local.IsConst("a") {
+		t.Errorf("a shadowed in the local table is a constant")
+	}
 }
 
 func TestResolveGlobal(t *testing.T) {
@@ -42,3 +73,128 @@ func TestResolveGlobal(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveNested(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "b", Scope: FreeScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := secondLocal.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	expectedFree := []Symbol{{Name: "b", Scope: LocalScope, Index: 0}}
+	if len(secondLocal.FreeSymbols) != len(expectedFree) || secondLocal.FreeSymbols[0] != expectedFree[0] {
+		t.Errorf("wrong free symbols. want=%+v, got=%+v", expectedFree, secondLocal.FreeSymbols)
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineConst("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("b")
+
+	block := NewBlockSymbolTable(local)
+	a := block.Define("a")
+	if a != (Symbol{Name: "a", Scope: LocalScope, Index: 1}) {
+		t.Errorf("wrong symbol for a. got=%+v", a)
+	}
+	if block.IsConst("a") {
+		t.Errorf("a shadowed in the block is a constant")
+	}
+
+	b, ok := block.Resolve("b")
+	if !ok || b != (Symbol{Name: "b", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong symbol for b. got=%+v", b)
+	}
+	if len(block.FreeSymbols) != 0 {
+		t.Errorf("block has free symbols: %+v", block.FreeSymbols)
+	}
+
+	if _, ok := local.Resolve("a"); !ok || local.IsConst("a") {
+		t.Errorf("a of the block is visible outside it")
+	}
+	if local.numDefinitions != 2 {
+		t.Errorf("block definitions not counted in the local table. got=%d", local.numDefinitions)
+	}
+
+	globalBlock := NewBlockSymbolTable(global)
+	if c := globalBlock.Define("c"); c != (Symbol{Name: "c", Scope: GlobalScope, Index: 1}) {
+		t.Errorf("wrong symbol for c. got=%+v", c)
+	}
+}
+
+func TestResolveBuiltinsAndFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(3, "rest")
+
+	local := NewEnclosedSymbolTable(global)
+	local.DefineFunctionName("f")
+
+	expected := []Symbol{
+		{Name: "rest", Scope: BuiltinScope, Index: 3},
+		{Name: "f", Scope: FunctionScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := local.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(local.FreeSymbols) != 0 {
+		t.Errorf("builtins should not be free symbols. got=%+v", local.FreeSymbols)
+	}
+}
+
+func TestResolveRegisteredBuiltin(t *testing.T) {
+	global := NewSymbolTableWithBuiltins()
+	local := NewEnclosedSymbolTable(global)
+
+	err := object.RegisterBuiltin("symbolTableTestBuiltin", func(args ...object.Object) object.Object {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	index, _ := object.GetBuiltinIndex("symbolTableTestBuiltin")
+	expected := Symbol{Name: "symbolTableTestBuiltin", Scope: BuiltinScope, Index: index}
+
+	result, ok := local.Resolve("symbolTableTestBuiltin")
+	if !ok {
+		t.Fatalf("registered builtin not resolvable")
+	}
+	if result != expected {
+		t.Errorf("expected %+v, got=%+v", expected, result)
+	}
+
+	if _, ok := NewSymbolTable().Resolve("len"); ok {
+		t.Errorf("a table without builtins should not resolve len")
+	}
+}
@@ -1,96 +0,0 @@
-package evaluator
-
-import "Gengo/object"
-
-var builtins = map[string]*object.Builtin{
-	"len":   {Fn: lenFunc},
-	"first": {Fn: firstFunc},
-	"last":  {Fn: lastFunc},
-	"rest":  {Fn: restFunc},
-	"push":  {Fn: pushFunc},
-}
-
-func lenFunc(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1", len(args))
-	}
-
-	switch arg := args[0].(type) {
-	case *object.String:
-		return &object.Integer{Value: int64(len(arg.Value))}
-	case *object.Array:
-		return &object.Integer{Value: int64(len(arg.Elements))}
-	default:
-		return newError("argument to `len` not supported, got %s", args[0].Type())
-	}
-}
-
-func firstFunc(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1", len(args))
-	}
-	if args[0].Type() != object.ARRAY {
-		return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
-	}
-
-	arr := args[0].(*object.Array)
-	if len(arr.Elements) > 0 {
-		return arr.Elements[0]
-	}
-
-	return NULL
-}
-
-func lastFunc(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1", len(args))
-	}
-	if args[0].Type() != object.ARRAY {
-		return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
-	}
-
-	arr := args[0].(*object.Array)
-	length := len(arr.Elements)
-	if length > 0 {
-		return arr.Elements[length-1]
-	}
-
-	return NULL
-}
-
-func restFunc(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1", len(args))
-	}
-	if args[0].Type() != object.ARRAY {
-		return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
-	}
-
-	arr := args[0].(*object.Array)
-	length := len(arr.Elements)
-	if length > 0 {
-		newElements := make([]object.Object, length-1, length-1)
-		copy(newElements, arr.Elements[1:length])
-		return &object.Array{Elements: newElements}
-	}
-
-	return NULL
-}
-
-func pushFunc(args ...object.Object) object.Object {
-	if len(args) != 2 {
-		return newError("wrong number of arguments. got=%d, want=2", len(args))
-	}
-	if args[0].Type() != object.ARRAY {
-		return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
-	}
-
-	arr := args[0].(*object.Array)
-	length := len(arr.Elements)
-
-	newElements := make([]object.Object, length+1, length+1)
-	copy(newElements, arr.Elements)
-	newElements[length] = args[1]
-
-	return &object.Array{Elements: newElements}
-}
@@ -1,10 +1,14 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"path/filepath"
+	"time"
 
 	"Gengo/ast"
+	"Gengo/module"
 	"Gengo/object"
 )
 
@@ -19,120 +23,531 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// Evaluator walks the AST while keeping track of the resources the evaluation has used.
+type Evaluator struct {
+	limits object.Limits
+	ctx    context.Context
+
+	steps  int
+	depth  int
+	allocs int
+
+	// err is the error that aborted the evaluation, if any.
+	err error
+
+	// loader resolves imports relative to file, the file being evaluated.
+	loader *module.Loader
+	file   string
+	// globals is the environment of the program, which caches the imported modules.
+	globals *object.Environment
+	// importing holds the files being imported, outermost first, to detect cycles.
+	importing []string
+
+	// io is where builtins print to and read from.
+	io *object.IO
+	// random is the source of random numbers of the math module.
+	random *object.Random
+	// registry holds the builtins and the modules implemented in Go.
+	registry *object.Registry
+}
+
+// position is where in the function being called an expression is evaluated.
+type position int
+
+const (
+	// inValue is the position of an expression whose value the expression around
+	// it uses, or that is in a try block. Nothing in it is a tail call.
+	inValue position = iota
+	// inStatement is the position of a statement, or of an expression whose value
+	// is a statement's, outside of try blocks. A return there leaves the function,
+	// so the call it returns is a tail call.
+	inStatement
+	// inTail is the position of an expression whose value is the value of the
+	// function, so a call there is a tail call.
+	inTail
+)
+
+// tailCall is a call in tail position, which callFunction makes once the function
+// making it has returned.
+type tailCall struct {
+	fn    *object.Function
+	args  []object.Object
+	named map[string]object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// skippedChain is the type of chainEnd.
+type skippedChain struct{}
+
+func (sc *skippedChain) Type() object.ObjectType { return "SKIPPED_CHAIN" }
+func (sc *skippedChain) Inspect() string         { return "skipped chain" }
+
+// chainEnd is what an optional member, index or slice expression evaluates to if its
+// left side is null. It skips the rest of the OptionalChain it is in, which is null.
+var chainEnd = &skippedChain{}
+
+// moduleKey prefixes the path of a module to name where it is cached. It can't be
+// the name of a binding.
+const moduleKey = "module:"
+
+// New Creates an Evaluator that enforces limits.
+func New(limits object.Limits) *Evaluator {
+	return &Evaluator{limits: limits}
+}
+
+// SetLoader Sets the loader that resolves imports, and the file the evaluated
+// programs were read from, which imports are relative to. Without a loader, modules
+// are read from the file system without a search path.
+func (e *Evaluator) SetLoader(loader *module.Loader, file string) {
+	e.loader = loader
+	e.file = file
+}
+
+// SetIO Sets where builtins print to and read from. Without it they use the
+// standard output and input of the process.
+func (e *Evaluator) SetIO(io *object.IO) {
+	e.io = io
+}
+
+// SetRandom Sets the source of random numbers of the math module. Without it the
+// Evaluator makes one of its own when it is first needed.
+func (e *Evaluator) SetRandom(random *object.Random) {
+	e.random = random
+}
+
+// SetRegistry Sets the registry of the builtins and modules implemented in Go that
+// programs can use. Without it they use the default registry.
+func (e *Evaluator) SetRegistry(registry *object.Registry) {
+	e.registry = registry
+}
+
 // Eval Evaluate the AST node.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(object.Limits{}).eval(node, env)
+}
+
+// Run Evaluate the AST node. If a limit is exceeded the evaluation is aborted and the
+// limit error is returned.
+func (e *Evaluator) Run(node ast.Node, env *object.Environment) (object.Object, error) {
+	return e.RunContext(context.Background(), node, env)
+}
+
+// RunContext is like Run but aborts with a *object.CancelError once ctx is done.
+// Cancellation is checked at every function call. Bindings are only set once their
+// value has been evaluated, so an aborted evaluation never leaves one half assigned.
+func (e *Evaluator) RunContext(ctx context.Context, node ast.Node, env *object.Environment) (object.Object, error) {
+	e.steps, e.depth, e.allocs, e.err = 0, 0, 0, nil
+	e.ctx = ctx
+	e.globals = env
+	e.importing = nil
+	if e.file != "" {
+		e.importing = []string{filepath.Clean(e.file)}
+	}
+
+	if err := e.checkCanceled(); err != nil {
+		return nil, e.err
+	}
+
+	result := e.eval(node, env)
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return result, nil
+}
+
+// Apply calls fn, a function or a builtin, with args. Like Run, it returns the error
+// that aborted the call if a limit was exceeded.
+func (e *Evaluator) Apply(fn object.Object, args ...object.Object) (object.Object, error) {
+	return e.ApplyContext(context.Background(), fn, args...)
+}
+
+// ApplyContext is like Apply but aborts with a *object.CancelError once ctx is done.
+func (e *Evaluator) ApplyContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	e.steps, e.depth, e.allocs, e.err = 0, 0, 0, nil
+	e.ctx = ctx
+
+	result := e.applyFunction(fn, args)
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return result, nil
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env, inValue)
 
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
+	case *ast.NullLiteral:
+		return NULL
+
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return e.track(&object.String{Value: node.Value})
 
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return e.track(&object.Integer{Value: node.Value})
 
 	case *ast.FloatLiteral:
-		return &object.Float{Value: node.Value}
+		return e.track(&object.Float{Value: node.Value})
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env, inValue)
+
+	case *ast.TernaryExpression:
+		return e.evalTernaryExpression(node, env, inValue)
+
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env, inValue)
+
+	case *ast.MatchExpression:
+		return e.evalMatchExpression(node, env, inValue)
 
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return e.track(&object.Array{Elements: elements})
+
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := e.eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
-		if isError(index) {
+		if node.Optional && isNull(left) {
+			return chainEnd
+		}
+		index := e.eval(node.Index, env)
+		if isAbrupt(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
 
-	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+	case *ast.SliceExpression:
+		left := e.eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		if node.Optional && isNull(left) {
+			return chainEnd
+		}
+		bounds, errObj := e.evalOptional(env, node.Start, node.End)
+		if errObj != nil {
+			return errObj
+		}
+		return e.track(hostResult(object.Slice(left, bounds[0], bounds[1])))
 
-	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+	case *ast.RangeExpression:
+		bounds, errObj := e.evalOptional(env, node.Start, node.End, node.Step)
+		if errObj != nil {
+			return errObj
+		}
+		r, err := object.NewRange(bounds[0], bounds[1], bounds[2], node.Inclusive)
+		if err != nil {
+			return newError("%s", err)
 		}
+		return e.track(r)
 
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+	case *ast.MemberExpression:
+		obj := e.eval(node.Object, env)
+		if isAbrupt(obj) {
+			return obj
+		}
+		if node.Optional && isNull(obj) {
+			return chainEnd
 		}
+		return e.evalMemberExpression(obj, node.Property.Value)
 
-		return applyFunction(function, args)
+	case *ast.OptionalChain:
+		result := e.eval(node.Expression, env)
+		if result == chainEnd {
+			return NULL
+		}
+		return result
+
+	case *ast.FunctionLiteral:
+		return e.evalFunctionLiteral(node, "", env)
+
+	case *ast.CallExpression:
+		return e.evalCallExpression(node, env, false)
 
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		if node.Pattern != nil {
+			val := e.eval(node.Value, env)
+			if isAbrupt(val) {
+				return val
+			}
+			if errObj := destructure(node.Pattern, val, env, node.IsConst()); errObj != nil {
+				return errObj
+			}
+			return NULL
+		}
+
+		var val object.Object
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+			val = e.evalFunctionLiteral(fn, node.Name.Value, env)
+		} else {
+			val = e.eval(node.Value, env)
+		}
+		if isAbrupt(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if errObj := bind(env, node.Name.Value, val, node.IsConst()); errObj != nil {
+			return errObj
+		}
 		return NULL
 
+	case *ast.FunctionDeclaration:
+		// The function was bound when its block started.
+		return NULL
+
+	case *ast.ImportStatement:
+		mod := e.importModule(node.Path.Value)
+		if isAbrupt(mod) {
+			return mod
+		}
+		if errObj := bind(env, node.Name.Value, mod, false); errObj != nil {
+			return errObj
+		}
+		return NULL
+
+	case *ast.ExportStatement:
+		return e.eval(node.Statement, env)
+
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		return e.evalReturnStatement(node, env, inValue)
+
+	case *ast.ThrowStatement:
+		val := e.eval(node.Value, env)
+		if isAbrupt(val) {
 			return val
 		}
-		return &object.ReturnValue{Value: val}
+		return object.Throw(val)
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.track(evalPrefixExpression(node.Operator, right))
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		if node.Operator == "??" {
+			return e.evalCoalesceExpression(node, env, inValue)
+		}
+
+		left := e.eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
 
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return e.track(evalInfixExpression(node.Operator, left, right))
 
 	default:
 		return NULL
 	}
 }
 
+// evalAt evaluates node at pos. A call in tail position is returned as a tailCall
+// rather than made, so tail recursive functions run in constant stack. Only
+// callFunction makes tail calls, and only the functions it calls have tail
+// positions, so a tailCall never gets past it.
+func (e *Evaluator) evalAt(node ast.Node, env *object.Environment, pos position) object.Object {
+	if pos == inValue {
+		return e.eval(node, env)
+	}
+
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		if err := e.step(); err != nil {
+			return err
+		}
+		return e.evalAt(node.Expression, env, pos)
+	case *ast.ReturnStatement:
+		if err := e.step(); err != nil {
+			return err
+		}
+		return e.evalReturnStatement(node, env, pos)
+	case *ast.BlockStatement:
+		if err := e.step(); err != nil {
+			return err
+		}
+		return e.evalBlockStatement(node, env, pos)
+	case *ast.IfExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+		return e.evalIfExpression(node, env, pos)
+	case *ast.TernaryExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+		return e.evalTernaryExpression(node, env, pos)
+	case *ast.InfixExpression:
+		if node.Operator != "??" {
+			return e.eval(node, env)
+		}
+		if err := e.step(); err != nil {
+			return err
+		}
+		return e.evalCoalesceExpression(node, env, pos)
+	case *ast.TryExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+		return e.evalTryExpression(node, env, pos)
+	case *ast.MatchExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+		return e.evalMatchExpression(node, env, pos)
+	case *ast.CallExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+		return e.evalCallExpression(node, env, pos == inTail)
+	default:
+		return e.eval(node, env)
+	}
+}
+
+// evalReturnStatement evaluates the returned value, in tail position unless the
+// return is at pos inValue.
+func (e *Evaluator) evalReturnStatement(node *ast.ReturnStatement, env *object.Environment, pos position) object.Object {
+	if pos != inValue {
+		pos = inTail
+	}
+
+	val := e.evalAt(node.ReturnValue, env, pos)
+	if isAbrupt(val) {
+		return val
+	}
+	return &object.ReturnValue{Value: val}
+}
+
+// evalCallExpression calls the function the call evaluates to. In tail position,
+// a function defined in Gengo is returned as a tailCall for callFunction to call.
+func (e *Evaluator) evalCallExpression(node *ast.CallExpression, env *object.Environment, tail bool) object.Object {
+	function := e.eval(node.Function, env)
+	if isAbrupt(function) {
+		return function
+	}
+
+	args, named, errObj := e.evalArguments(node.Arguments, env)
+	if errObj != nil {
+		return errObj
+	}
+
+	if fn, ok := function.(*object.Function); ok && tail {
+		return &tailCall{fn: fn, args: args, named: named}
+	}
+	return e.callFunction(function, args, named)
+}
+
+// checkCanceled aborts the evaluation if its context is done.
+func (e *Evaluator) checkCanceled() *object.Error {
+	if e.ctx == nil {
+		return nil
+	}
+
+	select {
+	case <-e.ctx.Done():
+		return e.abort(&object.CancelError{Err: e.ctx.Err()})
+	default:
+		return nil
+	}
+}
+
+// step counts an evaluation step against the instruction limit.
+func (e *Evaluator) step() *object.Error {
+	if e.err != nil {
+		return newError("%s", e.err)
+	}
+
+	e.steps++
+	if e.limits.MaxInstructions > 0 && e.steps > e.limits.MaxInstructions {
+		return e.abort(&object.InstructionLimitError{Limit: e.limits.MaxInstructions})
+	}
+
+	return nil
+}
+
+// track counts obj against the allocation limit. The shared NULL, TRUE and FALSE
+// objects and errors aren't allocations, so they are returned as is.
+func (e *Evaluator) track(obj object.Object) object.Object {
+	if obj == NULL || obj == TRUE || obj == FALSE || isError(obj) {
+		return obj
+	}
+
+	if err := e.allocate(1); err != nil {
+		return err
+	}
+
+	return obj
+}
+
+// allocate counts n allocations against the allocation limit.
+func (e *Evaluator) allocate(n int) *object.Error {
+	e.allocs += n
+	if e.limits.MaxAllocations > 0 && e.allocs > e.limits.MaxAllocations {
+		return e.abort(&object.AllocationLimitError{Limit: e.limits.MaxAllocations})
+	}
+
+	return nil
+}
+
+// abort stops the evaluation with err. The returned error object unwinds the
+// evaluation like any other error, and Run reports err to the caller.
+func (e *Evaluator) abort(err error) *object.Error {
+	e.err = err
+	return newError("%s", err)
+}
+
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH:
+		return evalHashIndexExpression(left, index)
 	default:
+		if indexable, ok := left.(object.Indexable); ok {
+			return hostResult(indexable.Index(index))
+		}
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
+func (e *Evaluator) evalMemberExpression(obj object.Object, name string) object.Object {
+	return hostResult(e.registry.GetAttribute(obj, name))
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
@@ -145,12 +560,53 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for keyNode, valueNode := range node.Pairs {
+		key := e.eval(keyNode, env)
+		if isAbrupt(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := e.eval(valueNode, env)
+		if isAbrupt(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return e.track(&object.Hash{Pairs: pairs})
+}
+
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 
@@ -160,11 +616,56 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+// evalArguments evaluates the arguments of a call, expanding spread arrays and
+// collecting named arguments.
+func (e *Evaluator) evalArguments(exps []ast.Expression, env *object.Environment) ([]object.Object, map[string]object.Object, object.Object) {
+	var args []object.Object
+	var named map[string]object.Object
+
+	for _, exp := range exps {
+		switch exp := exp.(type) {
+		case *ast.SpreadExpression:
+			evaluated := e.eval(exp.Value, env)
+			if isAbrupt(evaluated) {
+				return nil, nil, evaluated
+			}
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				return nil, nil, newError("spread argument must be ARRAY, got %s", evaluated.Type())
+			}
+			args = append(args, array.Elements...)
+
+		case *ast.NamedArgument:
+			evaluated := e.eval(exp.Value, env)
+			if isAbrupt(evaluated) {
+				return nil, nil, evaluated
+			}
+			if named == nil {
+				named = make(map[string]object.Object)
+			}
+			named[exp.Name.Value] = evaluated
+
+		default:
+			evaluated := e.eval(exp, env)
+			if isAbrupt(evaluated) {
+				return nil, nil, evaluated
+			}
+			args = append(args, evaluated)
+		}
+	}
+
+	return args, named, nil
+}
+
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
+	if errObj := e.hoistFunctions(program.Statements, env); errObj != nil {
+		return errObj
+	}
+
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -177,11 +678,26 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+// evalBlockStatement evaluates the statements of block at pos. The statements
+// before the last one are at inStatement unless pos is inValue.
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment, pos position) object.Object {
 	var result object.Object
 
-	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+	if errObj := e.hoistFunctions(block.Statements, env); errObj != nil {
+		return errObj
+	}
+
+	for i, statement := range block.Statements {
+		switch statement.(type) {
+		case *ast.ImportStatement, *ast.ExportStatement:
+			return newError("%s is only allowed at the top level", statement.TokenLiteral())
+		}
+
+		if i == len(block.Statements)-1 || pos == inValue {
+			result = e.evalAt(statement, env, pos)
+		} else {
+			result = e.evalAt(statement, env, inStatement)
+		}
 
 		if result != nil {
 			rt := result.Type()
@@ -194,6 +710,99 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+// hoistFunctions binds the functions declared among statements, before the
+// statements run, so they can be called before they are declared and call each
+// other.
+func (e *Evaluator) hoistFunctions(statements []ast.Statement, env *object.Environment) object.Object {
+	// The functions are bound before the const statements run, so a function can't
+	// have the name of one of those constants.
+	constants := make(map[string]bool)
+	for _, statement := range statements {
+		if let, ok := ast.Declaration(statement).(*ast.LetStatement); ok && let.IsConst() {
+			for _, name := range let.Names() {
+				constants[name.Value] = true
+			}
+		}
+	}
+
+	for _, statement := range statements {
+		if decl, ok := ast.Declaration(statement).(*ast.FunctionDeclaration); ok {
+			if constants[decl.Name.Value] {
+				return newError("cannot reassign constant %s", decl.Name.Value)
+			}
+			fn := e.evalFunctionLiteral(decl.Function, decl.Name.Value, env)
+			if isAbrupt(fn) {
+				return fn
+			}
+			if errObj := bind(env, decl.Name.Value, fn, false); errObj != nil {
+				return errObj
+			}
+		}
+	}
+	return nil
+}
+
+// importModule Evaluates the module path, imported by the file being evaluated, in
+// an environment of its own. Each module is evaluated once per program; importing it
+// again returns the same module. Modules registered in Go are returned as they are.
+func (e *Evaluator) importModule(path string) object.Object {
+	if mod, ok := e.registry.GetModule(path); ok {
+		return mod
+	}
+
+	loader := e.loader
+	if loader == nil {
+		loader = module.NewLoader()
+	}
+
+	// Like the compiler, which fails before the program runs, a module that can't be
+	// loaded can't be caught. Its errors may quote the file, which the program
+	// mustn't see.
+	source, err := loader.Load(e.file, path)
+	if err != nil {
+		return e.abort(err)
+	}
+
+	key := moduleKey + source.Path
+	if e.globals != nil {
+		if mod, ok := e.globals.Get(key); ok {
+			return mod
+		}
+	}
+	if err := module.Cycle(e.importing, source.Path); err != nil {
+		return newError("%s", err)
+	}
+
+	file := e.file
+	e.file = source.Path
+	e.importing = append(e.importing, source.Path)
+	env := object.NewEnvironment()
+	result := e.evalProgram(source.Program, env)
+	e.importing = e.importing[:len(e.importing)-1]
+	e.file = file
+
+	if errObj, ok := result.(*object.Error); ok {
+		if e.err == nil {
+			errObj.Stack = append(errObj.Stack, source.Path)
+		}
+		return errObj
+	}
+
+	if err := e.allocate(1); err != nil {
+		return err
+	}
+
+	mod := &object.Module{Path: source.Path, Exports: make(map[string]object.Object)}
+	for _, name := range module.Exports(source.Program) {
+		mod.Exports[name], _ = env.Get(name)
+	}
+	if e.globals != nil {
+		e.globals.Set(key, mod)
+	}
+
+	return mod
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -202,6 +811,10 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 }
 
 func evalInfixExpression(op string, left, right object.Object) object.Object {
+	if result, ok := evalHostInfixExpression(op, left, right); ok {
+		return result
+	}
+
 	// The use of a switch statement is purely to make adding new conditions easier.
 	switch {
 	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
@@ -211,9 +824,9 @@ func evalInfixExpression(op string, left, right object.Object) object.Object {
 	case left.Type() == object.STRING && right.Type() == object.STRING:
 		return evalStringInfixExpression(op, left, right)
 	case op == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
 	case op == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), op, right.Type())
 	default:
@@ -221,6 +834,53 @@ func evalInfixExpression(op string, left, right object.Object) object.Object {
 	}
 }
 
+// evalHostInfixExpression dispatches to the Arithmetic and Comparable interfaces of
+// host defined objects. It reports false if neither operand handles op.
+func evalHostInfixExpression(op string, left, right object.Object) (object.Object, bool) {
+	switch op {
+	case "+", "-", "*", "/", "**":
+		if arithmetic, ok := left.(object.Arithmetic); ok {
+			return hostResult(arithmetic.BinaryOperation(op, right)), true
+		}
+	case "==", "!=", "<", ">":
+		result, ok, err := object.Compare(left, right)
+		if !ok {
+			return nil, false
+		}
+		if err != nil {
+			return newError("%s", err), true
+		}
+
+		switch op {
+		case "==":
+			return nativeBoolToBooleanObject(result == 0), true
+		case "!=":
+			return nativeBoolToBooleanObject(result != 0), true
+		case "<":
+			return nativeBoolToBooleanObject(result < 0), true
+		default:
+			return nativeBoolToBooleanObject(result > 0), true
+		}
+	}
+
+	return nil, false
+}
+
+// objectsEqual compares booleans and nulls by value, so those created outside the
+// evaluator are equal to its own, and everything else by identity.
+func objectsEqual(left, right object.Object) bool {
+	switch left := left.(type) {
+	case *object.Boolean:
+		right, ok := right.(*object.Boolean)
+		return ok && left.Value == right.Value
+	case *object.Null:
+		_, ok := right.(*object.Null)
+		return ok
+	default:
+		return left == right
+	}
+}
+
 func evalStringInfixExpression(op string, left object.Object, right object.Object) object.Object {
 	if op != "+" {
 		return newError("unknown operator: %s %s %s", left.Type(), op, right.Type())
@@ -243,16 +903,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		return FALSE
-	}
+	return nativeBoolToBooleanObject(!isTruthy(right))
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
@@ -282,6 +933,9 @@ func evalIntegerInfixExpression(op string, left, right object.Object) object.Obj
 	case "**":
 		return &object.Integer{Value: int64(math.Pow(float64(leftVal), float64(rightVal)))}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -324,54 +978,313 @@ func evalFloatInfixExpression(op string, left, right object.Object) object.Objec
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+// evalIfExpression evaluates the branch the condition picks at pos.
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment, pos position) object.Object {
+	condition := e.eval(ie.Condition, env)
 
-	if isError(condition) {
+	if isAbrupt(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.evalAt(ie.Consequence, env, pos)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.evalAt(ie.Alternative, env, pos)
 	} else {
 		return NULL
 	}
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	// FIXME(aaron): null shouldn't be an identifier
-	if node.Value == "null" {
+// evalOptional evaluates each of exps, giving nil for those that are nil, which
+// the parser leaves for the parts of an expression that were left out.
+func (e *Evaluator) evalOptional(env *object.Environment, exps ...ast.Expression) ([]object.Object, *object.Error) {
+	result := make([]object.Object, len(exps))
+
+	for i, exp := range exps {
+		if exp == nil {
+			continue
+		}
+		evaluated := e.eval(exp, env)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			return nil, errObj
+		}
+		result[i] = evaluated
+	}
+
+	return result, nil
+}
+
+// evalTernaryExpression evaluates the expression the condition picks at pos.
+func (e *Evaluator) evalTernaryExpression(te *ast.TernaryExpression, env *object.Environment, pos position) object.Object {
+	condition := e.eval(te.Condition, env)
+	if isAbrupt(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return e.evalAt(te.Consequence, env, pos)
+	}
+	return e.evalAt(te.Alternative, env, pos)
+}
+
+// evalCoalesceExpression evaluates left ?? right to left, or to right at pos if left
+// is null. right is only evaluated then.
+func (e *Evaluator) evalCoalesceExpression(ie *ast.InfixExpression, env *object.Environment, pos position) object.Object {
+	left := e.eval(ie.Left, env)
+	if isAbrupt(left) || !isNull(left) {
+		return left
+	}
+
+	return e.evalAt(ie.Right, env, pos)
+}
+
+// evalTryExpression Evaluates the try block, and the catch block if the try block
+// fails, to the value of the last one evaluated. The finally block is evaluated
+// last in any case, and only its own error or return replaces that value. Limit and
+// cancel errors are never caught. Only a catch block without a finally block is at
+// pos, since the try expression is done with it.
+func (e *Evaluator) evalTryExpression(te *ast.TryExpression, env *object.Environment, pos position) object.Object {
+	result := e.eval(te.Block, env)
+	if e.err != nil {
+		return result
+	}
+
+	if errObj, ok := result.(*object.Error); ok && te.Catch != nil {
+		exception := e.track(object.Catch(errObj))
+		if isAbrupt(exception) {
+			return exception
+		}
+		// The parameter is only bound in the catch block.
+		catchEnv := object.NewEnclosedEnvironment(env)
+		if te.Parameter != nil {
+			if errObj := bind(catchEnv, te.Parameter.Value, exception, false); errObj != nil {
+				return errObj
+			}
+		}
+
+		if te.Finally != nil {
+			pos = inValue
+		}
+		result = e.evalAt(te.Catch, catchEnv, pos)
+		if e.err != nil {
+			return result
+		}
+	}
+
+	if te.Finally != nil {
+		finally := e.eval(te.Finally, env)
+		if finally != nil {
+			if rt := finally.Type(); rt == object.RETURN_VALUE || rt == object.ERROR {
+				return finally
+			}
+		}
+	}
+
+	if result == nil {
 		return NULL
 	}
+	return result
+}
+
+// evalMatchExpression Evaluates the body of the first arm whose pattern matches the
+// value and whose guard, if any, is truthy, or null if there is none. The
+// identifiers of a matching pattern are bound before its guard is evaluated. The
+// body is at pos.
+func (e *Evaluator) evalMatchExpression(me *ast.MatchExpression, env *object.Environment, pos position) object.Object {
+	value := e.eval(me.Value, env)
+	if isAbrupt(value) {
+		return value
+	}
+
+	for _, arm := range me.Arms {
+		bound, ok := object.Match(arm.Pattern, value)
+		if !ok {
+			continue
+		}
+
+		// Each arm binds its names in an environment of its own.
+		armEnv := object.NewEnclosedEnvironment(env)
+		for i, ident := range ast.Bindings(arm.Pattern) {
+			if errObj := bind(armEnv, ident.Value, bound[i], false); errObj != nil {
+				return errObj
+			}
+		}
 
+		if arm.Guard != nil {
+			guard := e.eval(arm.Guard, armEnv)
+			if isAbrupt(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		result := e.evalAt(arm.Body, armEnv, pos)
+		if result == nil {
+			return NULL
+		}
+		return result
+	}
+
+	return NULL
+}
+
+func (e *Evaluator) evalFunctionLiteral(fl *ast.FunctionLiteral, name string, env *object.Environment) object.Object {
+	return e.track(&object.Function{
+		Name:       name,
+		Parameters: fl.Parameters,
+		Defaults:   fl.Defaults,
+		Rest:       fl.Rest,
+		Env:        env,
+		Body:       fl.Body,
+	})
+}
+
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin := e.registry.GetBuiltinByName(node.Value); builtin != nil {
 		return builtin
 	}
 
 	return newError("identifier not found: %s", node.Value)
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
+	return e.callFunction(fn, args, nil)
+}
+
+// callFunction calls fn with args and the named arguments, which only functions
+// defined in Gengo take. The calls functions make in tail position are made here,
+// one after the other, once the function making each has returned.
+func (e *Evaluator) callFunction(fn object.Object, args []object.Object, named map[string]object.Object) object.Object {
+	for {
+		result := e.invoke(fn, args, named)
+		tc, ok := result.(*tailCall)
+		if !ok {
+			return result
+		}
+		fn, args, named = tc.fn, tc.args, tc.named
+	}
+}
+
+// invoke makes a single call for callFunction, returning the call its function makes
+// in tail position, if any.
+func (e *Evaluator) invoke(fn object.Object, args []object.Object, named map[string]object.Object) object.Object {
+	if _, ok := fn.(*object.Function); !ok && len(named) > 0 {
+		return newError("named arguments are not supported by %s", fn.Type())
+	}
+
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		if err := e.checkCanceled(); err != nil {
+			return err
+		}
+
+		if len(args) != len(fn.Parameters) || fn.Rest != nil || named != nil {
+			sig := object.NewSignature(fn.Parameters, fn.Defaults, fn.Rest)
+			arranged, err := sig.Arrange(args, named)
+			if err != nil {
+				return newError("%s", err)
+			}
+			args = arranged
+		}
+
+		e.depth++
+		defer func() { e.depth-- }()
+
+		if limit := e.limits.CallDepth(); e.depth > limit {
+			return e.abort(&object.CallDepthError{Limit: limit})
+		}
+
+		// The function's environment counts as an allocation.
+		if err := e.allocate(1); err != nil {
+			return err
+		}
+
+		extendedEnv, errObj := e.extendFunctionEnv(fn, args)
+		if errObj != nil {
+			if e.err == nil {
+				errObj.Stack = append(errObj.Stack, object.FrameName(fn.Name))
+			}
+			return errObj
+		}
+
+		evaluated := e.evalAt(fn.Body, extendedEnv, inTail)
+		if errObj, ok := evaluated.(*object.Error); ok && e.err == nil {
+			errObj.Stack = append(errObj.Stack, object.FrameName(fn.Name))
+		}
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if result := fn.Invoke(runtime{e}, args...); result != nil {
+			return e.track(result)
+		}
+		return NULL
+
+	case object.Callable:
+		return e.track(hostResult(fn.Call(args...)))
 
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// runtime lets builtins call functions while the Evaluator is evaluating.
+type runtime struct {
+	e *Evaluator
+}
+
+// Call calls fn with args. Errors are returned as Go errors, and a limit or cancel
+// error also aborts the evaluation once the builtin returns.
+func (r runtime) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	result := r.e.applyFunction(fn, args)
+	if r.e.err != nil {
+		return nil, r.e.err
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	return result, nil
+}
+
+// IO returns the IO of the Evaluator.
+func (r runtime) IO() *object.IO {
+	return r.e.io
+}
+
+// Allocate counts n allocations made by a builtin against the allocation limit. If
+// that exceeds it, the evaluation is aborted once the builtin returns.
+func (r runtime) Allocate(n int) error {
+	if r.e.allocate(n) != nil {
+		return r.e.err
+	}
+	return nil
+}
+
+// Random returns the source of random numbers of the Evaluator.
+func (r runtime) Random() *object.Random {
+	if r.e.random == nil {
+		r.e.random = object.NewRandom(time.Now().UnixNano())
+	}
+	return r.e.random
+}
+
+// hostResult converts the result of a method of a host defined object, turning an
+// error into an error object and no value into null.
+func hostResult(obj object.Object, err error) object.Object {
+	if err != nil {
+		return newError("%s", err)
+	}
+	if obj == nil {
+		return NULL
+	}
+	return obj
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
@@ -380,23 +1293,72 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds the parameters of fn to the arguments arranged for them.
+// Default values are evaluated in the new environment, after the parameters before
+// them are bound.
+func (e *Evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for i, param := range fn.Parameters {
-		env.Set(param.Value, args[i])
+		arg := args[i]
+		if arg == nil {
+			arg = e.eval(fn.Defaults[i], env)
+			if errObj, ok := arg.(*object.Error); ok {
+				return nil, errObj
+			}
+		}
+		if errObj := destructure(param, arg, env, false); errObj != nil {
+			return nil, errObj
+		}
+	}
+
+	if fn.Rest != nil {
+		env.Set(fn.Rest.Value, args[len(fn.Parameters)])
+	}
+
+	return env, nil
+}
+
+// destructure binds the identifiers of pattern to the parts of value they match, as
+// constants if constant is set.
+func destructure(pattern ast.Pattern, value object.Object, env *object.Environment, constant bool) *object.Error {
+	if ident, ok := pattern.(*ast.Identifier); ok {
+		return bind(env, ident.Value, value, constant)
 	}
 
-	return env
+	bound, errObj := object.Destructure(pattern, value)
+	if errObj != nil {
+		return errObj
+	}
+	for i, ident := range ast.Bindings(pattern) {
+		if errObj := bind(env, ident.Value, bound[i], constant); errObj != nil {
+			return errObj
+		}
+	}
+	return nil
+}
+
+// bind binds name to val in env, as a constant if constant is set. A constant can't
+// be bound again in the environment it was bound in.
+func bind(env *object.Environment, name string, val object.Object, constant bool) *object.Error {
+	if env.IsConst(name) {
+		return newError("cannot reassign constant %s", name)
+	}
+	if constant {
+		env.SetConst(name, val)
+	} else {
+		env.Set(name, val)
+	}
+	return nil
 }
 
+// isTruthy checks the type rather than comparing with TRUE, FALSE and NULL, so
+// booleans and nulls created outside the evaluator are handled too.
 func isTruthy(obj object.Object) bool {
-	switch obj {
-	case NULL:
-		return false
-	case TRUE:
-		return true
-	case FALSE:
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
 		return false
 	default:
 		return true
@@ -407,9 +1369,26 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// isNull checks the type rather than comparing with NULL, like isTruthy.
+func isNull(obj object.Object) bool {
+	_, ok := obj.(*object.Null)
+	return ok
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR
 	}
 	return false
 }
+
+// isAbrupt reports whether obj is an error, a return value or chainEnd, which end
+// the evaluation of the expressions around them until a try expression, the function
+// call or the optional chain they leave.
+func isAbrupt(obj object.Object) bool {
+	if obj != nil {
+		t := obj.Type()
+		return t == object.ERROR || t == object.RETURN_VALUE || obj == chainEnd
+	}
+	return false
+}
@@ -1,7 +1,12 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"Gengo/lexer"
 	"Gengo/object"
@@ -171,6 +176,19 @@ func TestErrorHandling(t *testing.T) {
 		{"if (10 > 1) { if (10 > 1) { return true + false; } } return 1;", "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foobar", "identifier not found: foobar"},
 		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{"fn(a) { a }(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"fn(a, b) { a }(1)", "wrong number of arguments: want=2, got=1"},
+		{"fn(a, b = 1) { a }()", "wrong number of arguments: want=1 to 2, got=0"},
+		{"fn(a, ...b) { a }()", "wrong number of arguments: want>=1, got=0"},
+		{"fn(a) { a }(b: 1)", "no parameter named b"},
+		{"fn(a) { a }(1, a: 2)", "multiple values for parameter a"},
+		{"fn(a, b = 1) { a }(b: 2)", "missing argument for parameter a"},
+		{"fn(a, b = foobar) { a }(1)", "identifier not found: foobar"},
+		{"fn(a) { a }(...1)", "spread argument must be ARRAY, got INTEGER"},
+		{"len(x: 1)", "named arguments are not supported by BUILTIN"},
+		{`throw "boom"`, "boom"},
+		{"try { throw 1 } finally { 2 }", "1"},
+		{"try { throw 1 } catch { foobar }", "identifier not found: foobar"},
 	}
 
 	for _, tt := range tests {
@@ -188,6 +206,225 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fn add(a, b) { a + b } add(1, 2)", 3},
+		{"let x = double(2); fn double(n) { n * 2 } x", 4},
+		{"fn fact(n) { if (n == 0) { 1 } else { n * fact(n - 1) } } fact(5)", 120},
+		{"fn even(n) { if (n == 0) { 1 } else { odd(n - 1) } } fn odd(n) { if (n == 0) { 0 } else { even(n - 1) } } even(10)", 1},
+		{"let f = fn() { let a = g(); fn g() { 7 } a }; f()", 7},
+		{"if (true) { let r = h(); fn h() { 3 } r }", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	fn, ok := testEval("fn add(a, b = 1) { a + b } add").(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function")
+	}
+	if fn.Name != "add" {
+		t.Errorf("wrong name. want=add, got=%q", fn.Name)
+	}
+	if !strings.HasPrefix(fn.Inspect(), "fn add(a, b = 1) {") {
+		t.Errorf("wrong inspect. got=%q", fn.Inspect())
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const x = 5; x * 2", 10},
+		{"const [a, b] = [1, 2]; a + b", 3},
+		{"const x = 1; let f = fn() { let x = 2; x }; f() + x", 3},
+		{"const x = 1; let f = fn(x) { x }; f(2)", 2},
+		{"let x = 1; const x = 2; x", 2},
+		{"const x = 1; let x = 2;", "cannot reassign constant x"},
+		{"const x = 1; const x = 2;", "cannot reassign constant x"},
+		{`const [a, b] = [1, 2]; let {b} = {"b": 3};`, "cannot reassign constant b"},
+		{"const x = 1; if (true) { let x = 2; }", "cannot reassign constant x"},
+		{"const x = 1; match (2) { x => x }", 2},
+		{"const e = 1; try { throw 2 } catch (e) { e.value }", 2},
+		{"const y = 1; if (true) { fn y() { 2 } }", "cannot reassign constant y"},
+		// Unlike the compiler, the evaluator only rejects bindings it runs.
+		{"const x = 1; if (false) { let x = 2; } x", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let sum = fn(xs, acc) { if (len(xs) == 0) { acc } else { sum(rest(xs), acc + first(xs)) } }; sum(range(100000), 0)", 4999950000},
+		{"let count = fn(n) { if (n == 0) { return 0 } return count(n - 1) }; count(100000)", 0},
+		{"fn even(n) { if (n == 0) { 1 } else { odd(n - 1) } } fn odd(n) { if (n == 0) { 0 } else { even(n - 1) } } even(100001)", 0},
+		{"let f = fn(n) { match (n) { 0 => 7, _ => f(n - 1) } }; f(100000)", 7},
+		{"let f = fn(n) { try { throw n } catch (e) { if (e.value == 0) { 7 } else { f(e.value - 1) } } }; f(100000)", 7},
+		{"let f = fn(n, acc) { if (n == 0) { acc } else { f(...[n - 1], acc: acc + 1) } }; f(100000, 0)", 100000},
+		{"let f = fn(n) { n == 0 ? 7 : f(n - 1) }; f(100000)", 7},
+		{"let f = fn(n) { if (n == 0) { 7 } else { null ?? f(n - 1) } }; f(100000)", 7},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn(a, b = 2) { a * b }; f(3)", 6},
+		{"let f = fn(a, b = 2) { a * b }; f(3, 3)", 9},
+		{"let f = fn(a, b = a + 1) { a * b }; f(3)", 12},
+		{"let f = fn(a, ...rest) { a + len(rest) }; f(1, 2, 3)", 3},
+		{"let f = fn(...rest) { len(rest) }; f()", 0},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(...[1, 2], 3)", 123},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(1, ...[], ...[2, 3])", 123},
+		{"let f = fn(a, b = 0, c = 0) { a * 100 + b * 10 + c }; f(1, c: 3)", 103},
+		{"let f = fn(a, b) { a - b }; f(b: 1, a: 5)", 4},
+		{"let f = fn([a, b] = [1, 2], c = a + b) { c }; f()", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, ...rest] = [1, 2, 3]; len(rest)", 2},
+		{`let {x, "y": [y, _]} = {"x": 1, "y": [2, 3]}; x * 10 + y`, 12},
+		{"let f = fn([a, b], c) { a + b + c }; f([1, 2], 3)", 6},
+		{"let f = fn(n) { fn([a, b]) { a + b + n } }; f(1)([2, 3])", 6},
+		{"let [a, b] = [1];", "cannot destructure ARRAY with [a, b]: want length 2, got 1"},
+		{"let [a, [b]] = [1, [2, 3]];", "cannot destructure ARRAY with [a, [b]]: element 1: want length 1, got 2"},
+		{`let {name} = {"age": 1};`, `cannot destructure HASH with {name}: missing key "name"`},
+		{`let f = fn({name}) { name }; try { f([]) } catch (e) { e.stack[0] }`, "f"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match (1) { 1 => 10, _ => 20 }", 10},
+		{"match (2) { 1 => 10, _ => 20 }", 20},
+		{"match (3) { 1 => 10 }", nil},
+		{"match (-1.5) { -1.5 => 1, _ => 2 }", 1},
+		{`match ("a") { "b" => "x", "a" => "y" }`, "y"},
+		{"match ([1, 2, 3]) { [a, ...r] => len(r) }", 2},
+		{"match ([1, 2]) { [a] => a, [a, b] => a + b }", 3},
+		{"match ([1]) { [a, b] => a }", nil},
+		{`match ({"x": 1, "y": 2}) { {"x": x, "y": y} => x * 10 + y }`, 12},
+		{`match ({"x": 1}) { {"y": y} => y, _ => 0 }`, 0},
+		{"match (5) { n if n > 10 => 1, n if n > 3 => 2, _ => 3 }", 2},
+		{"let f = fn(x) { match (x) { 0 => { return 1 }, _ => 2 }; 3 }; f(0) + f(1)", 4},
+		{"match (1) { 1 => 1 + true, _ => 2 }", "type mismatch: INTEGER + BOOLEAN"},
+		{"match (5) { y => y }; y", "identifier not found: y"},
+		{"let x = 1; match (5) { x if false => 0, _ => x }", 1},
+		{"let x = 1; match (5) { x => x }; x", 1},
+		{"const x = 1; match (2) { x => x }", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { 1 } catch { 2 }", 1},
+		{"try { foobar } catch (e) { e.message }", "identifier not found: foobar"},
+		{"try { 1 + true } catch (e) { e.value }", "type mismatch: INTEGER + BOOLEAN"},
+		{"try { throw 3 } catch (e) { e.value }", 3},
+		{"let f = fn() { throw 1 }; try { f() } catch (e) { e.stack[0] }", "f"},
+		{"let f = fn() { try { return 1 } finally { 2 } }; f()", 1},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let f = fn() { try { throw 1 } finally { 2 } }; try { f() } catch (e) { e.value }", 1},
+		{"try { try { throw 1 } catch (e) { throw e.value + 1 } } catch (e) { e.value }", 2},
+		{"try { 1 } finally { }", 1},
+		{"try { } catch { 1 }", nil},
+		{"try { 1 / 0 } catch (e) { e.message }", "division by zero"},
+		{"let e = 1; try { throw 2 } catch (e) { 3 }; e", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -308,6 +545,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("Hello World")`, 11},
+		{`len("héllo wörld")`, 11},
 		{`let a = [1, 2, 3]; len(a);`, 3},
 		{`let a = [3, 2, 1]; first(a)`, 3},
 		{`let a = [3, 2, 1]; last(a)`, 1},
@@ -345,6 +583,208 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestMemberExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"name": "gengo"}.name`, "gengo"},
+		{`{"name": "gengo"}.missing`, nil},
+		{`let user = {"address": {"city": "Oslo"}}; user.address.city`, "Oslo"},
+		{`"abc".upper()`, "ABC"},
+		{`"ABC".lower().len()`, 3},
+		{`"ÄBÇ".len()`, 3},
+		{`"a,b".split(",").len()`, 2},
+		{`" gengo ".trim().indexOf("n")`, 2},
+		{`"x".repeat(3)`, "xxx"},
+		{`[1, 2, 3].len()`, 3},
+		{`let double = fn(x) { x * 2 }; len([1, 2, 3].map(double))`, 3},
+		{`let offset = 10; [1, 2].map(fn(x) { x + offset })[1]`, 12},
+		{`[1, 2, 3, 4].filter(fn(x) { x > 2 }).len()`, 2},
+		{`[1, 2, 3].reduce(fn(acc, x) { acc + x }, 10)`, 16},
+		{`[3, 1, 2].sort(fn(a, b) { b - a }).first()`, 3},
+		{`[3, 1, 2].sort().reverse().last()`, 1},
+		{`[].reduce()`, &object.Error{Message: "wrong number of arguments. got=0, want=1 or 2"}},
+		{`"abc".missing`, &object.Error{Message: "unknown attribute: STRING.missing"}},
+		{`true.missing`, &object.Error{Message: "attribute access not supported: BOOLEAN"}},
+		{`"abc".upper(1)`, &object.Error{Message: "wrong number of arguments. got=1, want=0"}},
+		{`[1].map(fn(x) { x + true })`, &object.Error{Message: "type mismatch: INTEGER + BOOLEAN"}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case nil:
+			testNullObject(t, evaluated)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *object.Error:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			t.Fatalf("Unknown expected type. got=%T", expected)
+		}
+	}
+}
+
+func TestConditionalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true ? 1 : 2", 1},
+		{"false ? 1 : 2", 2},
+		{"null ? 1 : 2", 2},
+		{"1 > 2 ? 1 : 2 > 1 ? 2 : 3", 2},
+		{`let f = fn() { throw "boom" }; false ? f() : 2`, 2},
+		{"null ?? 1", 1},
+		{"2 ?? 1", 2},
+		{"false ?? 1", false},
+		{"null ?? null", nil},
+		{"null ?? null ?? 3", 3},
+		{`let f = fn() { throw "boom" }; 1 ?? f()`, 1},
+		{`{"a": 1}.b ?? 2`, 2},
+		{"let xs = null; xs?.[0]", nil},
+		{"let xs = [1]; xs?.[0]", 1},
+		{"let f = fn() { throw 1 }; let xs = null; xs?.[f()]", nil},
+		{"let h = null; h?.name", nil},
+		{`let h = {"name": {"first": "a"}}; h?.name?.first`, "a"},
+		{`let h = {}; h.name?.first ?? "default"`, "default"},
+		// A null before ?. skips the rest of the chain, calls included.
+		{`let h = null; h?.name.first`, nil},
+		{`let x = null; x?.foo()`, nil},
+		{`let x = null; x?.foo().bar[0]`, nil},
+		{`let f = fn() { throw 1 }; let x = null; x?.foo(f())`, nil},
+		{`let x = null; x?.len() ?? 0`, 0},
+		{`let x = "ab"; x?.len() + 1`, 3},
+		{`let h = {"name": null}; h?.name.first`, &object.Error{Message: "attribute access not supported: NULL"}},
+		{"let xs = null; xs?.[0] == null", true},
+		{"match (null) { null => 1, _ => 2 }", 1},
+		{"match (0) { null => 1, _ => 2 }", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case nil:
+			testNullObject(t, evaluated)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *object.Error:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			t.Fatalf("Unknown expected type. got=%T", expected)
+		}
+	}
+}
+
+func TestPipelines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let double = fn(x) { x * 2 }; 3 |> double", 6},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3)", 7},
+		{"[1, 2, 3] |> map(fn(x) { x * 2 }) |> filter(fn(x) { x > 2 }) |> len", 2},
+		{"let f = fn(a, b = 1, c = 1) { a * b * c }; 2 |> f(c: 5)", 10},
+		{"let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; (inc >> double)(3)", 8},
+		{"let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; 3 |> double >> inc", 7},
+		{"let add = fn(a, b) { a + b }; let double = fn(x) { x * 2 }; (add >> double)(1, 2)", 6},
+		{"let f = fn(x) { x + 1 }; let g = f >> f >> f; let f = fn(x) { x }; g(0)", 3},
+		{"[[1], [2, 3]] |> map(len >> fn(n) { n * 10 }) |> first", 10},
+		{"let f = fn(x) { x + 1 }; let g = f >> 1; g(0)", "not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestRangesAndSlices(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1..3", "1..3"},
+		{"let n = 3; 0..<n * 2 step 2", "0..<6 step 2"},
+		{"len(1..10)", 10},
+		{"len(0..<10 step 3)", 4},
+		{"(0..10 step 5)[2]", 10},
+		{"(0..10)[11]", nil},
+		{"reduce(1..100, fn(acc, x) { acc + x }, 0)", 5050},
+		{"(1..5)[1:-1]", "2..<5"},
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-3]", "[1]"},
+		{"[1, 2][:]", "[1, 2]"},
+		{`"hello"[:2]`, "he"},
+		{`"hello"[-3:]`, "llo"},
+		{"let xs = null; xs?.[1:]", nil},
+		{"let f = fn() { throw 1 }; let xs = null; xs?.[f():]", nil},
+		{"let xs = [1, 2, 3]; xs[first(xs):len(xs)]", "[2, 3]"},
+		{`0.."a"`, "range bounds must be INTEGER, got STRING"},
+		{"0..10 step 0", "range step must not be zero"},
+		{`[1, 2]["a":]`, "slice bounds must be INTEGER, got STRING"},
+		{"[1, 2][2:1]", "slice bounds out of range [2:1]"},
+		{"1[0:1]", "slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case nil:
+			testNullObject(t, evaluated)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("%q: wrong result. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 	evaluated := testEval(input)
@@ -359,6 +799,208 @@ func TestStringConcatenation(t *testing.T) {
 	testStringObject(t, evaluated, "Hello World!")
 }
 
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{"name": "Gengo"}[fn(x) { x }];`, &object.Error{Message: "unusable as hash key: FUNCTION"}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case *object.Error:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected.Message, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   object.Limits
+		expected error
+	}{
+		{
+			"let f = fn(x) { 1 + f(x) }; f(1);",
+			object.Limits{MaxCallDepth: 100},
+			&object.CallDepthError{Limit: 100},
+		},
+		{
+			"1 + 2 + 3 + 4 + 5",
+			object.Limits{MaxInstructions: 5},
+			&object.InstructionLimitError{Limit: 5},
+		},
+		{
+			"[1, 2, 3, 4]",
+			object.Limits{MaxAllocations: 4},
+			&object.AllocationLimitError{Limit: 4},
+		},
+		{
+			"try { range(0, 10000000) } catch { 0 };",
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		{
+			`import "strings" as strings; try { strings.repeat("ab", 1000000) } catch { 0 };`,
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		{
+			"let xs = range(0, 500); zip(xs, xs);",
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		// Ranges are lazy, so only the arrays made of them count.
+		{
+			"let r = 0..<1099511627776; r[1000] + len(r);",
+			object.Limits{MaxAllocations: 1000},
+			nil,
+		},
+		{
+			"try { first(0..<100000) } catch { 0 };",
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		{
+			`import "json" as json; try { json.parse("[` + strings.Repeat("1, ", 2000) + `1]") } catch { 0 };`,
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		{
+			"len(range(0, 500));",
+			object.Limits{MaxAllocations: 1000},
+			nil,
+		},
+		{
+			"let f = fn(x) { x }; f(1) + f(2);",
+			object.Limits{MaxInstructions: 100, MaxCallDepth: 1, MaxAllocations: 10},
+			nil,
+		},
+		{
+			"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000);",
+			object.Limits{MaxCallDepth: 10},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		_, err := New(tt.limits).Run(program, object.NewEnvironment())
+		if tt.expected == nil {
+			if err != nil {
+				t.Errorf("unexpected error for %q: %s", tt.input, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("expected error %q for %q, got none", tt.expected, tt.input)
+			continue
+		}
+
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.expected) || err.Error() != tt.expected.Error() {
+			t.Errorf("wrong error for %q. want=%T(%q), got=%T(%q)", tt.input, tt.expected, tt.expected, err, err)
+		}
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	input := `
+	let a = 1;
+	let loop = fn(x) { loop(x) };
+	let b = loop(a);
+	`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := New(object.Limits{}).RunContext(ctx, program, env)
+
+	var cancelErr *object.CancelError
+	if !errors.As(err, &cancelErr) {
+		t.Fatalf("error is not CancelError. got=%T (%v)", err, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error does not wrap context.DeadlineExceeded. got=%v", err)
+	}
+
+	if a, ok := env.Get("a"); !ok {
+		t.Errorf("a was not set before the evaluation was canceled")
+	} else {
+		testIntegerObject(t, a, 1)
+	}
+	if _, ok := env.Get("b"); ok {
+		t.Errorf("b was set by a canceled evaluation")
+	}
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
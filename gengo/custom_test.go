@@ -0,0 +1,184 @@
+package gengo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"Gengo/object"
+)
+
+// vector is a host defined object implementing every optional interface.
+type vector struct {
+	x, y int64
+}
+
+func (v *vector) Type() object.ObjectType { return "VECTOR" }
+func (v *vector) Inspect() string         { return fmt.Sprintf("vector(%d, %d)", v.x, v.y) }
+
+func (v *vector) Index(index object.Object) (object.Object, error) {
+	i, ok := index.(*object.Integer)
+	if !ok {
+		return nil, fmt.Errorf("vector index must be INTEGER, got %s", index.Type())
+	}
+	switch i.Value {
+	case 0:
+		return &object.Integer{Value: v.x}, nil
+	case 1:
+		return &object.Integer{Value: v.y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (v *vector) Attribute(name string) (object.Object, bool) {
+	switch name {
+	case "x":
+		return &object.Integer{Value: v.x}, true
+	case "y":
+		return &object.Integer{Value: v.y}, true
+	case "scale":
+		return &scaler{v}, true
+	default:
+		return nil, false
+	}
+}
+
+func (v *vector) Iterator() object.Iterator {
+	return &vectorIterator{elements: []int64{v.x, v.y}}
+}
+
+func (v *vector) Compare(other object.Object) (int, error) {
+	o, ok := other.(*vector)
+	if !ok {
+		return 0, fmt.Errorf("cannot compare VECTOR with %s", other.Type())
+	}
+	return int(v.x*v.x + v.y*v.y - o.x*o.x - o.y*o.y), nil
+}
+
+func (v *vector) BinaryOperation(op string, right object.Object) (object.Object, error) {
+	switch right := right.(type) {
+	case *vector:
+		switch op {
+		case "+":
+			return &vector{v.x + right.x, v.y + right.y}, nil
+		case "-":
+			return &vector{v.x - right.x, v.y - right.y}, nil
+		}
+	case *object.Integer:
+		if op == "*" {
+			return &vector{v.x * right.Value, v.y * right.Value}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported operation: VECTOR %s %s", op, right.Type())
+}
+
+type vectorIterator struct {
+	elements []int64
+}
+
+func (it *vectorIterator) Next() (object.Object, bool) {
+	if len(it.elements) == 0 {
+		return nil, false
+	}
+	next := it.elements[0]
+	it.elements = it.elements[1:]
+	return &object.Integer{Value: next}, true
+}
+
+// scaler is a callable bound to a vector.
+type scaler struct {
+	v *vector
+}
+
+func (s *scaler) Type() object.ObjectType { return "SCALER" }
+func (s *scaler) Inspect() string         { return "scaler" }
+
+func (s *scaler) Call(args ...object.Object) (object.Object, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	return s.v.BinaryOperation("*", args[0])
+}
+
+func TestCustomObjects(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"v.x + v.y", "7"},
+		{"v[0] * 10 + v[1]", "34"},
+		{"v[5]", "null"},
+		{"v + w", "vector(4, 6)"},
+		{"v - w", "vector(2, 2)"},
+		{"v * 2", "vector(6, 8)"},
+		{"v.scale(3)", "vector(9, 12)"},
+		{"let s = v.scale; s(2).y", "8"},
+		{"v == v", "true"},
+		{"v != w", "true"},
+		{"v > w", "true"},
+		{"v < w", "false"},
+		{"w < v", "true"},
+		{"len(v)", "2"},
+		{"first(v)", "3"},
+		{"last(v)", "4"},
+		{"rest(v)", "[4]"},
+		{"let f = fn(a, b) { (a + b).x }; f(v, w)", "4"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			i := New(e.engine)
+			if err := i.Set("v", &vector{3, 4}); err != nil {
+				t.Fatalf("%s: unexpected error: %s", e.name, err)
+			}
+			if err := i.Set("w", &vector{1, 2}); err != nil {
+				t.Fatalf("%s: unexpected error: %s", e.name, err)
+			}
+
+			result, err := i.Eval(tt.input)
+			if err != nil {
+				t.Errorf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+				continue
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestCustomObjectErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"v.z", "unknown attribute: VECTOR.z"},
+		{`v["x"]`, "vector index must be INTEGER, got STRING"},
+		{"v * v", "unsupported operation: VECTOR * VECTOR"},
+		{"v == 1", "cannot compare VECTOR with INTEGER"},
+		{"1 == v", "cannot compare VECTOR with INTEGER"},
+		{"v.scale(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{"true.x", "attribute access not supported: BOOLEAN"},
+		{"v(1)", "not a function: VECTOR"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			i := New(e.engine)
+			if err := i.Set("v", &vector{3, 4}); err != nil {
+				t.Fatalf("%s: unexpected error: %s", e.name, err)
+			}
+
+			var runtimeErr *RuntimeError
+			_, err := i.Eval(tt.input)
+			if !errors.As(err, &runtimeErr) {
+				t.Errorf("%s: %q: expected a *RuntimeError, got=%T (%v)", e.name, tt.input, err, err)
+				continue
+			}
+			if runtimeErr.Message != tt.expected {
+				t.Errorf("%s: %q: wrong error message. want=%q, got=%q", e.name, tt.input, tt.expected, runtimeErr.Message)
+			}
+		}
+	}
+}
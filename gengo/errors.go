@@ -0,0 +1,73 @@
+package gengo
+
+import (
+	"strings"
+
+	"Gengo/evaluator"
+	"Gengo/object"
+)
+
+// ParseError is returned when a program does not parse.
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse errors: " + strings.Join(e.Errors, "; ")
+}
+
+// CompileError is returned when a program does not compile to bytecode.
+type CompileError struct {
+	Err error
+}
+
+func (e *CompileError) Error() string {
+	return "compile error: " + e.Err.Error()
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// RuntimeError is returned when a program fails while it runs, such as on a type
+// mismatch or a call with the wrong number of arguments, or throws a value it
+// doesn't catch. Limit and cancel errors from the object package are returned as
+// they are.
+type RuntimeError struct {
+	Message string
+	// Value is the value that was thrown, or nil if the program failed.
+	Value object.Object
+	// Stack holds the functions the error unwound from, innermost first.
+	Stack []string
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message
+}
+
+// runtimeError converts an error returned by the VM to the error the Interpreter
+// returns, leaving limit and cancel errors untouched.
+func runtimeError(err error) error {
+	if object.IsAbort(err) {
+		return err
+	}
+
+	errObj := object.ToError(err)
+	return &RuntimeError{Message: errObj.Message, Value: errObj.Value, Stack: errObj.Stack}
+}
+
+// evaluated converts the result of the evaluator to the result the Interpreter
+// returns, turning error objects into a *RuntimeError and no value into null.
+func evaluated(result object.Object, err error) (object.Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Message: errObj.Message, Value: errObj.Value, Stack: errObj.Stack}
+	}
+	if result == nil {
+		return evaluator.NULL, nil
+	}
+
+	return result, nil
+}
@@ -0,0 +1,397 @@
+// Package gengo embeds the Gengo programming language in Go programs.
+//
+// An Interpreter runs programs and keeps their global bindings between runs, so a
+// host can define values with Set, run scripts with Eval and call the functions
+// they define with Call, without caring whether the tree-walking evaluator or the
+// bytecode VM does the work.
+package gengo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"Gengo/ast"
+	"Gengo/compiler"
+	"Gengo/evaluator"
+	"Gengo/lexer"
+	"Gengo/module"
+	"Gengo/object"
+	"Gengo/parser"
+	"Gengo/vm"
+)
+
+// Engine selects how an Interpreter executes programs. Both engines fail the same way:
+// an identifier that isn't defined, for one, is an error when it is evaluated, which
+// try can catch.
+type Engine int
+
+const (
+	// VM compiles programs to bytecode and runs them on the virtual machine.
+	VM Engine = iota
+	// TreeWalker evaluates the AST of programs directly.
+	TreeWalker
+)
+
+// Interpreter runs Gengo programs. An Interpreter is not safe for concurrent use.
+type Interpreter struct {
+	engine Engine
+	limits object.Limits
+	loader *module.Loader
+	io     *object.IO
+	// random is the source of random numbers of the math module, which math.seed
+	// seeds for all programs run by the Interpreter.
+	random *object.Random
+	// registry holds the builtins and the modules implemented in Go that the
+	// programs of the Interpreter can use.
+	registry *object.Registry
+
+	// The state of the tree-walking evaluator.
+	env *object.Environment
+
+	// The state of the VM.
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+// Program is a parsed, and for the VM compiled, program. It can only be run by the
+// Interpreter that created it.
+type Program struct {
+	interpreter *Interpreter
+	program     *ast.Program
+	bytecode    *compiler.Bytecode
+	// file is the file the program was read from, which its imports are relative to.
+	file string
+	// warnings are the warnings of the parser.
+	warnings []string
+}
+
+// Warnings returns the problems the parser found in the program that don't stop it
+// from running, such as a match expression that leaves a boolean unmatched.
+func (p *Program) Warnings() []string {
+	return p.warnings
+}
+
+// New creates an Interpreter that executes programs with engine.
+func New(engine Engine) *Interpreter {
+	i := &Interpreter{
+		engine:   engine,
+		loader:   module.NewLoader(),
+		io:       object.NewIO(os.Stdout, os.Stdin),
+		random:   object.NewRandom(time.Now().UnixNano()),
+		registry: object.NewRegistry(),
+	}
+
+	switch engine {
+	case TreeWalker:
+		i.env = object.NewEnvironment()
+	default:
+		i.engine = VM
+		i.symbolTable = compiler.NewSymbolTableWithRegistry(i.registry)
+		i.constants = []object.Object{}
+		i.globals = make([]object.Object, vm.GlobalsSize)
+	}
+
+	return i
+}
+
+// RegisterFunc makes fn, a Go function, available to the programs of the
+// Interpreter as the builtin name. See object.NewBuiltinFunc for the functions it
+// accepts. Other interpreters don't see it.
+func (i *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	return i.registry.RegisterFunc(name, fn)
+}
+
+// RegisterModule makes exports importable by the programs of the Interpreter as the
+// module name, as in import "name" as name. Modules registered this way take
+// precedence over files. Other interpreters don't see them.
+func (i *Interpreter) RegisterModule(name string, exports map[string]object.Object) error {
+	return i.registry.RegisterModule(name, exports)
+}
+
+// RegisterMethod adds the method name to the objects of type t in the programs of
+// the Interpreter, called as obj.name(args). Other interpreters don't see it.
+func (i *Interpreter) RegisterMethod(t object.ObjectType, name string, fn object.MethodFunction) error {
+	return i.registry.RegisterMethod(t, name, fn)
+}
+
+// Engine returns the engine the Interpreter executes programs with.
+func (i *Interpreter) Engine() Engine {
+	return i.engine
+}
+
+// SetLimits sets the limits enforced on every following run or call.
+func (i *Interpreter) SetLimits(limits object.Limits) {
+	i.limits = limits
+}
+
+// SetOutput sets where print, println and printf write to. It is the standard
+// output by default.
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.io = &object.IO{Out: w, In: i.io.In}
+	if w == nil {
+		i.io.Out = io.Discard
+	}
+}
+
+// SetInput sets where input and readLine read from. It is the standard input by
+// default.
+func (i *Interpreter) SetInput(r io.Reader) {
+	i.io = object.NewIO(i.io.Out, r)
+}
+
+// SetSearchPath sets the directories modules are looked for in when they aren't
+// found relative to the importing file.
+func (i *Interpreter) SetSearchPath(dirs ...string) {
+	i.loader.SearchPath = dirs
+}
+
+// SetLoader replaces the loader that resolves and reads the files of imported
+// modules, for example to read them from somewhere other than the file system.
+func (i *Interpreter) SetLoader(loader *module.Loader) {
+	i.loader = loader
+}
+
+// loaderFor returns the loader for the imports of the program read from file. Unless
+// the loader has a root of its own, they are confined to the directory of file.
+func (i *Interpreter) loaderFor(file string) *module.Loader {
+	if i.loader.Root != "" || file == "" {
+		return i.loader
+	}
+
+	loader := *i.loader
+	loader.Root = filepath.Dir(file)
+	return &loader
+}
+
+// Eval parses, compiles and runs src, returning the value of its last statement.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.EvalContext(context.Background(), src)
+}
+
+// EvalContext is like Eval but aborts with a *object.CancelError once ctx is done.
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	program, err := i.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.RunContext(ctx, program)
+}
+
+// EvalFile is like Eval but runs the program in the file name. Its imports are
+// relative to the file.
+func (i *Interpreter) EvalFile(name string) (object.Object, error) {
+	program, err := i.CompileFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.Run(program)
+}
+
+// Compile parses src and, if the Interpreter uses the VM, compiles it to bytecode.
+// Its imports are relative to the working directory.
+func (i *Interpreter) Compile(src string) (*Program, error) {
+	return i.compile(src, "")
+}
+
+// CompileFile is like Compile but reads the program from the file name, which its
+// imports are relative to.
+func (i *Interpreter) CompileFile(name string) (*Program, error) {
+	src, err := i.loader.Read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.compile(string(src), name)
+}
+
+func (i *Interpreter) compile(src, file string) (*Program, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	if i.engine == TreeWalker {
+		return &Program{interpreter: i, program: program, file: file, warnings: p.Warnings()}, nil
+	}
+
+	comp := compiler.NewWithState(i.symbolTable, i.constants)
+	comp.SetLoader(i.loaderFor(file), file)
+	comp.SetOptimize(true)
+	err := comp.Compile(program)
+	if err != nil {
+		return nil, &CompileError{Err: err}
+	}
+
+	bytecode := comp.Bytecode()
+	i.constants = bytecode.Constants
+
+	return &Program{interpreter: i, program: program, bytecode: bytecode, file: file, warnings: p.Warnings()}, nil
+}
+
+// Run runs a compiled program, returning the value of its last statement.
+func (i *Interpreter) Run(program *Program) (object.Object, error) {
+	return i.RunContext(context.Background(), program)
+}
+
+// RunContext is like Run but aborts with a *object.CancelError once ctx is done.
+func (i *Interpreter) RunContext(ctx context.Context, program *Program) (object.Object, error) {
+	if program.interpreter != i {
+		return nil, fmt.Errorf("program was compiled by a different interpreter")
+	}
+
+	if i.engine == TreeWalker {
+		ev := evaluator.New(i.limits)
+		ev.SetLoader(i.loaderFor(program.file), program.file)
+		ev.SetIO(i.io)
+		ev.SetRandom(i.random)
+		ev.SetRegistry(i.registry)
+		return evaluated(ev.RunContext(ctx, program.program, i.env))
+	}
+
+	machine := vm.NewWithGlobalsStore(program.bytecode, i.globals)
+	machine.SetLimits(i.limits)
+	machine.SetIO(i.io)
+	machine.SetRandom(i.random)
+	machine.SetRegistry(i.registry)
+
+	err := machine.RunContext(ctx)
+	i.globals = machine.Globals()
+	if err != nil {
+		return nil, runtimeError(err)
+	}
+
+	// The last popped element is the value of the last expression statement, or of
+	// a top-level return. Statements binding names leave nothing behind.
+	statements := program.program.Statements
+	if len(statements) == 0 {
+		return vm.Null, nil
+	}
+	switch statements[len(statements)-1].(type) {
+	case *ast.LetStatement, *ast.FunctionDeclaration, *ast.ImportStatement, *ast.ExportStatement:
+		return vm.Null, nil
+	}
+
+	return machine.LastPoppedStackElem(), nil
+}
+
+// Set binds value to name as a global. A global declared with const can't be bound
+// again.
+func (i *Interpreter) Set(name string, value object.Object) error {
+	if i.engine == TreeWalker {
+		if i.env.IsConst(name) {
+			return fmt.Errorf("cannot reassign constant %s", name)
+		}
+		i.env.Set(name, value)
+		return nil
+	}
+
+	if i.symbolTable.IsConst(name) {
+		return fmt.Errorf("cannot reassign constant %s", name)
+	}
+
+	symbol, ok := i.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		symbol = i.symbolTable.Define(name)
+	}
+
+	if symbol.Index >= len(i.globals) {
+		globals := make([]object.Object, 2*symbol.Index)
+		copy(globals, i.globals)
+		i.globals = globals
+	}
+
+	i.globals[symbol.Index] = value
+	return nil
+}
+
+// Get returns the value of the global or builtin called name.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	if i.engine == TreeWalker {
+		if value, ok := i.env.Get(name); ok {
+			return value, true
+		}
+		if builtin := i.registry.GetBuiltinByName(name); builtin != nil {
+			return builtin, true
+		}
+		return nil, false
+	}
+
+	symbol, ok := i.symbolTable.Resolve(name)
+	if !ok {
+		return nil, false
+	}
+
+	switch symbol.Scope {
+	case compiler.GlobalScope:
+		if symbol.Index >= len(i.globals) {
+			return nil, false
+		}
+		value := i.globals[symbol.Index]
+		return value, value != nil
+	case compiler.BuiltinScope:
+		return i.registry.GetBuiltin(symbol.Index), true
+	default:
+		return nil, false
+	}
+}
+
+// Call calls the function bound to fnName with args and returns its result.
+func (i *Interpreter) Call(fnName string, args ...object.Object) (object.Object, error) {
+	return i.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is like Call but aborts with a *object.CancelError once ctx is done.
+func (i *Interpreter) CallContext(ctx context.Context, fnName string, args ...object.Object) (object.Object, error) {
+	fn, ok := i.Get(fnName)
+	if !ok {
+		return nil, fmt.Errorf("identifier not found: %s", fnName)
+	}
+
+	return i.ApplyContext(ctx, fn, args...)
+}
+
+// Apply calls fn, a function or builtin returned by a program or by Get, with args.
+func (i *Interpreter) Apply(fn object.Object, args ...object.Object) (object.Object, error) {
+	return i.ApplyContext(context.Background(), fn, args...)
+}
+
+// ApplyContext is like Apply but aborts with a *object.CancelError once ctx is done.
+func (i *Interpreter) ApplyContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	if i.engine == TreeWalker {
+		ev := evaluator.New(i.limits)
+		ev.SetIO(i.io)
+		ev.SetRandom(i.random)
+		ev.SetRegistry(i.registry)
+		return evaluated(ev.ApplyContext(ctx, fn, args...))
+	}
+
+	machine := vm.NewWithGlobalsStore(&compiler.Bytecode{Constants: i.constants}, i.globals)
+	machine.SetLimits(i.limits)
+	machine.SetIO(i.io)
+	machine.SetRandom(i.random)
+	machine.SetRegistry(i.registry)
+
+	result, err := machine.CallContext(ctx, fn, args...)
+	i.globals = machine.Globals()
+	if err != nil {
+		return nil, runtimeError(err)
+	}
+
+	return result, nil
+}
+
+// ToGo stores obj in the value target points to, as object.ToGo does. Functions
+// stored in Go funcs are called with the Interpreter.
+func (i *Interpreter) ToGo(obj object.Object, target interface{}) error {
+	return object.ToGoWithCaller(obj, target, i.Apply)
+}
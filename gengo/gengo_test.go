@@ -0,0 +1,980 @@
+package gengo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"Gengo/object"
+)
+
+var engines = []struct {
+	name   string
+	engine Engine
+}{
+	{"vm", VM},
+	{"tree-walker", TreeWalker},
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2", "3"},
+		{"let a = 5; a * 2", "10"},
+		{"let a = 5;", "null"},
+		{"", "null"},
+		{`"Hello" + " " + "World"`, "Hello World"},
+		{"[1, 2, 3][1]", "2"},
+		{`{"one": 1}["one"]`, "1"},
+		{"let add = fn(a, b) { a + b }; add(1, 2)", "3"},
+		{"if (false) { 10 }", "null"},
+		{"return 7; 8", "7"},
+		{"len([1, 2, 3])", "3"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			result, err := New(e.engine).Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestGlobalsPersistBetweenRuns(t *testing.T) {
+	for _, e := range engines {
+		i := New(e.engine)
+
+		program, err := i.Compile("let counter = 1;")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if _, err := i.Run(program); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		result, err := i.Eval("counter + 1")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "2" {
+			t.Errorf("%s: wrong result. want=2, got=%s", e.name, result.Inspect())
+		}
+	}
+}
+
+func TestRunForeignProgram(t *testing.T) {
+	for _, e := range engines {
+		program, err := New(e.engine).Compile("1")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		if _, err := New(e.engine).Run(program); err == nil {
+			t.Errorf("%s: expected an error running a program of another interpreter", e.name)
+		}
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	for _, e := range engines {
+		i := New(e.engine)
+
+		if err := i.Set("answer", &object.Integer{Value: 42}); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		result, err := i.Eval("let doubled = answer * 2; doubled")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "84" {
+			t.Errorf("%s: wrong result. want=84, got=%s", e.name, result.Inspect())
+		}
+
+		doubled, ok := i.Get("doubled")
+		if !ok {
+			t.Fatalf("%s: doubled not found", e.name)
+		}
+		if doubled.Inspect() != "84" {
+			t.Errorf("%s: wrong value for doubled. want=84, got=%s", e.name, doubled.Inspect())
+		}
+
+		if err := i.Set("flag", &object.Boolean{Value: true}); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		result, err = i.Eval("flag == true")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "true" {
+			t.Errorf("%s: host booleans should equal true. got=%s", e.name, result.Inspect())
+		}
+
+		if _, ok := i.Get("len"); !ok {
+			t.Errorf("%s: builtin len not found", e.name)
+		}
+		if _, ok := i.Get("missing"); ok {
+			t.Errorf("%s: missing should not be found", e.name)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	for _, e := range engines {
+		i := New(e.engine)
+
+		_, err := i.Eval("let offset = 10; let add = fn(a, b) { a + b + offset };")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		result, err := i.Call("add", &object.Integer{Value: 1}, &object.Integer{Value: 2})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "13" {
+			t.Errorf("%s: wrong result. want=13, got=%s", e.name, result.Inspect())
+		}
+
+		result, err = i.Call("len", &object.String{Value: "four"})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "4" {
+			t.Errorf("%s: wrong result. want=4, got=%s", e.name, result.Inspect())
+		}
+
+		if _, err := i.Call("missing"); err == nil {
+			t.Errorf("%s: expected an error calling an unknown function", e.name)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, e := range engines {
+		i := New(e.engine)
+
+		var parseErr *ParseError
+		if _, err := i.Eval("let = 5;"); !errors.As(err, &parseErr) {
+			t.Errorf("%s: expected a *ParseError, got=%T (%v)", e.name, err, err)
+		}
+
+		var runtimeErr *RuntimeError
+		_, err := i.Eval(`1 + "a"`)
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("%s: expected a *RuntimeError, got=%T (%v)", e.name, err, err)
+		}
+		if runtimeErr.Message != "type mismatch: INTEGER + STRING" {
+			t.Errorf("%s: wrong error message. got=%q", e.name, runtimeErr.Message)
+		}
+
+		_, err = i.Eval("let f = fn(a) { a }; f(1, 2)")
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("%s: expected a *RuntimeError, got=%T (%v)", e.name, err, err)
+		}
+	}
+
+	var compileErr *CompileError
+	if _, err := New(VM).Eval("const c = 1; let c = 2;"); !errors.As(err, &compileErr) {
+		t.Errorf("vm: expected a *CompileError, got=%T (%v)", err, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	input := "let loop = fn(n) { if (n == 0) { 0 } else { 1 + loop(n - 1) } }; loop(100)"
+
+	for _, e := range engines {
+		i := New(e.engine)
+		i.SetLimits(object.Limits{MaxCallDepth: 10})
+
+		var depthErr *object.CallDepthError
+		if _, err := i.Eval(input); !errors.As(err, &depthErr) {
+			t.Errorf("%s: expected a *object.CallDepthError, got=%T (%v)", e.name, err, err)
+		}
+	}
+}
+
+func TestDefaultCallDepth(t *testing.T) {
+	for _, e := range engines {
+		i := New(e.engine)
+
+		result, err := i.Eval("let deep = fn(n) { if (n == 0) { 0 } else { 1 + deep(n - 1) } }; deep(2000)")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "2000" {
+			t.Errorf("%s: wrong result. want=2000, got=%s", e.name, result.Inspect())
+		}
+
+		// Runaway recursion is stopped by the default limit, which try can't catch.
+		var depthErr *object.CallDepthError
+		_, err = i.Eval("let g = fn(n) { 1 + g(n + 1) }; try { g(0) } catch (e) { 0 }")
+		if !errors.As(err, &depthErr) {
+			t.Fatalf("%s: expected a *object.CallDepthError, got=%T (%v)", e.name, err, err)
+		}
+		if depthErr.Limit != object.DefaultMaxCallDepth {
+			t.Errorf("%s: wrong limit. want=%d, got=%d", e.name, object.DefaultMaxCallDepth, depthErr.Limit)
+		}
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	for _, e := range engines {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var cancelErr *object.CancelError
+		_, err := New(e.engine).EvalContext(ctx, "1 + 1")
+		if !errors.As(err, &cancelErr) {
+			t.Errorf("%s: expected a *object.CancelError, got=%T (%v)", e.name, err, err)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected the error to wrap context.Canceled", e.name)
+		}
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	greet := func(name string, times int) (string, error) {
+		if times < 1 {
+			return "", fmt.Errorf("times must be positive, got %d", times)
+		}
+		return strings.Repeat("hello "+name+" ", times), nil
+	}
+
+	for _, e := range engines {
+		name := e.name
+		interpreter := New(e.engine)
+		if err := interpreter.RegisterFunc("greet", greet); err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+
+		result, err := interpreter.Eval(`let greeting = fn(name) { greet(name, 2) }; greeting("gengo")`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		if result.Inspect() != "hello gengo hello gengo " {
+			t.Errorf("%s: wrong result. got=%q", name, result.Inspect())
+		}
+
+		var runtimeErr *RuntimeError
+		_, err = interpreter.Eval(`greet("gengo", 0)`)
+		if !errors.As(err, &runtimeErr) || runtimeErr.Message != "times must be positive, got 0" {
+			t.Errorf("%s: wrong error. got=%v", name, err)
+		}
+
+		_, err = interpreter.Eval(`greet(1, 2)`)
+		if !errors.As(err, &runtimeErr) || runtimeErr.Message != "argument 1 to `greet` must be STRING, got INTEGER" {
+			t.Errorf("%s: wrong error. got=%v", name, err)
+		}
+	}
+}
+
+func TestRegistryPerInterpreter(t *testing.T) {
+	for _, e := range engines {
+		i, other := New(e.engine), New(e.engine)
+
+		if err := i.RegisterFunc("double", func(x int) int { return 2 * x }); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if err := i.RegisterModule("answers", map[string]object.Object{"answer": &object.Integer{Value: 42}}); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		err := i.RegisterMethod(object.INTEGER, "half", func(rt object.Runtime, receiver object.Object, args ...object.Object) object.Object {
+			return &object.Integer{Value: receiver.(*object.Integer).Value / 2}
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		result, err := i.Eval(`import "answers" as answers; double(answers.answer).half()`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "42" {
+			t.Errorf("%s: wrong result. want=42, got=%s", e.name, result.Inspect())
+		}
+
+		if _, err := other.Eval("double(1)"); err == nil {
+			t.Errorf("%s: expected double to be undefined in another interpreter", e.name)
+		}
+		if _, err := other.Eval(`import "answers" as answers;`); err == nil {
+			t.Errorf("%s: expected answers to be missing in another interpreter", e.name)
+		}
+		if _, err := other.Eval("let n = 2; n.half()"); err == nil {
+			t.Errorf("%s: expected half to be missing in another interpreter", e.name)
+		}
+		if _, ok := other.Get("double"); ok {
+			t.Errorf("%s: expected Get to not find double in another interpreter", e.name)
+		}
+		if object.GetBuiltinByName("double") != nil {
+			t.Errorf("%s: expected double to be missing from the default registry", e.name)
+		}
+
+		// Every interpreter may register the same name.
+		if err := other.RegisterFunc("double", func(x int) int { return x + x }); err != nil {
+			t.Errorf("%s: unexpected error: %s", e.name, err)
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	for _, e := range engines {
+		i := New(e.engine)
+
+		_, err := i.Eval(`let scale = 3; let apply = fn(xs, factor) { [first(xs) * factor * scale, last(xs)] };`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		fn, _ := i.Get("apply")
+
+		var apply func([]int, int) ([]int, error)
+		if err := i.ToGo(fn, &apply); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		result, err := apply([]int{2, 5}, 10)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if len(result) != 2 || result[0] != 60 || result[1] != 5 {
+			t.Errorf("%s: wrong result. got=%v", e.name, result)
+		}
+
+		var wrongArity func(int) error
+		if err := i.ToGo(fn, &wrongArity); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if err := wrongArity(1); err == nil {
+			t.Errorf("%s: expected an error calling with the wrong number of arguments", e.name)
+		}
+
+		value, err := object.FromGo(map[string][]int{"primes": {2, 3, 5}})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if err := i.Set("data", value); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		sum, err := i.Eval(`let primes = data["primes"]; primes[0] + primes[1] + primes[2]`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if sum.Inspect() != "10" {
+			t.Errorf("%s: wrong result. want=10, got=%s", e.name, sum.Inspect())
+		}
+	}
+}
+
+func TestBuiltinCallbacks(t *testing.T) {
+	applyTwice := func(f func(int) (int, error), x int) (int, error) {
+		once, err := f(x)
+		if err != nil {
+			return 0, err
+		}
+		return f(once)
+	}
+
+	for _, e := range engines {
+		i := New(e.engine)
+		if err := i.RegisterFunc("applyTwice", applyTwice); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		result, err := i.Eval("let add = fn(n) { fn(x) { x + n } }; applyTwice(add(5), 1)")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "11" {
+			t.Errorf("%s: wrong result. want=11, got=%s", e.name, result.Inspect())
+		}
+
+		var runtimeErr *RuntimeError
+		_, err = i.Eval(`applyTwice(fn(x) { x + "a" }, 1)`)
+		if !errors.As(err, &runtimeErr) || runtimeErr.Message != "type mismatch: INTEGER + STRING" {
+			t.Errorf("%s: wrong error. got=%T (%v)", e.name, err, err)
+		}
+
+		// Limits apply to the calls builtins make, and abort the whole run.
+		i.SetLimits(object.Limits{MaxInstructions: 200})
+		var limitErr *object.InstructionLimitError
+		_, err = i.Eval("let loop = fn(x) { loop(x) }; [1, 2].map(loop)")
+		if !errors.As(err, &limitErr) {
+			t.Errorf("%s: expected a *object.InstructionLimitError, got=%T (%v)", e.name, err, err)
+		}
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map([1, 2, 3], fn(x) { x * x })", "[1, 4, 9]"},
+		{"filter(range(10), fn(x) { x / 2 * 2 == x })", "[0, 2, 4, 6, 8]"},
+		{"reduce(range(1, 5), fn(acc, x) { acc * x })", "24"},
+		{`sort(["pear", "fig", "apple"], fn(a, b) { len(a) - len(b) })`, "[fig, pear, apple]"},
+		{"sort([3, 1, 2])", "[1, 2, 3]"},
+		{`zip(keys({"a": 1, "b": 2}), values({"a": 1, "b": 2}))`, "[[a, 1], [b, 2]]"},
+		{"!any([1, 3], fn(x) { x == 2 })", "true"},
+		{"all([1, 3], fn(x) { x > 0 })", "true"},
+		{"reverse(slice([1, 2, 3, 4], 1))", "[4, 3, 2]"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			result, err := New(e.engine).Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+
+		_, err := New(e.engine).Eval(`map([1], fn(x) { x + "a" })`)
+		if err == nil || err.Error() != "type mismatch: INTEGER + STRING" {
+			t.Errorf("%s: expected the error of the callback, got=%v", e.name, err)
+		}
+	}
+}
+
+func TestIO(t *testing.T) {
+	input := `
+		let name = input("name? ");
+		let greet = fn(greeting) { printf("%s, %s!", greeting, name); println() };
+		greet("Hello");
+		print(1, "two", [3]);
+		readLine()`
+
+	for _, e := range engines {
+		var out bytes.Buffer
+		i := New(e.engine)
+		i.SetOutput(&out)
+		i.SetInput(strings.NewReader("Ada\nrest\n"))
+
+		result, err := i.Eval(input)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "rest" {
+			t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+		}
+		if out.String() != "name? Hello, Ada!\n1 two [3]" {
+			t.Errorf("%s: wrong output. got=%q", e.name, out.String())
+		}
+
+		// Functions called by the host print to the same output.
+		out.Reset()
+		if _, err := i.Call("greet", &object.String{Value: "Bye"}); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if out.String() != "Bye, Ada!\n" {
+			t.Errorf("%s: wrong output. got=%q", e.name, out.String())
+		}
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 } catch (e) { 2 }", "1"},
+		{`try { throw "boom"; 1 } catch (e) { e.message }`, "boom"},
+		{`try { 1 + "a" } catch (e) { e.message }`, "type mismatch: INTEGER + STRING"},
+		{"try { throw [1, 2] } catch (e) { e.value }", "[1, 2]"},
+		{`try { throw "boom" } catch (e) { e.value }`, "boom"},
+		{`try { throw "boom" } catch { "caught" }`, "caught"},
+		{"1 + try { throw 1 } catch { 2 }", "3"},
+		{"let f = fn() { throw 1 }; let g = fn() { f(); null }; try { g() } catch (e) { e.stack }", "[f, g]"},
+		{"try { fn() { throw 1 }() } catch (e) { e.stack }", "[<anonymous>]"},
+		{"let f = fn() { try { return 1 } finally { 3 } }; f()", "1"},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", "2"},
+		{`let f = fn() { try { throw "a" } finally { return "cleanup" } }; f()`, "cleanup"},
+		{`try { try { throw "in" } finally { 1 } } catch (e) { e.message }`, "in"},
+		{`try { try { throw "a" } catch (e) { throw e } } catch (e) { e.message }`, "a"},
+		{`let f = fn() { try { throw "a" } catch (e) { throw "b" } finally { 0 } }; try { f() } catch (e) { e.message }`, "b"},
+		{`try { map([1], fn(x) { throw [x] }) } catch (e) { e.value }`, "[1]"},
+		{`map([1, 2], fn(x) { try { if (x == 2) { throw "two" } x } catch (e) { e.message } })`, "[1, two]"},
+		{"let f = fn(n) { if (n == 0) { throw n } 1 + f(n - 1) }; try { f(2) } catch (e) { e.stack }", "[f, f, f]"},
+		{"try { 1 / 0 } catch (e) { e.message }", "division by zero"},
+		{"const e = 1; try { throw 2 } catch (e) { e.value }", "2"},
+		{"let e = 1; try { throw 2 } catch (e) { 3 }; e", "1"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			result, err := New(e.engine).Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestCatchUndefinedIdentifier(t *testing.T) {
+	input := "try { foobar } catch (e) { e.message }"
+
+	for _, e := range engines {
+		result, err := New(e.engine).Eval(input)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "identifier not found: foobar" {
+			t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+		}
+	}
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } } fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } } [isEven(10), isOdd(7)]", "[true, true]"},
+		{"let x = square(3); fn square(n) { n * n } x", "9"},
+		{"let f = fn(xs) { fn walk(ys) { if (len(ys) == 0) { 0 } else { step(ys) } } fn step(ys) { first(ys) + walk(rest(ys)) } walk(xs) }; f([1, 2, 3])", "6"},
+		{"fn boom() { throw 1 } fn outer() { boom(); null } try { outer() } catch (e) { e.stack }", "[boom, outer]"},
+		{"fn f() { 1 }", "null"},
+		{"fn greet(name, greeting = \"hi\") { greeting + \" \" + name } greet(name: \"ada\")", "hi ada"},
+		// Declared functions see the globals defined after them, once they are.
+		{"fn scaled(n) { n * factor } let factor = 3; scaled(2)", "6"},
+		{"fn both() { [a, b] } let [a, b] = [1, 2]; const c = both(); c", "[1, 2]"},
+		{"fn early() { later } let x = try { early() } catch (e) { e.message }; let later = 1; [x, early()]", "[identifier not found: later, 1]"},
+		{"let x = 1; let f = fn() { x }; let x = 2; f()", "2"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			result, err := New(e.engine).Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+
+		result, err := New(e.engine).Eval("fn add(a, b) { a + b } add")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if !strings.Contains(result.Inspect(), "add") {
+			t.Errorf("%s: function is not named: %s", e.name, result.Inspect())
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	for _, e := range engines {
+		i := New(e.engine)
+		if _, err := i.Eval("const limit = 10; let f = fn(limit) { limit }; f(1)"); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		// The compiler rejects the program before it runs, the evaluator when it
+		// gets to the binding.
+		_, err := i.Eval("let limit = 20;")
+		if err == nil || !strings.HasSuffix(err.Error(), "cannot reassign constant limit") {
+			t.Errorf("%s: wrong error. got=%v", e.name, err)
+		}
+		if err := i.Set("limit", &object.Integer{Value: 20}); err == nil || err.Error() != "cannot reassign constant limit" {
+			t.Errorf("%s: wrong error from Set. got=%v", e.name, err)
+		}
+
+		result, err := i.Eval("limit")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "10" {
+			t.Errorf("%s: constant changed. got=%s", e.name, result.Inspect())
+		}
+
+		// Function declarations are hoisted above the constants of their block, so
+		// they can't share a name with one, whichever comes first.
+		for _, input := range []string{
+			"const f = 1; fn f() { 2 }",
+			"fn f() { 2 } const f = 1;",
+			"fn g() { const f = 1; fn f() { 2 } f } g()",
+		} {
+			_, err := New(e.engine).Eval(input)
+			if err == nil || !strings.HasSuffix(err.Error(), "cannot reassign constant f") {
+				t.Errorf("%s: %q: wrong error. got=%v", e.name, input, err)
+			}
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	appendFn := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		if err := args[0].(*object.Array).Append(args[1]); err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return args[0]
+	}}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let xs = [1]; append(xs, 2); xs", "[1, 2]"},
+		{"let xs = freeze([1]); try { append(xs, 2) } catch (e) { e.message }", "cannot modify frozen ARRAY"},
+		{`let h = freeze({"xs": [[1]]}); try { append(h.xs[0], 2) } catch (e) { e.message }`, "cannot modify frozen ARRAY"},
+		{"let xs = freeze([1, 2, 3]); try { append(rest(xs), 4) } catch (e) { e.message }", "cannot modify frozen ARRAY"},
+		// Scripts see the frozen checks through the builtins that grow arrays.
+		{"let xs = freeze([1]); try { push(xs, 2) } catch (e) { e.message }", "cannot modify frozen ARRAY"},
+		{"let xs = freeze([1]); try { xs.push(2) } catch (e) { e.message }", "cannot modify frozen ARRAY"},
+		{"let xs = freeze([[1]]); try { push(first(xs), 2) } catch (e) { e.message }", "cannot modify frozen ARRAY"},
+		{"let xs = freeze([1, 2]); try { push(rest(xs), 3) } catch (e) { e.message }", "cannot modify frozen ARRAY"},
+		{"let [x, ...ys] = freeze([1, 2]); try { push(ys, 3) } catch (e) { e.message }", "cannot modify frozen ARRAY"},
+		{"let xs = freeze([1]); let ys = push(slice(xs, 0), 2); try { push(xs[0:], 2) } catch (e) { [ys, e.message] }", "[[1, 2], cannot modify frozen ARRAY]"},
+		{"freeze(1)", "1"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			i := New(e.engine)
+			if err := i.Set("append", appendFn); err != nil {
+				t.Fatalf("%s: unexpected error: %s", e.name, err)
+			}
+			result, err := i.Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestConditionalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let config = {"port": 80}; config.host ?? "localhost"`, "localhost"},
+		{`let config = null; config?.["port"] ?? 8080`, "8080"},
+		{`let config = {"db": {"name": "test"}}; config?.db?.name`, "test"},
+		{`let config = {}; config.db?.name ?? "none"`, "none"},
+		{`let config = null; config?.db.name.upper() ?? "none"`, "none"},
+		{"let x = 5; x > 3 ? \"big\" : \"small\"", "big"},
+		{"let f = fn(x) { x ?? 0 }; f(null) + f(2)", "2"},
+		{"false ? 1 : null ?? 2", "2"},
+		{"match (null ?? 1) { null => 0, n => n }", "1"},
+		{"null?.[boom()] ?? 1 ? 2 : boom()", "2"},
+		{"1 ?? boom()", "1"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			calls := 0
+			i := New(e.engine)
+			err := i.Set("boom", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+				calls++
+				return &object.Error{Message: "boom"}
+			}})
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", e.name, err)
+			}
+
+			result, err := i.Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+			if calls != 0 {
+				t.Errorf("%s: %q: operand evaluated %d times, want none", e.name, tt.input, calls)
+			}
+		}
+	}
+}
+
+func TestPipelines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[3, 1, 2] |> sort |> map(fn(x) { x * 10 })", "[10, 20, 30]"},
+		{"[1, 2, 3, 4] |> filter(fn(x) { x > 2 }) |> reduce(fn(acc, x) { acc + x }, 0)", "7"},
+		{"let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; [1, 2] |> map(inc >> double)", "[4, 6]"},
+		{"let wrap = fn(x) { [x] }; 1 |> wrap >> wrap >> len", "1"},
+		{"fn fail(x) { throw x } let f = fail >> len; try { f(1) } catch (e) { e.stack }", "[fail, <anonymous>]"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			result, err := New(e.engine).Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestRangesAndSlices(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0..<10 step 3", "0..<10 step 3"},
+		{"1..5 |> map(fn(x) { x * x })", "[1, 4, 9, 16, 25]"},
+		{"let n = 4; (0..<n)[1:][2]", "3"},
+		{"let words = [\"a\", \"b\", \"c\", \"d\"]; words[1:3]", "[b, c]"},
+		{"let s = \"Gengo 言語\"; s[-2:] + s[:5]", "言語Gengo"},
+		{"let xs = freeze([1, 2, 3]); try { xs[1:] |> append(4) } catch (e) { e.message }", "cannot modify frozen ARRAY"},
+		{"let xs = [1, 2, 3]; let ys = xs[:1]; append(ys, 4); xs", "[1, 2, 3]"},
+	}
+
+	appendFn := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		if err := args[0].(*object.Array).Append(args[1]); err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return args[0]
+	}}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			i := New(e.engine)
+			if err := i.Set("append", appendFn); err != nil {
+				t.Fatalf("%s: unexpected error: %s", e.name, err)
+			}
+
+			result, err := i.Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn sum(xs, acc) { if (len(xs) == 0) { acc } else { sum(rest(xs), acc + first(xs)) } } sum(range(100000), 0)", "4999950000"},
+		{"fn loop(n) { if (n == 0) { return \"done\" } return loop(n - 1) } loop(100000)", "done"},
+		// A function called in tail position takes the place of its caller in the stack.
+		{"fn f() { throw 1 } fn g() { f() } try { g() } catch (e) { e.stack }", "[f]"},
+		{"fn f() { throw 1 } fn g() { try { return f() } finally { 0 } } try { g() } catch (e) { e.stack }", "[f, g]"},
+		// A return in an expression leaves the function with the value of the call.
+		{"fn g(x) { x } fn h() { [if (true) { return g(1) } else { 0 }] } h()", "1"},
+		{"fn g(x) { x } fn h() { let y = if (true) { return g(2) } else { 0 }; y + 1 } h()", "2"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			i := New(e.engine)
+			i.SetLimits(object.Limits{MaxCallDepth: 100})
+			result, err := i.Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let greet = fn(name, greeting = "hello") { greeting + " " + name }; [greet("ada"), greet("bob", "hi")]`, "[hello ada, hi bob]"},
+		{"let sum = fn(...xs) { reduce(xs, fn(a, b) { a + b }, 0) }; [sum(), sum(1, 2, 3), sum(...range(5))]", "[0, 6, 10]"},
+		{"let f = fn(a, b = 1, c = 2) { [a, b, c] }; [f(0, c: 9), f(c: 3, a: 1)]", "[[0, 1, 9], [1, 1, 3]]"},
+		{"let log = fn(level, ...parts) { [level, parts] }; log(...[1, 2, 3], 4)", "[1, [2, 3, 4]]"},
+		{"map([1, 2], fn(x, step = 10) { x + step })", "[11, 12]"},
+		{"try { fn(a) { a }() } catch (e) { e.message }", "wrong number of arguments: want=1, got=0"},
+		{"try { fn(a, b = 1) { a }(1, 2, 3) } catch (e) { e.message }", "wrong number of arguments: want=1 to 2, got=3"},
+		{"try { fn(a) { a }(1, a: 2) } catch (e) { e.message }", "multiple values for parameter a"},
+		{"let f = fn(a, b = 1) { a }; try { f(b: 2) } catch (e) { e.message }", "missing argument for parameter a"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			result, err := New(e.engine).Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b, ...rest] = [1, 2, 3, 4]; [b, a, rest]", "[2, 1, [3, 4]]"},
+		{`let person = {"name": "ada", "age": 36}; let {name, age} = person; [name, age]`, "[ada, 36]"},
+		{`let {"pos": [x, y]} = {"pos": [3, 4]}; x * y`, "12"},
+		{`let greet = fn({name}, [greeting, ..._]) { greeting + " " + name }; greet({"name": "ada"}, ["hi", "hey"])`, "hi ada"},
+		{"let sum = fn([x, ...rest]) { if (len(rest) == 0) { x } else { x + sum(rest) } }; sum([1, 2, 3])", "6"},
+		{"map([[1, 2], [3, 4]], fn([a, b]) { a * b })", "[2, 12]"},
+		{"try { let [a] = [1, 2]; } catch (e) { e.message }", "cannot destructure ARRAY with [a]: want length 1, got 2"},
+		{`try { let {"a": [b]} = {"a": 1}; } catch (e) { e.message }`, `cannot destructure HASH with {"a": [b]}: key "a": want ARRAY, got INTEGER`},
+		{"let f = fn([a]) { a }; try { f(1) } catch (e) { e.stack }", "[f]"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			result, err := New(e.engine).Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match ([1, 2, 3]) { [a, ...r] => r }", "[2, 3]"},
+		{"match ([]) { [a, ...r] => r, [] => \"empty\" }", "empty"},
+		{`match ({"name": "ada", "age": 36}) { {"name": n} => n }`, "ada"},
+		{"match (7) { n if n < 5 => \"small\", _ => \"large\" }", "large"},
+		{"match (-3) { -3 => 1, _ => 2 }", "1"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, "2"},
+		{"match (1) { 2 => 1 }", "null"},
+		{"let f = fn(x) { match (x) { [a, b] => { return a + b } }; 0 }; [f([1, 2]), f(1)]", "[3, 0]"},
+		{"let sum = fn(xs) { match (xs) { [] => 0, [x, ...rest] => x + sum(rest) } }; sum([1, 2, 3, 4])", "10"},
+		{"let x = 1; match (5) { x if false => 0, _ => x }", "1"},
+		{"const x = 1; match (2) { x => x }", "2"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			result, err := New(e.engine).Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %s", e.name, tt.input, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%s", e.name, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestMatchBindingsScope(t *testing.T) {
+	for _, e := range engines {
+		_, err := New(e.engine).Eval("match (5) { y => y }; y")
+		if err == nil {
+			t.Errorf("%s: binding of a match arm visible after the match", e.name)
+		}
+	}
+}
+
+func TestMatchWarnings(t *testing.T) {
+	for _, e := range engines {
+		interpreter := New(e.engine)
+		program, err := interpreter.Compile("let x = true; match (x) { true => 1 }")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if len(program.Warnings()) != 1 {
+			t.Fatalf("%s: wrong number of warnings. want=1, got=%v", e.name, program.Warnings())
+		}
+
+		result, err := interpreter.Run(program)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "1" {
+			t.Errorf("%s: wrong result. want=1, got=%s", e.name, result.Inspect())
+		}
+	}
+}
+
+func TestFinallyOrder(t *testing.T) {
+	input := `
+		let f = fn(fail) {
+			try {
+				print("try ");
+				if (fail) { throw "fail" }
+				"ok"
+			} catch (e) {
+				print("catch ");
+				e.message
+			} finally {
+				print("finally ")
+			}
+		};
+		f(false) + f(true)`
+
+	for _, e := range engines {
+		var out bytes.Buffer
+		i := New(e.engine)
+		i.SetOutput(&out)
+
+		result, err := i.Eval(input)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "okfail" {
+			t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+		}
+		if out.String() != "try finally try catch finally " {
+			t.Errorf("%s: wrong output. got=%q", e.name, out.String())
+		}
+	}
+}
+
+func TestUncaughtExceptions(t *testing.T) {
+	for _, e := range engines {
+		var runtimeErr *RuntimeError
+		_, err := New(e.engine).Eval(`let f = fn() { throw {"code": 1} }; let g = fn() { f(); null }; g()`)
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("%s: expected a *RuntimeError, got=%T (%v)", e.name, err, err)
+		}
+		if runtimeErr.Message != "{code: 1}" || runtimeErr.Value.Inspect() != "{code: 1}" {
+			t.Errorf("%s: wrong error. got message=%q, value=%v", e.name, runtimeErr.Message, runtimeErr.Value)
+		}
+		if strings.Join(runtimeErr.Stack, " ") != "f g" {
+			t.Errorf("%s: wrong stack. got=%v", e.name, runtimeErr.Stack)
+		}
+
+		// Limit errors can't be caught.
+		i := New(e.engine)
+		i.SetLimits(object.Limits{MaxCallDepth: 10})
+		var depthErr *object.CallDepthError
+		_, err = i.Eval("let f = fn() { 1 + f() }; try { f() } catch { 1 } finally { 2 }")
+		if !errors.As(err, &depthErr) {
+			t.Errorf("%s: expected a *object.CallDepthError, got=%T (%v)", e.name, err, err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package gengo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Gengo/object"
+)
+
+// imports counts the calls of the testCountImport builtin, which modules call to
+// show how often they run.
+var imports int
+
+// newCountingImports creates an Interpreter with the testCountImport builtin, and
+// resets the count.
+func newCountingImports(t *testing.T, engine Engine) *Interpreter {
+	t.Helper()
+
+	imports = 0
+	i := New(engine)
+	if err := i.RegisterFunc("testCountImport", func() { imports++ }); err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// writeFiles writes files, named by paths relative to dir, and returns dir.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.gg": `
+			import "util/math.gg" as math;
+			import "greet.gg" as greet;
+			import "./util/../util/math.gg" as again;
+			[math.add(1, 2), math.square(3), greet.hello("world"), math.scale, math == again]`,
+		"util/math.gg": `
+			testCountImport();
+			let factor = 10;
+			export let scale = factor * 2;
+			export let add = fn(a, b) { a + b };
+			export let square = fn(x) { x * x };`,
+		"lib/greet.gg": `
+			import "strings.gg" as s;
+			export let hello = fn(name) { s.join("hello", name) };`,
+		"lib/strings.gg": `
+			import "../util/math.gg" as math;
+			export let join = fn(a, b) { a + " " + b };`,
+	})
+
+	for _, e := range engines {
+		i := newCountingImports(t, e.engine)
+		i.SetSearchPath(filepath.Join(dir, "lib"))
+
+		result, err := i.EvalFile(filepath.Join(dir, "main.gg"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "[3, 9, hello world, 20, true]" {
+			t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+		}
+		if imports != 1 {
+			t.Errorf("%s: math.gg was run %d times, want once", e.name, imports)
+		}
+	}
+}
+
+func TestExportDestructuring(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"point.gg": `export let [x, y] = [1, 2]; export let {z} = {"z": 3};`,
+	})
+
+	for _, e := range engines {
+		i := New(e.engine)
+		program, err := i.compile(`import "point.gg" as p; [p.x, p.y, p.z]`, filepath.Join(dir, "main.gg"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		result, err := i.Run(program)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "[1, 2, 3]" {
+			t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+		}
+	}
+}
+
+func TestExportFunctionDeclarations(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"parity.gg": `
+			export fn even(n) { if (n == 0) { true } else { odd(n - 1) } }
+			export fn odd(n) { if (n == 0) { false } else { even(n - 1) } }
+			export let answer = twice(21);
+			fn twice(n) { n * 2 }`,
+	})
+
+	for _, e := range engines {
+		i := New(e.engine)
+		program, err := i.compile(`import "parity.gg" as p; [p.even(10), p.odd(7), p.answer, try { p.twice } catch (e) { "hidden" }]`, filepath.Join(dir, "main.gg"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		result, err := i.Run(program)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "[true, true, 42, hidden]" {
+			t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.gg":       `import "b.gg" as b; export let a = 1;`,
+		"b.gg":       `import "a.gg" as a; export let b = 2;`,
+		"private.gg": `let secret = 1; export let public = 2;`,
+		"scope.gg":   `export let x = y;`,
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "a.gg" as a;`, "import cycle: " + filepath.Join(dir, "a.gg") + " -> " +
+			filepath.Join(dir, "b.gg") + " -> " + filepath.Join(dir, "a.gg")},
+		{`import "missing.gg" as m;`, "module not found: missing.gg"},
+		{`import "private.gg" as p; p.secret`, "unknown attribute: MODULE.secret"},
+		// Modules can't see the bindings of the programs importing them.
+		{`let y = 1; import "scope.gg" as s;`, "y"},
+		{`let f = fn() { import "private.gg" as p; }; f()`, "import is only allowed at the top level"},
+		{`if (true) { export let x = 1; }`, "export is only allowed at the top level"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			i := New(e.engine)
+			program, err := i.compile(tt.input, filepath.Join(dir, "main.gg"))
+			if err == nil {
+				_, err = i.Run(program)
+			}
+			if err == nil {
+				t.Fatalf("%s: %q: expected an error", e.name, tt.input)
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("%s: %q: wrong error. want=%q, got=%q", e.name, tt.input, tt.expected, err.Error())
+			}
+		}
+	}
+}
+
+func TestImportOutsideRoot(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app/main.gg": `import "../secret.gg" as secret;`,
+		"secret.gg":   `password hunter2`,
+	})
+
+	for _, e := range engines {
+		_, err := New(e.engine).EvalFile(filepath.Join(dir, "app", "main.gg"))
+		if err == nil || !strings.Contains(err.Error(), "outside the module directories") {
+			t.Errorf("%s: wrong error. got=%v", e.name, err)
+		}
+		if err != nil && strings.Contains(err.Error(), "hunter2") {
+			t.Errorf("%s: error quotes the file: %s", e.name, err)
+		}
+	}
+}
+
+func TestImportCycleThroughMainFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.gg":  `import "other.gg" as other;`,
+		"other.gg": `import "main.gg" as main;`,
+	})
+
+	for _, e := range engines {
+		_, err := New(e.engine).EvalFile(filepath.Join(dir, "main.gg"))
+		if err == nil || !strings.Contains(err.Error(), "import cycle") {
+			t.Errorf("%s: expected an import cycle error, got=%v", e.name, err)
+		}
+	}
+}
+
+func TestImportsPersistBetweenRuns(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"counter.gg": `testCountImport(); export let value = 1;`,
+	})
+
+	for _, e := range engines {
+		i := newCountingImports(t, e.engine)
+		i.SetSearchPath(dir)
+
+		for n := 0; n < 2; n++ {
+			result, err := i.Eval(`import "counter.gg" as c; c.value`)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", e.name, err)
+			}
+			if result.Inspect() != "1" {
+				t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+			}
+		}
+		if imports != 1 {
+			t.Errorf("%s: counter.gg was run %d times, want once", e.name, imports)
+		}
+	}
+}
+
+func TestImportNativeModule(t *testing.T) {
+	input := `
+		import "strings" as strings;
+		let words = strings.split("héllo wörld", " ");
+		strings.format("%s|%d|%s", strings.upper(words[0]), strings.indexOf("héllo", "l"), strings.join(words, "+"))`
+
+	for _, e := range engines {
+		result, err := New(e.engine).Eval(input)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "HÉLLO|2|héllo+wörld" {
+			t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+		}
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	input := `
+		import "math" as math;
+		math.seed(7);
+		let a = math.randomInt(1000);
+		math.seed(7);
+		let b = math.randomInt(1000);
+		[a == b, math.max(1, 5, 3), int(math.floor(math.pi)), str(parseInt("ff", 16)), float("1.5") * 2.0]`
+
+	for _, e := range engines {
+		result, err := New(e.engine).Eval(input)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != "[true, 5, 3, 255, 3.000000]" {
+			t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+		}
+	}
+}
+
+func TestRandomPerInterpreter(t *testing.T) {
+	for _, e := range engines {
+		seeded, other := New(e.engine), New(e.engine)
+
+		if _, err := seeded.Eval(`import "math" as math; math.seed(7);`); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		// Seeding another interpreter leaves the numbers of the first alone.
+		want, err := other.Eval(`import "math" as math; math.seed(7); math.seed(8); math.seed(7); math.randomInt(1000000)`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if _, err := New(e.engine).Eval(`import "math" as math; math.seed(1);`); err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		got, err := seeded.Eval(`import "math" as math; math.randomInt(1000000)`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if got.Inspect() != want.Inspect() {
+			t.Errorf("%s: wrong number after seeding. want=%s, got=%s", e.name, want.Inspect(), got.Inspect())
+		}
+	}
+}
+
+func TestJSONModule(t *testing.T) {
+	input := `
+		import "json" as json;
+		let payload = json.parse(text);
+		let user = payload.users[0];
+		json.stringify({"name": user.name, "next": user.age + 1})`
+
+	for _, e := range engines {
+		i := New(e.engine)
+		if err := i.Set("text", &object.String{Value: `{"users": [{"name": "Ann", "age": 31}]}`}); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := i.Eval(input)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if result.Inspect() != `{"name":"Ann","next":32}` {
+			t.Errorf("%s: wrong result. got=%s", e.name, result.Inspect())
+		}
+	}
+}
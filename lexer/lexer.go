@@ -31,6 +31,10 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -62,13 +66,51 @@ func (l *Lexer) NextToken() token.Token {
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.COMPOSE, Literal: ">>"}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.PIPE, Literal: "|>"}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '?':
+		if l.peekChar() == '?' {
+			l.readChar()
+			tok = token.Token{Type: token.COALESCE, Literal: "??"}
+		} else if l.peekChar() == '.' && !(l.readPosition+1 < len(l.input) && isDigit(l.input[l.readPosition+1])) {
+			// A digit after the dot means a ternary, as in a ?.5 : 1, not an optional chain.
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL, Literal: "?."}
+		} else {
+			tok = newToken(token.QUESTION, l.ch)
+		}
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '<' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.UNTIL, Literal: "..<"}
+		} else if l.peekChar() == '.' {
+			l.readChar()
+			tok = token.Token{Type: token.RANGE, Literal: ".."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -91,8 +133,9 @@ func (l *Lexer) NextToken() token.Token {
 			return tok // have to return to avoid reading the next char
 		} else if isDigit(l.ch) {
 			num := l.readNumber()
-			// If the current character is a period then it's a float.
-			if l.ch == '.' {
+			// If the current character is a period then it's a float, unless the
+			// period starts a range, as in 1..10.
+			if l.ch == '.' && l.peekChar() != '.' {
 				l.readChar()
 				tok.Type = token.FLOAT
 				tok.Literal = num + "." + l.readNumber()
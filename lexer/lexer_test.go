@@ -33,6 +33,13 @@ func TestNextToken(t *testing.T) {
 	"\"foobar\""
 	[1, 2];
 	{"foo": "bar"}
+	row.name;
+	match (x) { [a, ...b] => a }
+	a ? b : c ?? null;
+	a?.b?.[0];
+	a ?.5 : 1;
+	xs |> f >> g > h | i;
+	1..10 0..<n xs[1:] 1.5.b
 	`
 
 	tests := []struct {
@@ -132,6 +139,71 @@ func TestNextToken(t *testing.T) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "row"},
+		{token.DOT, "."},
+		{token.IDENT, "name"},
+		{token.SEMICOLON, ";"},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "b"},
+		{token.RBRACKET, "]"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "a"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "a"},
+		{token.QUESTION, "?"},
+		{token.IDENT, "b"},
+		{token.COLON, ":"},
+		{token.IDENT, "c"},
+		{token.COALESCE, "??"},
+		{token.NULL, "null"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.OPTIONAL, "?."},
+		{token.IDENT, "b"},
+		{token.OPTIONAL, "?."},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.QUESTION, "?"},
+		{token.DOT, "."},
+		{token.INT, "5"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "xs"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.COMPOSE, ">>"},
+		{token.IDENT, "g"},
+		{token.GT, ">"},
+		{token.IDENT, "h"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "i"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.RANGE, ".."},
+		{token.INT, "10"},
+		{token.INT, "0"},
+		{token.UNTIL, "..<"},
+		{token.IDENT, "n"},
+		{token.IDENT, "xs"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.RBRACKET, "]"},
+		{token.FLOAT, "1.5"},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
 		{token.EOF, ""},
 	}
 
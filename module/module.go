@@ -0,0 +1,184 @@
+// Package module finds, reads and parses the files imported by programs. The
+// evaluator and the compiler both use a Loader to resolve import statements.
+package module
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Gengo/ast"
+	"Gengo/lexer"
+	"Gengo/parser"
+)
+
+// Loader resolves the paths of import statements to files and parses them.
+type Loader struct {
+	// SearchPath The directories a module is looked for in when it isn't found
+	// relative to the importing file.
+	SearchPath []string
+	// Root The directory the modules of the program are in, the working directory
+	// if empty. A module is only loaded from a file in Root or in one of the
+	// directories of the search path, so a program can't read other files by
+	// importing them.
+	Root string
+	// AnyPath Lets modules be loaded from any file, including absolute paths and
+	// paths leading out of Root and the search path.
+	AnyPath bool
+	// ReadFile Reads the file called name. It is os.ReadFile if nil.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// NewLoader Creates a loader that looks for modules in searchPath.
+func NewLoader(searchPath ...string) *Loader {
+	return &Loader{SearchPath: searchPath}
+}
+
+// Source A parsed module.
+type Source struct {
+	// Path The file the module was read from. It identifies the module, so the same
+	// file imported twice is one module.
+	Path    string
+	Program *ast.Program
+}
+
+// ParseError The errors found while parsing a module.
+type ParseError struct {
+	Path   string
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse errors in module %s:\n\t%s", e.Path, strings.Join(e.Errors, "\n\t"))
+}
+
+// Load Reads and parses the module path imported by the file importer. path is
+// resolved relative to the directory of importer, which is the working directory if
+// importer is empty, and then relative to each directory of the search path. Files
+// outside Root and the search path are skipped unless AnyPath is set.
+func (l *Loader) Load(importer, path string) (*Source, error) {
+	name, data, err := l.find(importer, path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(data)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Path: name, Errors: p.Errors()}
+	}
+	for _, s := range program.Statements {
+		if _, ok := s.(*ast.ReturnStatement); ok {
+			return nil, fmt.Errorf("module %s: return is not allowed at the top level of a module", name)
+		}
+	}
+
+	return &Source{Path: name, Program: program}, nil
+}
+
+// Read Reads the file name with the loader's ReadFile.
+func (l *Loader) Read(name string) ([]byte, error) {
+	if l.ReadFile != nil {
+		return l.ReadFile(name)
+	}
+	return os.ReadFile(name)
+}
+
+func (l *Loader) find(importer, path string) (string, []byte, error) {
+	if path == "" {
+		return "", nil, fmt.Errorf("module path must not be empty")
+	}
+
+	var candidates []string
+	if filepath.IsAbs(path) {
+		candidates = []string{path}
+	} else {
+		candidates = append(candidates, filepath.Join(filepath.Dir(importer), path))
+		for _, dir := range l.SearchPath {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+	}
+
+	outside := false
+	for _, name := range candidates {
+		name = filepath.Clean(name)
+		if !l.allowed(name) {
+			outside = true
+			continue
+		}
+		data, err := l.Read(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("could not read module %s: %w", path, err)
+		}
+		return name, data, nil
+	}
+
+	if outside {
+		return "", nil, fmt.Errorf("module %s is outside the module directories", path)
+	}
+	return "", nil, fmt.Errorf("module not found: %s", path)
+}
+
+// allowed reports whether a module may be loaded from the file name.
+func (l *Loader) allowed(name string) bool {
+	if l.AnyPath {
+		return true
+	}
+
+	root := l.Root
+	if root == "" {
+		root = "."
+	}
+	for _, dir := range append([]string{root}, l.SearchPath...) {
+		if within(dir, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether the file name is in the directory dir or below it.
+func within(dir, name string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	name, err = filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(dir, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Cycle Returns the error for importing path while loading the modules in
+// importing, or nil if path isn't one of them.
+func Cycle(importing []string, path string) error {
+	for i, name := range importing {
+		if name == path {
+			cycle := append(append([]string{}, importing[i:]...), path)
+			return fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	return nil
+}
+
+// Exports Returns the names the top-level statements of program export.
+func Exports(program *ast.Program) []string {
+	var names []string
+	for _, s := range program.Statements {
+		if export, ok := s.(*ast.ExportStatement); ok {
+			for _, name := range export.Names() {
+				names = append(names, name.Value)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,166 @@
+package module
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testLoader returns a loader that reads files from files instead of the file system.
+func testLoader(files map[string]string, searchPath ...string) *Loader {
+	loader := NewLoader(searchPath...)
+	loader.ReadFile = func(name string) ([]byte, error) {
+		src, ok := files[filepath.ToSlash(name)]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return []byte(src), nil
+	}
+	return loader
+}
+
+func TestLoad(t *testing.T) {
+	loader := testLoader(map[string]string{
+		"app/util.gg":    "export let a = 1;",
+		"app/lib/sub.gg": "export let b = 2;",
+		"lib/shared.gg":  "export let c = 3;",
+		"app/shared.gg":  "export let d = 4;",
+	}, "lib")
+
+	tests := []struct {
+		importer string
+		path     string
+		expected string
+	}{
+		{"app/main.gg", "util.gg", "app/util.gg"},
+		{"app/main.gg", "./lib/sub.gg", "app/lib/sub.gg"},
+		{"app/lib/sub.gg", "../util.gg", "app/util.gg"},
+		// Relative to the importing file before the search path.
+		{"app/main.gg", "shared.gg", "app/shared.gg"},
+		{"main.gg", "shared.gg", "lib/shared.gg"},
+		{"", "shared.gg", "lib/shared.gg"},
+	}
+
+	for _, tt := range tests {
+		source, err := loader.Load(tt.importer, tt.path)
+		if err != nil {
+			t.Fatalf("%s from %s: unexpected error: %s", tt.path, tt.importer, err)
+		}
+		if filepath.ToSlash(source.Path) != tt.expected {
+			t.Errorf("%s from %s: wrong path. want=%s, got=%s", tt.path, tt.importer, tt.expected, source.Path)
+		}
+		if len(source.Program.Statements) != 1 {
+			t.Errorf("%s from %s: wrong number of statements. got=%d", tt.path, tt.importer, len(source.Program.Statements))
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	loader := testLoader(map[string]string{
+		"broken.gg":  "let 1;",
+		"returns.gg": "return 1;",
+	})
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"missing.gg", "module not found: missing.gg"},
+		{"", "module path must not be empty"},
+		{"broken.gg", "parse errors in module broken.gg:\n\texpected next token to be IDENT, got INT instead"},
+		{"returns.gg", "module returns.gg: return is not allowed at the top level of a module"},
+	}
+
+	for _, tt := range tests {
+		_, err := loader.Load("main.gg", tt.path)
+		if err == nil {
+			t.Fatalf("%q: expected an error", tt.path)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%q", tt.path, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestLoadOutsideRoot(t *testing.T) {
+	files := map[string]string{
+		"app/main.gg":      "export let a = 1;",
+		"app/lib/util.gg":  "export let b = 2;",
+		"secret.gg":        "let 1;",
+		"/abs/absolute.gg": "export let c = 3;",
+	}
+
+	tests := []struct {
+		importer string
+		path     string
+		expected string
+	}{
+		{"app/main.gg", "lib/util.gg", ""},
+		{"app/lib/util.gg", "../main.gg", ""},
+		{"app/main.gg", "../secret.gg", "module ../secret.gg is outside the module directories"},
+		{"app/lib/util.gg", "../../secret.gg", "module ../../secret.gg is outside the module directories"},
+		{"app/main.gg", "/abs/absolute.gg", "module /abs/absolute.gg is outside the module directories"},
+		{"app/main.gg", "../missing.gg", "module ../missing.gg is outside the module directories"},
+	}
+
+	loader := testLoader(files, "app/lib")
+	loader.Root = "app"
+	for _, tt := range tests {
+		_, err := loader.Load(tt.importer, tt.path)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("%s from %s: unexpected error: %s", tt.path, tt.importer, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s from %s: wrong error. want=%q, got=%v", tt.path, tt.importer, tt.expected, err)
+		}
+	}
+
+	loader.AnyPath = true
+	source, err := loader.Load("app/main.gg", "/abs/absolute.gg")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if filepath.ToSlash(source.Path) != "/abs/absolute.gg" {
+		t.Errorf("wrong path. got=%s", source.Path)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	importing := []string{"main.gg", "a.gg", "b.gg"}
+
+	if err := Cycle(importing, "c.gg"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	err := Cycle(importing, "a.gg")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if err.Error() != "import cycle: a.gg -> b.gg -> a.gg" {
+		t.Errorf("wrong error. got=%q", err.Error())
+	}
+}
+
+func TestExports(t *testing.T) {
+	loader := testLoader(map[string]string{
+		"util.gg": `
+			let hidden = 1;
+			export let [a, b] = [1, 2];
+			export const c = 3;
+			export fn d() { 4 }
+			fn e() { 5 }`,
+	})
+
+	source, err := loader.Load("", "util.gg")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	names := Exports(source.Program)
+	if strings.Join(names, " ") != "a b c d" {
+		t.Errorf("wrong exports. got=%v", names)
+	}
+}
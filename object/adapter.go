@@ -0,0 +1,228 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	objectType  = reflect.TypeOf((*Object)(nil)).Elem()
+	runtimeType = reflect.TypeOf((*Runtime)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// NewBuiltinFunc adapts fn, a Go function, to a builtin called name.
+//
+// Parameters and results may be bools, strings, integers, floats, Objects, or slices
+// of those. Arguments are checked against the parameters before fn is called, so fn
+// only ever sees values of the types it asked for. fn may return nothing, a value,
+// an error, or a value and an error; a non-nil error becomes an error object. If
+// the first parameter is a Runtime, it receives the runtime executing the call, and
+// functions passed for func parameters are called through it. A function of type
+// BuiltinFunction or RuntimeFunction is used as it is.
+func NewBuiltinFunc(name string, fn interface{}) (*Builtin, error) {
+	switch fn := fn.(type) {
+	case BuiltinFunction:
+		return &Builtin{Fn: fn}, nil
+	case func(args ...Object) Object:
+		return &Builtin{Fn: fn}, nil
+	case RuntimeFunction:
+		return &Builtin{RuntimeFn: fn}, nil
+	case func(rt Runtime, args ...Object) Object:
+		return &Builtin{RuntimeFn: fn}, nil
+	}
+
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return nil, fmt.Errorf("builtin %s must be a function, got %T", name, fn)
+	}
+
+	fnType := value.Type()
+	withRuntime := fnType.NumIn() > 0 && fnType.In(0) == runtimeType
+
+	for i := 0; i < fnType.NumIn(); i++ {
+		if i == 0 && withRuntime {
+			continue
+		}
+
+		param := fnType.In(i)
+		if fnType.IsVariadic() && i == fnType.NumIn()-1 {
+			param = param.Elem()
+		}
+		if !isSupportedType(param) {
+			return nil, fmt.Errorf("builtin %s: unsupported parameter type %s", name, fnType.In(i))
+		}
+	}
+
+	switch fnType.NumOut() {
+	case 0:
+	case 1:
+		if fnType.Out(0) != errorType && !isSupportedType(fnType.Out(0)) {
+			return nil, fmt.Errorf("builtin %s: unsupported result type %s", name, fnType.Out(0))
+		}
+	case 2:
+		if !isSupportedType(fnType.Out(0)) || fnType.Out(1) != errorType {
+			return nil, fmt.Errorf("builtin %s: results must be a value and an error", name)
+		}
+	default:
+		return nil, fmt.Errorf("builtin %s: too many results", name)
+	}
+
+	adapter := func(rt Runtime, args ...Object) Object {
+		if rt == nil {
+			rt = Caller(callBuiltin)
+		}
+
+		in, errObj := goArguments(name, fnType, withRuntime, rt, args)
+		if errObj != nil {
+			return errObj
+		}
+
+		return fromResults(name, value.Call(in))
+	}
+
+	return &Builtin{RuntimeFn: adapter}, nil
+}
+
+// RegisterFunc adapts fn with NewBuiltinFunc and registers it as name with the
+// default registry.
+func RegisterFunc(name string, fn interface{}) error {
+	return defaultRegistry.RegisterFunc(name, fn)
+}
+
+func isSupportedType(t reflect.Type) bool {
+	return supportedType(t, map[reflect.Type]bool{})
+}
+
+// supportedType reports whether values of type t can be converted to and from
+// objects. seen holds the types being checked, so recursive types terminate.
+func supportedType(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t.Implements(objectType) || seen[t] {
+		return true
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return supportedType(t.Elem(), seen)
+	case reflect.Map:
+		return supportedType(t.Key(), seen) && supportedType(t.Elem(), seen)
+	case reflect.Struct:
+		for _, field := range structFields(t) {
+			if !supportedType(field.typ, seen) {
+				return false
+			}
+		}
+		return true
+	case reflect.Func:
+		if checkFuncType(t) != nil {
+			return false
+		}
+		for i := 0; i < t.NumIn(); i++ {
+			if !supportedType(t.In(i), seen) {
+				return false
+			}
+		}
+		for i := 0; i < t.NumOut(); i++ {
+			if t.Out(i) != errorType && !supportedType(t.Out(i), seen) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func goArguments(name string, fnType reflect.Type, withRuntime bool, rt Runtime, args []Object) ([]reflect.Value, *Error) {
+	var in []reflect.Value
+	offset := 0
+	if withRuntime {
+		in = append(in, reflect.ValueOf(&rt).Elem())
+		offset = 1
+	}
+
+	numParams := fnType.NumIn() - offset
+	if fnType.IsVariadic() {
+		if len(args) < numParams-1 {
+			return nil, newError("wrong number of arguments. got=%d, want>=%d", len(args), numParams-1)
+		}
+	} else if len(args) != numParams {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), numParams)
+	}
+
+	for i, arg := range args {
+		var param reflect.Type
+		if fnType.IsVariadic() && i >= numParams-1 {
+			param = fnType.In(fnType.NumIn() - 1).Elem()
+		} else {
+			param = fnType.In(i + offset)
+		}
+
+		value, err := toGoValue(arg, param, rt.Call)
+		if err != nil {
+			return nil, newError("argument %d to `%s` %s", i+1, name, err)
+		}
+		in = append(in, value)
+	}
+
+	return in, nil
+}
+
+// expectedType describes the objects a parameter of type t accepts.
+func expectedType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return string(BOOLEAN)
+	case reflect.String:
+		return string(STRING)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return string(INTEGER)
+	case reflect.Float32, reflect.Float64:
+		return string(FLOAT)
+	case reflect.Slice:
+		return string(ARRAY)
+	case reflect.Ptr:
+		if obj, ok := reflect.New(t.Elem()).Interface().(Object); ok {
+			return string(obj.Type())
+		}
+	}
+	return t.String()
+}
+
+func objectTypeOf(obj Object) ObjectType {
+	if obj == nil {
+		return NULL
+	}
+	return obj.Type()
+}
+
+// fromResults converts the results of an adapted function to the builtin's result.
+func fromResults(name string, results []reflect.Value) Object {
+	if len(results) == 0 {
+		return nil
+	}
+
+	last := results[len(results)-1]
+	if last.Type() == errorType {
+		if !last.IsNil() {
+			return ToError(last.Interface().(error))
+		}
+		if len(results) == 1 {
+			return nil
+		}
+	}
+
+	obj, err := fromGoValue(results[0])
+	if err != nil {
+		return newError("result of `%s`: %s", name, err)
+	}
+	return obj
+}
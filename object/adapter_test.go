@@ -0,0 +1,174 @@
+package object
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewBuiltinFunc(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       interface{}
+		args     []Object
+		expected interface{}
+	}{
+		{
+			"repeat",
+			func(s string, n int) (string, error) { return strings.Repeat(s, n), nil },
+			[]Object{&String{Value: "ab"}, &Integer{Value: 3}},
+			"ababab",
+		},
+		{
+			"fail",
+			func(s string) (string, error) { return "", errors.New("failed: " + s) },
+			[]Object{&String{Value: "x"}},
+			&Error{Message: "failed: x"},
+		},
+		{
+			"half",
+			func(f float64) float64 { return f / 2 },
+			[]Object{&Integer{Value: 5}},
+			2.5,
+		},
+		{
+			"negate",
+			func(b bool) bool { return !b },
+			[]Object{&Boolean{Value: true}},
+			false,
+		},
+		{
+			"sum",
+			func(ns ...int64) int64 {
+				var sum int64
+				for _, n := range ns {
+					sum += n
+				}
+				return sum
+			},
+			[]Object{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}},
+			int64(6),
+		},
+		{
+			"double",
+			func(ns []int) []int {
+				for i := range ns {
+					ns[i] *= 2
+				}
+				return ns
+			},
+			[]Object{&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}},
+			"[2, 4]",
+		},
+		{
+			"identity",
+			func(obj Object) Object { return obj },
+			[]Object{&String{Value: "same"}},
+			"same",
+		},
+		{
+			"nothing",
+			func() {},
+			[]Object{},
+			nil,
+		},
+		{
+			"repeat",
+			func(s string, n int) string { return strings.Repeat(s, n) },
+			[]Object{&String{Value: "ab"}},
+			&Error{Message: "wrong number of arguments. got=1, want=2"},
+		},
+		{
+			"sum",
+			func(first int, rest ...int) int { return first },
+			[]Object{},
+			&Error{Message: "wrong number of arguments. got=0, want>=1"},
+		},
+		{
+			"repeat",
+			func(s string, n int) string { return strings.Repeat(s, n) },
+			[]Object{&Integer{Value: 1}, &Integer{Value: 2}},
+			&Error{Message: "argument 1 to `repeat` must be STRING, got INTEGER"},
+		},
+		{
+			"small",
+			func(n int8) int8 { return n },
+			[]Object{&Integer{Value: 300}},
+			&Error{Message: "argument 1 to `small` overflows int8"},
+		},
+		{
+			"count",
+			func(ns []int) int { return len(ns) },
+			[]Object{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "two"}}}},
+			&Error{Message: "argument 1 to `count` element 1 must be INTEGER, got STRING"},
+		},
+		{
+			"length",
+			func(arr *Array) int { return len(arr.Elements) },
+			[]Object{&Hash{}},
+			&Error{Message: "argument 1 to `length` must be ARRAY, got HASH"},
+		},
+	}
+
+	for _, tt := range tests {
+		builtin, err := NewBuiltinFunc(tt.name, tt.fn)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+
+		result := builtin.Invoke(nil, tt.args...)
+		switch expected := tt.expected.(type) {
+		case nil:
+			if result != nil {
+				t.Errorf("%s: expected nil, got=%T (%+v)", tt.name, result, result)
+			}
+		case *Error:
+			errObj, ok := result.(*Error)
+			if !ok {
+				t.Errorf("%s: expected an error, got=%T (%+v)", tt.name, result, result)
+				continue
+			}
+			if errObj.Message != expected.Message {
+				t.Errorf("%s: wrong error message. want=%q, got=%q", tt.name, expected.Message, errObj.Message)
+			}
+		case string:
+			if result.Inspect() != expected {
+				t.Errorf("%s: wrong result. want=%s, got=%s", tt.name, expected, result.Inspect())
+			}
+		case int64:
+			integer, ok := result.(*Integer)
+			if !ok || integer.Value != expected {
+				t.Errorf("%s: wrong result. want=%d, got=%+v", tt.name, expected, result)
+			}
+		case float64:
+			float, ok := result.(*Float)
+			if !ok || float.Value != expected {
+				t.Errorf("%s: wrong result. want=%f, got=%+v", tt.name, expected, result)
+			}
+		case bool:
+			boolean, ok := result.(*Boolean)
+			if !ok || boolean.Value != expected {
+				t.Errorf("%s: wrong result. want=%t, got=%+v", tt.name, expected, result)
+			}
+		}
+	}
+}
+
+func TestNewBuiltinFuncUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   interface{}
+	}{
+		{"notAFunction", 42},
+		{"channel", func(c chan int) {}},
+		{"channelResult", func() chan int { return nil }},
+		{"noError", func() (int, int) { return 0, 0 }},
+		{"tooMany", func() (int, int, error) { return 0, 0, nil }},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewBuiltinFunc(tt.name, tt.fn); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
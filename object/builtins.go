@@ -0,0 +1,226 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// MaxBuiltins is the number of builtins the registry can hold.
+const MaxBuiltins = 1 << 16
+
+// BuiltinDefinition A builtin and the name programs call it by.
+type BuiltinDefinition struct {
+	Name    string
+	Builtin *Builtin
+}
+
+// defaultBuiltins The functions available to every program.
+func defaultBuiltins() []BuiltinDefinition {
+	return []BuiltinDefinition{
+		{"len", &Builtin{Fn: lenFunc}},
+		{"first", &Builtin{RuntimeFn: firstFunc}},
+		{"last", &Builtin{RuntimeFn: lastFunc}},
+		{"rest", &Builtin{RuntimeFn: restFunc}},
+		{"push", &Builtin{RuntimeFn: pushFunc}},
+		{"int", &Builtin{Fn: intFunc}},
+		{"float", &Builtin{Fn: floatFunc}},
+		{"str", &Builtin{Fn: strFunc}},
+		{"parseInt", &Builtin{Fn: parseIntFunc}},
+		{"parseFloat", &Builtin{Fn: parseFloatFunc}},
+		{"map", &Builtin{RuntimeFn: mapFunc}},
+		{"filter", &Builtin{RuntimeFn: filterFunc}},
+		{"reduce", &Builtin{RuntimeFn: reduceFunc}},
+		{"sort", &Builtin{RuntimeFn: sortFunc}},
+		{"reverse", &Builtin{RuntimeFn: reverseFunc}},
+		{"zip", &Builtin{RuntimeFn: zipFunc}},
+		{"range", &Builtin{RuntimeFn: rangeFunc}},
+		{"slice", &Builtin{RuntimeFn: sliceFunc}},
+		{"keys", &Builtin{RuntimeFn: keysFunc}},
+		{"values", &Builtin{RuntimeFn: valuesFunc}},
+		{"any", &Builtin{RuntimeFn: anyFunc}},
+		{"all", &Builtin{RuntimeFn: allFunc}},
+		{"print", &Builtin{RuntimeFn: printFunc}},
+		{"println", &Builtin{RuntimeFn: printlnFunc}},
+		{"printf", &Builtin{RuntimeFn: printfFunc}},
+		{"input", &Builtin{RuntimeFn: inputFunc}},
+		{"readLine", &Builtin{RuntimeFn: readLineFunc}},
+		{"freeze", &Builtin{Fn: freezeFunc}},
+	}
+}
+
+// RegisterBuiltin makes fn available as name to every program whose interpreter
+// doesn't have a registry of its own, and to the registries created after it. See
+// Registry.RegisterBuiltin.
+func RegisterBuiltin(name string, fn BuiltinFunction) error {
+	return defaultRegistry.RegisterBuiltin(name, fn)
+}
+
+// Builtins returns the builtins of the default registry in registration order.
+func Builtins() []BuiltinDefinition {
+	return defaultRegistry.Builtins()
+}
+
+// GetBuiltin returns the builtin of the default registry at index, or nil if there
+// is none.
+func GetBuiltin(index int) *Builtin {
+	return defaultRegistry.GetBuiltin(index)
+}
+
+// GetBuiltinIndex returns the position of the builtin of the default registry
+// called name.
+func GetBuiltinIndex(name string) (int, bool) {
+	return defaultRegistry.GetBuiltinIndex(name)
+}
+
+// GetBuiltinByName returns the builtin of the default registry called name, or nil
+// if there is none.
+func GetBuiltinByName(name string) *Builtin {
+	return defaultRegistry.GetBuiltinByName(name)
+}
+
+func lenFunc(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *Array:
+		return &Integer{Value: int64(len(arg.Elements))}
+	case *Range:
+		length := arg.Len()
+		if length > math.MaxInt64 {
+			return newError("length of %s exceeds the largest INTEGER", arg.Inspect())
+		}
+		return &Integer{Value: int64(length)}
+	case Iterable:
+		return &Integer{Value: int64(len(Collect(arg)))}
+	default:
+		return newError("argument to `len` not supported, got %s", args[0].Type())
+	}
+}
+
+func firstFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	elements, ok, err := sequenceElements(rt, args[0])
+	if !ok {
+		return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+	}
+	if err != nil {
+		return ToError(err)
+	}
+
+	if len(elements) > 0 {
+		return elements[0]
+	}
+
+	return nil
+}
+
+func lastFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	elements, ok, err := sequenceElements(rt, args[0])
+	if !ok {
+		return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+	}
+	if err != nil {
+		return ToError(err)
+	}
+
+	length := len(elements)
+	if length > 0 {
+		return elements[length-1]
+	}
+
+	return nil
+}
+
+func restFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	elements, ok, err := sequenceElements(rt, args[0])
+	if !ok {
+		return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+	}
+	if err != nil {
+		return ToError(err)
+	}
+
+	// The rest shares the elements, so walking a list with first and rest takes
+	// linear time. Its capacity is cut, so appending to it copies them, and it is
+	// frozen if the array is.
+	length := len(elements)
+	if length > 0 {
+		array, _ := args[0].(*Array)
+		return &Array{Elements: elements[1:length:length], Frozen: array != nil && array.Frozen}
+	}
+
+	return nil
+}
+
+func pushFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	if args[0].Type() != ARRAY {
+		return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+	}
+
+	// Pushing makes a new array, but it is the way programs grow one, so a frozen
+	// array refuses it like it refuses Append.
+	arr := args[0].(*Array)
+	if arr.Frozen {
+		return ToError(&FrozenError{Type: ARRAY})
+	}
+	length := len(arr.Elements)
+	if err := allocate(rt, length+1); err != nil {
+		return ToError(err)
+	}
+
+	newElements := make([]Object, length+1, length+1)
+	copy(newElements, arr.Elements)
+	newElements[length] = args[1]
+
+	return &Array{Elements: newElements}
+}
+
+// sequenceElements returns the elements of an array or an Iterable. The elements of
+// an Iterable are made here, so they count against the allocation limit of rt, and
+// a range becomes no longer an array than the range builtin makes.
+func sequenceElements(rt Runtime, obj Object) ([]Object, bool, error) {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Elements, true, nil
+	case *Range:
+		length := obj.Len()
+		if length > maxRangeLength {
+			return nil, true, fmt.Errorf("range of %d elements exceeds the limit of %d", length, maxRangeLength)
+		}
+		if err := allocate(rt, int(length)); err != nil {
+			return nil, true, err
+		}
+		return Collect(obj), true, nil
+	case Iterable:
+		elements := Collect(obj)
+		if err := allocate(rt, len(elements)); err != nil {
+			return nil, true, err
+		}
+		return elements, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,123 @@
+package object
+
+import "testing"
+
+func TestRegisterBuiltin(t *testing.T) {
+	before := len(Builtins())
+
+	err := RegisterBuiltin("testRegistered", func(args ...Object) Object {
+		return &String{Value: "registered"}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	index, ok := GetBuiltinIndex("testRegistered")
+	if !ok {
+		t.Fatalf("testRegistered not found")
+	}
+	if index != before {
+		t.Errorf("builtin should be appended. want index=%d, got=%d", before, index)
+	}
+	if GetBuiltin(index) != GetBuiltinByName("testRegistered") {
+		t.Errorf("GetBuiltin and GetBuiltinByName disagree")
+	}
+	if result := GetBuiltin(index).Invoke(nil); result.Inspect() != "registered" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	if err := RegisterBuiltin("testRegistered", func(args ...Object) Object { return nil }); err == nil {
+		t.Errorf("expected an error registering a name twice")
+	}
+	if err := RegisterBuiltin("len", func(args ...Object) Object { return nil }); err == nil {
+		t.Errorf("expected an error replacing a default builtin")
+	}
+	if err := RegisterBuiltin("", func(args ...Object) Object { return nil }); err == nil {
+		t.Errorf("expected an error registering an empty name")
+	}
+
+	if GetBuiltin(-1) != nil || GetBuiltin(len(Builtins())) != nil {
+		t.Errorf("expected nil for an index out of range")
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	r := NewRegistry()
+	if len(r.Builtins()) != len(Builtins()) {
+		t.Fatalf("wrong number of builtins. want=%d, got=%d", len(Builtins()), len(r.Builtins()))
+	}
+	if _, ok := r.GetModule("strings"); !ok {
+		t.Errorf("expected the strings module")
+	}
+
+	fn := func(args ...Object) Object { return nil }
+	if err := r.RegisterBuiltin("testRegistryOnly", fn); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.RegisterModule("test/registryOnly", nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if r.GetBuiltinByName("testRegistryOnly") == nil {
+		t.Errorf("testRegistryOnly not found")
+	}
+	if GetBuiltinByName("testRegistryOnly") != nil || NewRegistry().GetBuiltinByName("testRegistryOnly") != nil {
+		t.Errorf("testRegistryOnly found outside its registry")
+	}
+	if _, ok := GetModule("test/registryOnly"); ok {
+		t.Errorf("test/registryOnly found outside its registry")
+	}
+
+	var nilRegistry *Registry
+	if nilRegistry.GetBuiltinByName("len") != GetBuiltinByName("len") {
+		t.Errorf("a nil registry should use the default registry")
+	}
+}
+
+type countdown struct {
+	from int64
+}
+
+func (c *countdown) Type() ObjectType { return "COUNTDOWN" }
+func (c *countdown) Inspect() string  { return "countdown" }
+
+func (c *countdown) Iterator() Iterator {
+	return &countdownIterator{next: c.from}
+}
+
+type countdownIterator struct {
+	next int64
+}
+
+func (it *countdownIterator) Next() (Object, bool) {
+	if it.next == 0 {
+		return nil, false
+	}
+	it.next--
+	return &Integer{Value: it.next + 1}, true
+}
+
+func TestBuiltinsAcceptIterables(t *testing.T) {
+	tests := []struct {
+		builtin  string
+		arg      Object
+		expected string
+	}{
+		{"len", &countdown{3}, "3"},
+		{"first", &countdown{3}, "3"},
+		{"last", &countdown{3}, "1"},
+		{"rest", &countdown{3}, "[2, 1]"},
+		{"len", &countdown{0}, "0"},
+	}
+
+	for _, tt := range tests {
+		result := GetBuiltinByName(tt.builtin).Invoke(nil, tt.arg)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.builtin, tt.expected, result.Inspect())
+		}
+	}
+
+	if result := GetBuiltinByName("first").Invoke(nil, &countdown{0}); result != nil {
+		t.Errorf("first of an empty iterable should be nil, got=%s", result.Inspect())
+	}
+}
@@ -0,0 +1,600 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRangeLength The most elements range creates, or a range becomes as an array, so
+// a typo can't exhaust memory.
+const maxRangeLength = 1 << 24
+
+// mapFunc returns the results of calling a function with each element of an array.
+func mapFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	elements, ok, err := sequenceElements(rt, args[0])
+	if !ok {
+		return newError("argument 1 to `map` must be ARRAY, got %s", objectTypeOf(args[0]))
+	}
+	if err != nil {
+		return ToError(err)
+	}
+	if err := allocate(rt, len(elements)); err != nil {
+		return ToError(err)
+	}
+
+	mapped := make([]Object, len(elements))
+	for i, element := range elements {
+		result, err := rt.Call(args[1], element)
+		if err != nil {
+			return ToError(err)
+		}
+		mapped[i] = result
+	}
+
+	return &Array{Elements: mapped}
+}
+
+// filterFunc returns the elements of an array a function returns a truthy value for.
+func filterFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	elements, ok, err := sequenceElements(rt, args[0])
+	if !ok {
+		return newError("argument 1 to `filter` must be ARRAY, got %s", objectTypeOf(args[0]))
+	}
+	if err != nil {
+		return ToError(err)
+	}
+
+	filtered := []Object{}
+	for _, element := range elements {
+		result, err := rt.Call(args[1], element)
+		if err != nil {
+			return ToError(err)
+		}
+		if isTruthy(result) {
+			filtered = append(filtered, element)
+		}
+	}
+	if err := allocate(rt, len(filtered)); err != nil {
+		return ToError(err)
+	}
+
+	return &Array{Elements: filtered}
+}
+
+// reduceFunc combines the elements of an array from the left with a function of the
+// accumulated value and the next element. Without an initial value, the first
+// element is the initial value.
+func reduceFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+
+	elements, ok, err := sequenceElements(rt, args[0])
+	if !ok {
+		return newError("argument 1 to `reduce` must be ARRAY, got %s", objectTypeOf(args[0]))
+	}
+	if err != nil {
+		return ToError(err)
+	}
+
+	var accumulator Object
+	if len(args) == 3 {
+		accumulator = args[2]
+	} else {
+		if len(elements) == 0 {
+			return newError("reduce of an empty array without an initial value")
+		}
+		accumulator, elements = elements[0], elements[1:]
+	}
+
+	for _, element := range elements {
+		result, err := rt.Call(args[1], accumulator, element)
+		if err != nil {
+			return ToError(err)
+		}
+		accumulator = result
+	}
+
+	return accumulator
+}
+
+// sortFunc returns the elements of an array in ascending order. Numbers and strings
+// sort naturally; other elements need a comparator, a function returning a negative
+// integer, zero or a positive integer if its first argument sorts before, with or
+// after the second. The sort is stable.
+func sortFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	elements, ok, err := sequenceElements(rt, args[0])
+	if !ok {
+		return newError("argument 1 to `sort` must be ARRAY, got %s", objectTypeOf(args[0]))
+	}
+	if err != nil {
+		return ToError(err)
+	}
+
+	compare := compareObjects
+	if len(args) == 2 {
+		compare = func(a, b Object) (int, error) {
+			result, err := rt.Call(args[1], a, b)
+			if err != nil {
+				return 0, err
+			}
+			order, ok := result.(*Integer)
+			if !ok {
+				return 0, fmt.Errorf("comparator must return INTEGER, got %s", objectTypeOf(result))
+			}
+			return int(order.Value), nil
+		}
+	}
+
+	if err := allocate(rt, len(elements)); err != nil {
+		return ToError(err)
+	}
+	sorted := make([]Object, len(elements))
+	copy(sorted, elements)
+
+	var sortErr error
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		order, err := compare(sorted[i], sorted[j])
+		if err != nil {
+			sortErr = err
+			return false
+		}
+		return order < 0
+	})
+	if sortErr != nil {
+		return ToError(sortErr)
+	}
+
+	return &Array{Elements: sorted}
+}
+
+// compareObjects orders numbers, strings and Comparable objects.
+func compareObjects(a, b Object) (int, error) {
+	if a, ok := a.(*Integer); ok {
+		if b, ok := b.(*Integer); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1, nil
+			case a.Value > b.Value:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+
+	if a, ok := toFloat(a); ok {
+		if b, ok := toFloat(b); ok {
+			switch {
+			case a < b:
+				return -1, nil
+			case a > b:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+
+	if a, ok := a.(*String); ok {
+		if b, ok := b.(*String); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	}
+
+	if order, ok, err := Compare(a, b); ok {
+		return order, err
+	}
+
+	return 0, fmt.Errorf("cannot compare %s and %s", objectTypeOf(a), objectTypeOf(b))
+}
+
+func toFloat(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+// reverseFunc returns the elements of an array, or the characters of a string, in
+// reverse order.
+func reverseFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	if s, ok := args[0].(*String); ok {
+		if err := allocate(rt, utf8.RuneCountInString(s.Value)); err != nil {
+			return ToError(err)
+		}
+		runes := []rune(s.Value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return &String{Value: string(runes)}
+	}
+
+	elements, ok, err := sequenceElements(rt, args[0])
+	if !ok {
+		return newError("argument to `reverse` must be ARRAY or STRING, got %s", objectTypeOf(args[0]))
+	}
+	if err != nil {
+		return ToError(err)
+	}
+	if err := allocate(rt, len(elements)); err != nil {
+		return ToError(err)
+	}
+
+	reversed := make([]Object, len(elements))
+	for i, element := range elements {
+		reversed[len(elements)-1-i] = element
+	}
+
+	return &Array{Elements: reversed}
+}
+
+// zipFunc returns arrays of the elements at the same position in each of its
+// arguments, as many as the shortest argument has.
+func zipFunc(rt Runtime, args ...Object) Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want>=1")
+	}
+
+	sequences := make([][]Object, len(args))
+	length := -1
+	for i, arg := range args {
+		elements, ok, err := sequenceElements(rt, arg)
+		if !ok {
+			return newError("argument %d to `zip` must be ARRAY, got %s", i+1, objectTypeOf(arg))
+		}
+		if err != nil {
+			return ToError(err)
+		}
+		sequences[i] = elements
+		if length < 0 || len(elements) < length {
+			length = len(elements)
+		}
+	}
+
+	// Each tuple is an array of its own, besides its elements.
+	if err := allocate(rt, length*(len(sequences)+2)); err != nil {
+		return ToError(err)
+	}
+
+	zipped := make([]Object, length)
+	for i := range zipped {
+		tuple := make([]Object, len(sequences))
+		for j, elements := range sequences {
+			tuple[j] = elements[i]
+		}
+		zipped[i] = &Array{Elements: tuple}
+	}
+
+	return &Array{Elements: zipped}
+}
+
+// rangeFunc returns the integers from start up to, but not including, stop, counting
+// by step. range(stop) counts from 0, and step is 1 unless given.
+func rangeFunc(rt Runtime, args ...Object) Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+	}
+
+	bounds := []int64{0, 0, 1}
+	for i, arg := range args {
+		n, ok := arg.(*Integer)
+		if !ok {
+			return newError("argument %d to `range` must be INTEGER, got %s", i+1, objectTypeOf(arg))
+		}
+		bounds[i] = n.Value
+	}
+	if len(args) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+
+	start, stop, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return newError("range step must not be zero")
+	}
+
+	length := rangeLength(start, stop, step, false)
+	if length > maxRangeLength {
+		return newError("range of %d elements exceeds the limit of %d", length, maxRangeLength)
+	}
+	if err := allocate(rt, int(length)); err != nil {
+		return ToError(err)
+	}
+
+	elements := make([]Object, length)
+	for i := range elements {
+		elements[i] = &Integer{Value: start + int64(i)*step}
+	}
+
+	return &Array{Elements: elements}
+}
+
+// sliceFunc returns the elements of an array, or the characters of a string, from
+// start up to end, or up to the end without end. Negative positions count from the
+// end.
+func sliceFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+
+	var length int
+	switch arg := args[0].(type) {
+	case *String:
+		length = len([]rune(arg.Value))
+	case *Array:
+		length = len(arg.Elements)
+	default:
+		return newError("argument 1 to `slice` must be ARRAY or STRING, got %s", objectTypeOf(args[0]))
+	}
+
+	positions := []int64{0, int64(length)}
+	for i, arg := range args[1:] {
+		n, ok := arg.(*Integer)
+		if !ok {
+			return newError("argument %d to `slice` must be INTEGER, got %s", i+2, objectTypeOf(arg))
+		}
+		positions[i] = n.Value
+	}
+
+	start, end, err := SliceBounds(positions[0], positions[1], length)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	if err := allocate(rt, end-start); err != nil {
+		return ToError(err)
+	}
+
+	if s, ok := args[0].(*String); ok {
+		return &String{Value: string([]rune(s.Value)[start:end])}
+	}
+
+	elements := make([]Object, end-start)
+	copy(elements, args[0].(*Array).Elements[start:end])
+	return &Array{Elements: elements}
+}
+
+// SliceBounds resolves the positions start and end of a slice of a sequence of
+// length elements, where negative positions count from the end. Positions past either
+// end are clamped; it is an error for start to come after end.
+func SliceBounds(start, end int64, length int) (int, int, error) {
+	resolve := func(position int64) int64 {
+		if position < 0 {
+			position += int64(length)
+		}
+		if position < 0 {
+			return 0
+		}
+		if position > int64(length) {
+			return int64(length)
+		}
+		return position
+	}
+
+	from, to := resolve(start), resolve(end)
+	if from > to {
+		return 0, 0, fmt.Errorf("slice bounds out of range [%d:%d]", start, end)
+	}
+	return int(from), int(to), nil
+}
+
+// Slice returns obj[start:end]: the elements of an array or a range, or the
+// characters of a string, from start up to end. Bounds that are nil or null default
+// to the ends, and negative ones count from the end. The slice of an array shares
+// its elements, like the array rest returns, and is frozen if the array is; the
+// slice of a string shares its bytes.
+func Slice(obj, start, end Object) (Object, error) {
+	var length int
+	switch obj := obj.(type) {
+	case *String:
+		length = utf8.RuneCountInString(obj.Value)
+	case *Array:
+		length = len(obj.Elements)
+	case *Range:
+		if obj.Len() > math.MaxInt {
+			return nil, fmt.Errorf("range of %d elements is too long to slice", obj.Len())
+		}
+		length = int(obj.Len())
+	default:
+		return nil, fmt.Errorf("slice operator not supported: %s", objectTypeOf(obj))
+	}
+
+	positions := []int64{0, int64(length)}
+	for i, bound := range []Object{start, end} {
+		switch bound := bound.(type) {
+		case nil, *Null:
+		case *Integer:
+			positions[i] = bound.Value
+		default:
+			return nil, fmt.Errorf("slice bounds must be INTEGER, got %s", bound.Type())
+		}
+	}
+
+	from, to, err := SliceBounds(positions[0], positions[1], length)
+	if err != nil {
+		return nil, err
+	}
+
+	switch obj := obj.(type) {
+	case *String:
+		return &String{Value: obj.Value[runeOffset(obj.Value, from):runeOffset(obj.Value, to)]}, nil
+	case *Array:
+		return &Array{Elements: obj.Elements[from:to:to], Frozen: obj.Frozen}, nil
+	default:
+		r := obj.(*Range)
+		return &Range{Start: r.Start + int64(from)*r.Step, Stop: r.Start + int64(to)*r.Step, Step: r.Step}, nil
+	}
+}
+
+// runeOffset returns the byte offset of the rune at position n of s, or the length
+// of s if it has n runes.
+func runeOffset(s string, n int) int {
+	for offset := range s {
+		if n == 0 {
+			return offset
+		}
+		n--
+	}
+	return len(s)
+}
+
+// keysFunc returns the keys of a hash in a stable order: booleans, then integers,
+// then strings, each ascending.
+func keysFunc(rt Runtime, args ...Object) Object {
+	pairs, errObj := sortedPairs("keys", args)
+	if errObj != nil {
+		return errObj
+	}
+	if err := allocate(rt, len(pairs)); err != nil {
+		return ToError(err)
+	}
+
+	keys := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = pair.Key
+	}
+	return &Array{Elements: keys}
+}
+
+// valuesFunc returns the values of a hash, in the order keys returns their keys.
+func valuesFunc(rt Runtime, args ...Object) Object {
+	pairs, errObj := sortedPairs("values", args)
+	if errObj != nil {
+		return errObj
+	}
+	if err := allocate(rt, len(pairs)); err != nil {
+		return ToError(err)
+	}
+
+	values := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		values[i] = pair.Value
+	}
+	return &Array{Elements: values}
+}
+
+func sortedPairs(name string, args []Object) ([]HashPair, *Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	hash, ok := args[0].(*Hash)
+	if !ok {
+		return nil, newError("argument to `%s` must be HASH, got %s", name, objectTypeOf(args[0]))
+	}
+
+	return SortedPairs(hash), nil
+}
+
+// SortedPairs returns the pairs of hash ordered by their keys: booleans, then
+// integers, then strings, each ascending.
+func SortedPairs(hash *Hash) []HashPair {
+	pairs := make([]HashPair, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	rank := map[ObjectType]int{BOOLEAN: 0, INTEGER: 1, STRING: 2}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i].Key, pairs[j].Key
+		if rank[a.Type()] != rank[b.Type()] {
+			return rank[a.Type()] < rank[b.Type()]
+		}
+
+		switch a := a.(type) {
+		case *Boolean:
+			return !a.Value && b.(*Boolean).Value
+		case *Integer:
+			return a.Value < b.(*Integer).Value
+		case *String:
+			return a.Value < b.(*String).Value
+		default:
+			return a.Inspect() < b.Inspect()
+		}
+	})
+
+	return pairs
+}
+
+// anyFunc reports whether any element of an array is truthy, or makes a function
+// return a truthy value if one is given.
+func anyFunc(rt Runtime, args ...Object) Object {
+	return quantify("any", rt, args, true)
+}
+
+// allFunc reports whether all elements of an array are truthy, or make a function
+// return a truthy value if one is given.
+func allFunc(rt Runtime, args ...Object) Object {
+	return quantify("all", rt, args, false)
+}
+
+// quantify looks for an element whose truthiness is wanted, and reports whether it
+// found one for any, or didn't for all.
+func quantify(name string, rt Runtime, args []Object, wanted bool) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	elements, ok, err := sequenceElements(rt, args[0])
+	if !ok {
+		return newError("argument 1 to `%s` must be ARRAY, got %s", name, objectTypeOf(args[0]))
+	}
+	if err != nil {
+		return ToError(err)
+	}
+
+	for _, element := range elements {
+		result := element
+		if len(args) == 2 {
+			var err error
+			result, err = rt.Call(args[1], element)
+			if err != nil {
+				return ToError(err)
+			}
+		}
+		if isTruthy(result) == wanted {
+			return &Boolean{Value: wanted}
+		}
+	}
+
+	return &Boolean{Value: !wanted}
+}
+
+// isTruthy reports whether obj counts as true in a condition, like the evaluator
+// and the VM do.
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case nil, *Null:
+		return false
+	case *Boolean:
+		return obj.Value
+	default:
+		return true
+	}
+}
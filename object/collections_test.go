@@ -0,0 +1,152 @@
+package object
+
+import "testing"
+
+func array(elements ...Object) *Array { return &Array{Elements: elements} }
+
+func TestCollectionBuiltins(t *testing.T) {
+	double := &Builtin{Fn: func(args ...Object) Object {
+		return integer(args[0].(*Integer).Value * 2)
+	}}
+	isEven := &Builtin{Fn: func(args ...Object) Object {
+		return &Boolean{Value: args[0].(*Integer).Value%2 == 0}
+	}}
+	add := &Builtin{Fn: func(args ...Object) Object {
+		return integer(args[0].(*Integer).Value + args[1].(*Integer).Value)
+	}}
+	descending := &Builtin{Fn: func(args ...Object) Object {
+		return integer(args[1].(*Integer).Value - args[0].(*Integer).Value)
+	}}
+	byLength := &Builtin{Fn: func(args ...Object) Object {
+		return integer(int64(len(args[0].(*String).Value) - len(args[1].(*String).Value)))
+	}}
+	fail := &Builtin{Fn: func(args ...Object) Object {
+		return newError("failed")
+	}}
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Object{str("b"), integer(2), str("a"), &Boolean{Value: true}, integer(-1)} {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: str(key.Inspect() + "!")}
+	}
+
+	tests := []struct {
+		builtin  string
+		args     []Object
+		expected string
+	}{
+		{"map", []Object{array(integer(1), integer(2)), double}, "[2, 4]"},
+		{"map", []Object{array(), double}, "[]"},
+		{"map", []Object{integer(1), double}, "ERROR: argument 1 to `map` must be ARRAY, got INTEGER"},
+		{"map", []Object{array(integer(1)), fail}, "ERROR: failed"},
+		{"filter", []Object{array(integer(1), integer(2), integer(4)), isEven}, "[2, 4]"},
+		{"reduce", []Object{array(integer(1), integer(2), integer(3)), add}, "6"},
+		{"reduce", []Object{array(integer(1), integer(2)), add, integer(10)}, "13"},
+		{"reduce", []Object{array(), add}, "ERROR: reduce of an empty array without an initial value"},
+		{"sort", []Object{array(integer(3), float(1.5), integer(2))}, "[1.500000, 2, 3]"},
+		{"sort", []Object{array(str("b"), str("c"), str("a"))}, "[a, b, c]"},
+		{"sort", []Object{array(integer(1), integer(3), integer(2)), descending}, "[3, 2, 1]"},
+		{"sort", []Object{array(str("ccc"), str("a"), str("bb"), str("b")), byLength}, "[a, b, bb, ccc]"},
+		{"sort", []Object{array(integer(1), str("a"))}, "ERROR: cannot compare STRING and INTEGER"},
+		{"sort", []Object{array(integer(1), integer(2)), fail}, "ERROR: failed"},
+		{"sort", []Object{array(integer(1), integer(2)), isEven}, "ERROR: comparator must return INTEGER, got BOOLEAN"},
+		{"reverse", []Object{array(integer(1), integer(2), integer(3))}, "[3, 2, 1]"},
+		{"reverse", []Object{str("añb")}, "bña"},
+		{"zip", []Object{array(integer(1), integer(2), integer(3)), array(str("a"), str("b"))}, "[[1, a], [2, b]]"},
+		{"range", []Object{integer(3)}, "[0, 1, 2]"},
+		{"range", []Object{integer(2), integer(5)}, "[2, 3, 4]"},
+		{"range", []Object{integer(10), integer(0), integer(-3)}, "[10, 7, 4, 1]"},
+		{"range", []Object{integer(5), integer(1)}, "[]"},
+		{"range", []Object{integer(0), integer(1), integer(0)}, "ERROR: range step must not be zero"},
+		{"range", []Object{integer(1 << 30)}, "ERROR: range of 1073741824 elements exceeds the limit of 16777216"},
+		{"slice", []Object{array(integer(1), integer(2), integer(3), integer(4)), integer(1), integer(3)}, "[2, 3]"},
+		{"slice", []Object{array(integer(1), integer(2), integer(3)), integer(-2)}, "[2, 3]"},
+		{"slice", []Object{str("日本語"), integer(1)}, "本語"},
+		{"slice", []Object{array(integer(1)), integer(0), integer(10)}, "[1]"},
+		{"slice", []Object{array(integer(1), integer(2)), integer(2), integer(1)}, "ERROR: slice bounds out of range [2:1]"},
+		{"len", []Object{&Range{Start: 0, Stop: 10, Step: 1, Inclusive: true}}, "11"},
+		{"map", []Object{&Range{Start: 0, Stop: 3, Step: 1}, double}, "[0, 2, 4]"},
+		{"filter", []Object{&Range{Start: 10, Stop: 0, Step: -3}, isEven}, "[10, 4]"},
+		{"rest", []Object{&Range{Start: 0, Stop: 3, Step: 1}}, "[1, 2]"},
+		{"keys", []Object{hash}, "[true, -1, 2, a, b]"},
+		{"values", []Object{hash}, "[true!, -1!, 2!, a!, b!]"},
+		{"keys", []Object{array()}, "ERROR: argument to `keys` must be HASH, got ARRAY"},
+		{"any", []Object{array(integer(1), integer(3)), isEven}, "false"},
+		{"any", []Object{array(integer(1), integer(2)), isEven}, "true"},
+		{"any", []Object{array(&Null{}, &Boolean{Value: true})}, "true"},
+		{"all", []Object{array(integer(2), integer(4)), isEven}, "true"},
+		{"all", []Object{array(integer(2), integer(3)), isEven}, "false"},
+		{"all", []Object{array()}, "true"},
+	}
+
+	for _, tt := range tests {
+		result := GetBuiltinByName(tt.builtin).Invoke(Caller(callBuiltin), tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s%v: wrong result. want=%q, got=%q", tt.builtin, tt.args, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestSlice(t *testing.T) {
+	tests := []struct {
+		obj        Object
+		start, end Object
+		expected   string
+	}{
+		{array(integer(1), integer(2), integer(3), integer(4)), integer(1), integer(3), "[2, 3]"},
+		{array(integer(1), integer(2), integer(3)), integer(-2), nil, "[2, 3]"},
+		{array(integer(1), integer(2), integer(3)), &Null{}, integer(-1), "[1, 2]"},
+		{array(integer(1)), integer(0), integer(10), "[1]"},
+		{array(), nil, nil, "[]"},
+		{str("日本語です"), nil, integer(3), "日本語"},
+		{str("日本語です"), integer(-2), nil, "です"},
+		{str("abc"), integer(1), integer(1), ""},
+		{&Range{Start: 0, Stop: 10, Step: 1, Inclusive: true}, integer(2), integer(-2), "2..<9"},
+		{&Range{Start: 0, Stop: 20, Step: 5}, integer(1), nil, "5..<20 step 5"},
+		{&Range{Start: 10, Stop: 0, Step: -2}, integer(1), integer(3), "8..<4 step -2"},
+		{array(integer(1), integer(2)), integer(2), integer(1), "slice bounds out of range [2:1]"},
+		{array(integer(1)), str("a"), nil, "slice bounds must be INTEGER, got STRING"},
+		{integer(1), nil, nil, "slice operator not supported: INTEGER"},
+		{&Hash{}, nil, nil, "slice operator not supported: HASH"},
+	}
+
+	for _, tt := range tests {
+		result, err := Slice(tt.obj, tt.start, tt.end)
+		if err != nil {
+			if err.Error() != tt.expected {
+				t.Errorf("Slice(%s): wrong error. want=%q, got=%q", tt.obj.Inspect(), tt.expected, err)
+			}
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("Slice(%s): wrong result. want=%q, got=%q", tt.obj.Inspect(), tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestSliceSharesElements(t *testing.T) {
+	elements := array(integer(1), integer(2), integer(3))
+	slice, err := Slice(elements, integer(1), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := slice.(*Array).Set(0, integer(5)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if elements.Inspect() != "[1, 5, 3]" {
+		t.Errorf("array not changed through its slice. got=%s", elements.Inspect())
+	}
+
+	// Appending to a slice copies the elements instead of overwriting the array.
+	slice, _ = Slice(elements, nil, integer(1))
+	if err := slice.(*Array).Append(integer(7)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if elements.Inspect() != "[1, 5, 3]" {
+		t.Errorf("array changed by appending to its slice. got=%s", elements.Inspect())
+	}
+
+	slice, _ = Slice(Freeze(elements), nil, nil)
+	if err := slice.(*Array).Set(0, integer(1)); err == nil {
+		t.Errorf("slice of a frozen array is not frozen")
+	}
+}
@@ -0,0 +1,550 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Caller calls fn, a function object, with args. The evaluator and the VM each
+// provide one, so ToGoWithCaller can turn functions into Go funcs.
+type Caller func(fn Object, args ...Object) (Object, error)
+
+// Call calls c, so a Caller can be used as a Runtime.
+func (c Caller) Call(fn Object, args ...Object) (Object, error) {
+	return c(fn, args...)
+}
+
+// FromGo converts a Go value to an object.
+//
+// Bools, strings, integers and floats become the matching object, slices and arrays
+// become arrays, and maps and structs become hashes. Struct fields are keyed by
+// their name, or by the name in a `gengo:"name"` tag; fields tagged `gengo:"-"` and
+// unexported fields are skipped. Pointers are followed, nil becomes null, Go
+// functions become builtins as with NewBuiltinFunc, and objects are returned as
+// they are. A value that contains itself can't be converted.
+func FromGo(v interface{}) (Object, error) {
+	if v == nil {
+		return &Null{}, nil
+	}
+	return fromGoValue(reflect.ValueOf(v))
+}
+
+// ToGo stores obj in the value target points to, converting it the way FromGo
+// converts the other way. Numbers out of the range of the target are an error. A hash can be stored in a map or a struct. Storing in an
+// interface{} picks int64, float64, string, bool, []interface{} and
+// map[string]interface{} as appropriate. A builtin can be stored in a Go func of
+// any supported signature; other functions need an interpreter, see
+// ToGoWithCaller.
+func ToGo(obj Object, target interface{}) error {
+	return ToGoWithCaller(obj, target, callBuiltin)
+}
+
+// ToGoWithCaller is like ToGo, but functions stored in Go funcs are called with
+// caller.
+func ToGoWithCaller(obj Object, target interface{}, caller Caller) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+
+	t := ptr.Elem().Type()
+	value, err := toGoValue(obj, t, caller)
+	if err != nil {
+		return fmt.Errorf("cannot convert %s to %s: %s", objectTypeOf(obj), t, err)
+	}
+
+	ptr.Elem().Set(value)
+	return nil
+}
+
+// callBuiltin is the Caller used when there is no interpreter.
+func callBuiltin(fn Object, args ...Object) (Object, error) {
+	builtin, ok := fn.(*Builtin)
+	if !ok {
+		return nil, fmt.Errorf("cannot call %s without an interpreter", objectTypeOf(fn))
+	}
+
+	// Like the interpreters, report an error object as an error.
+	result := builtin.Invoke(Caller(callBuiltin), args...)
+	if errObj, ok := result.(*Error); ok {
+		return nil, errObj
+	}
+	return result, nil
+}
+
+// visit identifies a pointer, map or slice being converted. Slices that share an
+// array but differ in length are different values.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// visits holds the values being converted, the ones value was reached through.
+type visits map[visit]bool
+
+// enter adds value, a pointer, map or slice, to visiting until the returned func is
+// called. A value that is already there contains itself, which no object can, so
+// it is an error.
+func (visiting visits) enter(value reflect.Value) (func(), error) {
+	v := visit{ptr: value.Pointer(), typ: value.Type()}
+	if value.Kind() == reflect.Slice {
+		v.len = value.Len()
+	}
+	if v.ptr == 0 {
+		return func() {}, nil
+	}
+	if visiting[v] {
+		return nil, fmt.Errorf("%s contains itself", v.typ)
+	}
+
+	visiting[v] = true
+	return func() { delete(visiting, v) }, nil
+}
+
+func fromGoValue(value reflect.Value) (Object, error) {
+	return visits{}.fromGo(value)
+}
+
+func (visiting visits) fromGo(value reflect.Value) (Object, error) {
+	if value.IsValid() && value.Type().Implements(objectType) {
+		if isNil(value) {
+			return &Null{}, nil
+		}
+		return value.Interface().(Object), nil
+	}
+
+	switch value.Kind() {
+	case reflect.Invalid:
+		return &Null{}, nil
+	case reflect.Bool:
+		return &Boolean{Value: value.Bool()}, nil
+	case reflect.String:
+		return &String{Value: value.String()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: value.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := value.Uint()
+		if n > 1<<63-1 {
+			return nil, fmt.Errorf("%d overflows INTEGER", n)
+		}
+		return &Integer{Value: int64(n)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: value.Float()}, nil
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return &Null{}, nil
+		}
+		if value.Kind() == reflect.Ptr {
+			leave, err := visiting.enter(value)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		return visiting.fromGo(value.Elem())
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice {
+			leave, err := visiting.enter(value)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		elements := make([]Object, value.Len())
+		for i := range elements {
+			element, err := visiting.fromGo(value.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %s", i, err)
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		leave, err := visiting.enter(value)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		hash := &Hash{Pairs: make(map[HashKey]HashPair, value.Len())}
+		iter := value.MapRange()
+		for iter.Next() {
+			key, err := visiting.fromGo(iter.Key())
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %s", iter.Key(), err)
+			}
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			val, err := visiting.fromGo(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("value of %s: %s", key.Inspect(), err)
+			}
+			hash.Pairs[hashable.HashKey()] = HashPair{Key: key, Value: val}
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+		for _, field := range structFields(value.Type()) {
+			// Fields promoted through a nil embedded pointer are left out.
+			fieldValue, err := value.FieldByIndexErr(field.index)
+			if err != nil {
+				continue
+			}
+			val, err := visiting.fromGo(fieldValue)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %s", field.name, err)
+			}
+			key := &String{Value: field.name}
+			hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: val}
+		}
+		return hash, nil
+	case reflect.Func:
+		if value.IsNil() {
+			return &Null{}, nil
+		}
+		return NewBuiltinFunc("function", value.Interface())
+	default:
+		return nil, fmt.Errorf("unsupported type %s", value.Type())
+	}
+}
+
+// toGoValue converts obj to a value of type t. Its errors describe what is wrong
+// with obj, so callers can prefix them with where obj came from.
+func toGoValue(obj Object, t reflect.Type, caller Caller) (reflect.Value, error) {
+	value := reflect.New(t).Elem()
+
+	if obj != nil && reflect.TypeOf(obj).AssignableTo(t) && t.Implements(objectType) {
+		value.Set(reflect.ValueOf(obj))
+		return value, nil
+	}
+
+	// Other objects are never converted to an object type.
+	if t.Implements(objectType) {
+		return value, fmt.Errorf("must be %s, got %s", expectedType(t), objectTypeOf(obj))
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			value.SetBool(b.Value)
+			return value, nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*String); ok {
+			value.SetString(s.Value)
+			return value, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*Integer); ok {
+			if value.OverflowInt(i.Value) {
+				return value, fmt.Errorf("overflows %s", t)
+			}
+			value.SetInt(i.Value)
+			return value, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*Integer); ok {
+			if i.Value < 0 || value.OverflowUint(uint64(i.Value)) {
+				return value, fmt.Errorf("overflows %s", t)
+			}
+			value.SetUint(uint64(i.Value))
+			return value, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := obj.(type) {
+		case *Float:
+			f = n.Value
+		case *Integer:
+			f = float64(n.Value)
+		default:
+			return value, fmt.Errorf("must be %s, got %s", expectedType(t), objectTypeOf(obj))
+		}
+		if value.OverflowFloat(f) {
+			return value, fmt.Errorf("overflows %s", t)
+		}
+		value.SetFloat(f)
+		return value, nil
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			natural, err := naturalGoValue(obj, caller)
+			if err != nil {
+				return value, err
+			}
+			if natural != nil {
+				value.Set(reflect.ValueOf(natural))
+			}
+			return value, nil
+		}
+	case reflect.Ptr:
+		if _, ok := obj.(*Null); ok {
+			return value, nil
+		}
+		elem, err := toGoValue(obj, t.Elem(), caller)
+		if err != nil {
+			return value, err
+		}
+		value.Set(reflect.New(t.Elem()))
+		value.Elem().Set(elem)
+		return value, nil
+	case reflect.Slice, reflect.Array:
+		if arr, ok := obj.(*Array); ok {
+			if t.Kind() == reflect.Slice {
+				value = reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+			} else if len(arr.Elements) != t.Len() {
+				return value, fmt.Errorf("has %d elements, want %d", len(arr.Elements), t.Len())
+			}
+			for i, element := range arr.Elements {
+				elementValue, err := toGoValue(element, t.Elem(), caller)
+				if err != nil {
+					return value, fmt.Errorf("element %d %s", i, err)
+				}
+				value.Index(i).Set(elementValue)
+			}
+			return value, nil
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*Hash); ok {
+			value = reflect.MakeMapWithSize(t, len(hash.Pairs))
+			for _, pair := range hash.Pairs {
+				key, err := toGoValue(pair.Key, t.Key(), caller)
+				if err != nil {
+					return value, fmt.Errorf("key %s %s", pair.Key.Inspect(), err)
+				}
+				val, err := toGoValue(pair.Value, t.Elem(), caller)
+				if err != nil {
+					return value, fmt.Errorf("value of %s %s", pair.Key.Inspect(), err)
+				}
+				value.SetMapIndex(key, val)
+			}
+			return value, nil
+		}
+	case reflect.Struct:
+		if hash, ok := obj.(*Hash); ok {
+			for _, field := range structFields(t) {
+				key := &String{Value: field.name}
+				pair, ok := hash.Pairs[key.HashKey()]
+				if !ok {
+					continue
+				}
+				val, err := toGoValue(pair.Value, field.typ, caller)
+				if err != nil {
+					return value, fmt.Errorf("field %s %s", field.name, err)
+				}
+				fieldValue, err := allocFieldByIndex(value, field.index)
+				if err != nil {
+					return value, fmt.Errorf("field %s %s", field.name, err)
+				}
+				fieldValue.Set(val)
+			}
+			return value, nil
+		}
+	case reflect.Func:
+		if isCallable(obj) {
+			if err := checkFuncType(t); err != nil {
+				return value, err
+			}
+			return goFunc(obj, t, caller), nil
+		}
+	}
+
+	return value, fmt.Errorf("must be %s, got %s", expectedType(t), objectTypeOf(obj))
+}
+
+// naturalGoValue converts obj to the Go value that represents it most directly.
+func naturalGoValue(obj Object, caller Caller) (interface{}, error) {
+	switch obj := obj.(type) {
+	case nil, *Null:
+		return nil, nil
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := naturalGoValue(element, caller)
+			if err != nil {
+				return nil, fmt.Errorf("element %d %s", i, err)
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *Hash:
+		allStrings := true
+		for _, pair := range obj.Pairs {
+			if _, ok := pair.Key.(*String); !ok {
+				allStrings = false
+			}
+		}
+		if allStrings {
+			m := make(map[string]interface{}, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				value, err := naturalGoValue(pair.Value, caller)
+				if err != nil {
+					return nil, fmt.Errorf("value of %s %s", pair.Key.Inspect(), err)
+				}
+				m[pair.Key.(*String).Value] = value
+			}
+			return m, nil
+		}
+		m := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, _ := naturalGoValue(pair.Key, caller)
+			value, err := naturalGoValue(pair.Value, caller)
+			if err != nil {
+				return nil, fmt.Errorf("value of %s %s", pair.Key.Inspect(), err)
+			}
+			m[key] = value
+		}
+		return m, nil
+	}
+
+	if isCallable(obj) {
+		fn := goFunc(obj, reflect.TypeOf(func(...interface{}) (interface{}, error) { return nil, nil }), caller)
+		return fn.Interface(), nil
+	}
+	return obj, nil
+}
+
+func isCallable(obj Object) bool {
+	switch obj.(type) {
+	case *Builtin, *Function, *Closure:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkFuncType checks that a function object can be stored in a Go func of type t.
+func checkFuncType(t reflect.Type) error {
+	switch {
+	case t.NumOut() > 2:
+		return fmt.Errorf("func %s has too many results", t)
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return fmt.Errorf("func %s must return an error last", t)
+	}
+	return nil
+}
+
+// goFunc returns a Go func of type t that converts its arguments with FromGo, calls fn
+// with caller and stores the result in the func's first result. Errors are returned
+// if the func returns an error, and otherwise panic.
+func goFunc(fn Object, t reflect.Type, caller Caller) reflect.Value {
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, t.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(t.Out(i))
+		}
+
+		fail := func(err error) []reflect.Value {
+			if !returnsError {
+				panic(err)
+			}
+			out[len(out)-1] = reflect.ValueOf(&err).Elem()
+			return out
+		}
+
+		if t.IsVariadic() {
+			variadic := in[len(in)-1]
+			in = in[:len(in)-1]
+			for i := 0; i < variadic.Len(); i++ {
+				in = append(in, variadic.Index(i))
+			}
+		}
+
+		args := make([]Object, len(in))
+		for i, arg := range in {
+			obj, err := fromGoValue(arg)
+			if err != nil {
+				return fail(fmt.Errorf("argument %d: %s", i+1, err))
+			}
+			args[i] = obj
+		}
+
+		result, err := caller(fn, args...)
+		if err != nil {
+			return fail(err)
+		}
+		if errObj, ok := result.(*Error); ok {
+			return fail(fmt.Errorf("%s", errObj.Message))
+		}
+
+		if t.NumOut() == 0 || (t.NumOut() == 1 && returnsError) {
+			return out
+		}
+
+		value, err := toGoValue(result, t.Out(0), caller)
+		if err != nil {
+			return fail(fmt.Errorf("result %s", err))
+		}
+		out[0] = value
+		return out
+	})
+}
+
+type structField struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+// structFields returns the exported fields of t and the keys they map to.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("gengo"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tagName, _, _ := strings.Cut(tag, ","); tagName != "" {
+				name = tagName
+			}
+		}
+
+		fields = append(fields, structField{name: name, index: field.Index, typ: field.Type})
+	}
+
+	return fields
+}
+
+// allocFieldByIndex returns the field of value at index, allocating the embedded
+// pointers the field is promoted through if they are nil.
+func allocFieldByIndex(value reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				if !value.CanSet() {
+					return value, fmt.Errorf("is promoted through a nil pointer to unexported %s", value.Type().Elem())
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value, nil
+}
+
+func isNil(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return value.IsNil()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,293 @@
+package object
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City string `gengo:"city"`
+	Zip  string `gengo:"zip,omitempty"`
+}
+
+type person struct {
+	Name     string   `gengo:"name"`
+	Age      int      `gengo:"age"`
+	Tags     []string `gengo:"tags"`
+	Address  *address `gengo:"address"`
+	Password string   `gengo:"-"`
+	Nickname string
+	secret   string
+}
+
+// Inner is embedded by pointer, so its fields are promoted through it.
+type Inner struct {
+	X int
+}
+
+type outer struct {
+	*Inner
+	Y int
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{"hello", "hello"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.5, "2.500000"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{map[string]int{"one": 1}, "{one: 1}"},
+		{(*address)(nil), "null"},
+		{&struct {
+			City string `gengo:"city"`
+		}{City: "Oslo"}, "{city: Oslo}"},
+		{&String{Value: "object"}, "object"},
+		// Fields promoted through a nil embedded pointer are left out.
+		{outer{Y: 1}, "{Y: 1}"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Fatalf("FromGo(%#v): unexpected error: %s", tt.input, err)
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v): want=%s, got=%s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func TestFromGoStruct(t *testing.T) {
+	p := person{Name: "Ada", Age: 36, Tags: []string{"math"}, Password: "hunter2", Nickname: "ada", secret: "x"}
+
+	obj, err := FromGo(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	hash, ok := obj.(*Hash)
+	if !ok {
+		t.Fatalf("expected a *Hash, got=%T", obj)
+	}
+
+	expected := map[string]string{
+		"name":     "Ada",
+		"age":      "36",
+		"tags":     "[math]",
+		"address":  "null",
+		"Nickname": "ada",
+	}
+	if len(hash.Pairs) != len(expected) {
+		t.Errorf("wrong number of pairs. want=%d, got=%d", len(expected), len(hash.Pairs))
+	}
+	for key, value := range expected {
+		pair, ok := hash.Pairs[(&String{Value: key}).HashKey()]
+		if !ok {
+			t.Errorf("no pair for %s", key)
+			continue
+		}
+		if pair.Value.Inspect() != value {
+			t.Errorf("wrong value for %s. want=%s, got=%s", key, value, pair.Value.Inspect())
+		}
+	}
+}
+
+func TestFromGoErrors(t *testing.T) {
+	tests := []interface{}{
+		make(chan int),
+		uint64(1 << 63),
+		map[float64]int{1.5: 1},
+	}
+
+	for _, input := range tests {
+		if _, err := FromGo(input); err == nil {
+			t.Errorf("FromGo(%#v): expected an error", input)
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	hash := func(pairs ...Object) *Hash {
+		h := &Hash{Pairs: make(map[HashKey]HashPair)}
+		for i := 0; i < len(pairs); i += 2 {
+			h.Pairs[pairs[i].(Hashable).HashKey()] = HashPair{Key: pairs[i], Value: pairs[i+1]}
+		}
+		return h
+	}
+	str := func(s string) *String { return &String{Value: s} }
+	integer := func(i int64) *Integer { return &Integer{Value: i} }
+
+	var i int
+	var f float32
+	var s []string
+	var m map[string]int
+	var p person
+	var ptr *int
+	var any interface{}
+	var arr *Array
+	var o outer
+
+	tests := []struct {
+		obj      Object
+		target   interface{}
+		expected interface{}
+	}{
+		{integer(5), &i, 5},
+		{integer(5), &f, float32(5)},
+		{&Array{Elements: []Object{str("a"), str("b")}}, &s, []string{"a", "b"}},
+		{hash(str("one"), integer(1)), &m, map[string]int{"one": 1}},
+		{
+			hash(str("name"), str("Ada"), str("age"), integer(36), str("address"), hash(str("city"), str("London")), str("ignored"), integer(1)),
+			&p,
+			person{Name: "Ada", Age: 36, Address: &address{City: "London"}},
+		},
+		{integer(3), &ptr, 3},
+		{&Null{}, &ptr, (*int)(nil)},
+		{&Array{Elements: []Object{integer(1), str("two"), &Null{}}}, &any, []interface{}{int64(1), "two", nil}},
+		{hash(str("a"), &Boolean{Value: true}), &any, map[string]interface{}{"a": true}},
+		{&Array{}, &arr, &Array{}},
+		// Embedded pointers are allocated for the fields promoted through them.
+		{hash(str("X"), integer(1), str("Y"), integer(2)), &o, outer{Inner: &Inner{X: 1}, Y: 2}},
+	}
+
+	for _, tt := range tests {
+		if err := ToGo(tt.obj, tt.target); err != nil {
+			t.Fatalf("ToGo(%s): unexpected error: %s", tt.obj.Inspect(), err)
+		}
+
+		got := reflect.ValueOf(tt.target).Elem().Interface()
+		if p, ok := got.(*int); ok && p != nil {
+			got = *p
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ToGo(%s): want=%#v, got=%#v", tt.obj.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestFromGoCycles(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	n := &node{}
+	n.Next = n
+
+	m := map[string]interface{}{}
+	m["self"] = m
+
+	s := []interface{}{nil}
+	s[0] = s
+
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{n, "field Next: *object.node contains itself"},
+		{m, "value of self: map[string]interface {} contains itself"},
+		{s, "element 0: []interface {} contains itself"},
+	}
+
+	for _, tt := range tests {
+		_, err := FromGo(tt.input)
+		if err == nil {
+			t.Errorf("FromGo(%T): expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("FromGo(%T): wrong error. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+
+	// A value reached twice, but not through itself, is converted twice.
+	shared := &node{}
+	obj, err := FromGo([]*node{shared, shared})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if obj.Inspect() != "[{Next: null}, {Next: null}]" {
+		t.Errorf("wrong object. got=%s", obj.Inspect())
+	}
+}
+
+func TestToGoErrors(t *testing.T) {
+	var i int8
+	var f float32
+	var s []int
+	var b bool
+	var arr *Array
+	var hidden struct{ *address }
+
+	tests := []struct {
+		obj      Object
+		target   interface{}
+		expected string
+	}{
+		{&Integer{Value: 1000}, &i, "cannot convert INTEGER to int8: overflows int8"},
+		{&Float{Value: 1e39}, &f, "cannot convert FLOAT to float32: overflows float32"},
+		{&Array{Elements: []Object{&String{Value: "x"}}}, &s, "cannot convert ARRAY to []int: element 0 must be INTEGER, got STRING"},
+		{&String{Value: "true"}, &b, "cannot convert STRING to bool: must be BOOLEAN, got STRING"},
+		{&Hash{}, &arr, "cannot convert HASH to *object.Array: must be ARRAY, got HASH"},
+		{&Integer{Value: 1}, i, "target must be a non-nil pointer, got int8"},
+		{
+			&Hash{Pairs: map[HashKey]HashPair{(&String{Value: "city"}).HashKey(): {Key: &String{Value: "city"}, Value: &String{Value: "Oslo"}}}},
+			&hidden,
+			"cannot convert HASH to struct { *object.address }: field city is promoted through a nil pointer to unexported object.address",
+		},
+	}
+
+	for _, tt := range tests {
+		err := ToGo(tt.obj, tt.target)
+		if err == nil {
+			t.Errorf("ToGo(%s): expected an error", tt.obj.Inspect())
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("ToGo(%s): wrong error. want=%q, got=%q", tt.obj.Inspect(), tt.expected, err.Error())
+		}
+	}
+}
+
+func TestToGoFunc(t *testing.T) {
+	upper, err := FromGo(strings.ToUpper)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var fn func(string) (string, error)
+	if err := ToGo(upper, &fn); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := fn("gengo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result != "GENGO" {
+		t.Errorf("wrong result. want=GENGO, got=%s", result)
+	}
+
+	var mismatch func(int) (string, error)
+	if err := ToGo(upper, &mismatch); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := mismatch(1); err == nil || err.Error() != "argument 1 to `function` must be STRING, got INTEGER" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	var fromFunction func() error
+	err = ToGo(&Function{}, &fromFunction)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := fromFunction(); err == nil {
+		t.Errorf("expected an error calling a function without an interpreter")
+	}
+}
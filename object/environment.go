@@ -3,7 +3,9 @@ package object
 // Environment associates strings with objects
 type Environment struct {
 	store map[string]Object
-	outer *Environment
+	// consts holds the names in store bound as constants.
+	consts map[string]bool
+	outer  *Environment
 }
 
 // Get the value of name
@@ -21,6 +23,23 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// SetConst Binds name to val as a constant, which IsConst reports can't be bound
+// again in e.
+func (e *Environment) SetConst(name string, val Object) Object {
+	if e.consts == nil {
+		e.consts = make(map[string]bool)
+	}
+	e.consts[name] = true
+	e.store[name] = val
+	return val
+}
+
+// IsConst Reports whether name is bound as a constant in e itself, rather than in an
+// outer environment.
+func (e *Environment) IsConst(name string) bool {
+	return e.consts[name]
+}
+
 // NewEnvironment returns a new Environment
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
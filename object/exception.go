@@ -0,0 +1,83 @@
+package object
+
+import "errors"
+
+// Exception An error caught by a catch clause. Its attributes are the message, the
+// value that was thrown and the stack, the functions the error unwound from,
+// innermost first.
+type Exception struct {
+	Message string
+	Value   Object
+	Stack   []string
+}
+
+// Type The object's type.
+func (x *Exception) Type() ObjectType {
+	return EXCEPTION
+}
+
+// Inspect A string of the type.
+func (x *Exception) Inspect() string {
+	return "Exception: " + x.Message
+}
+
+// Attribute The message, value or stack of the exception. The value of a runtime
+// error is its message.
+func (x *Exception) Attribute(name string) (Object, bool) {
+	switch name {
+	case "message":
+		return &String{Value: x.Message}, true
+	case "value":
+		if x.Value == nil {
+			return &String{Value: x.Message}, true
+		}
+		return x.Value, true
+	case "stack":
+		elements := make([]Object, len(x.Stack))
+		for i, name := range x.Stack {
+			elements[i] = &String{Value: name}
+		}
+		return &Array{Elements: elements}, true
+	default:
+		return nil, false
+	}
+}
+
+// FrameName Returns how a call of the function called name appears in a stack.
+func FrameName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
+
+// Catch Returns the exception a catch clause binds for err.
+func Catch(err *Error) *Exception {
+	return &Exception{Message: err.Message, Value: err.Value, Stack: append([]string(nil), err.Stack...)}
+}
+
+// Throw Returns the error raised by throwing value. The message of a string is the
+// string itself. Throwing a caught exception raises it again with its value and
+// stack.
+func Throw(value Object) *Error {
+	switch value := value.(type) {
+	case *Exception:
+		return &Error{Message: value.Message, Value: value.Value, Stack: append([]string(nil), value.Stack...)}
+	case *String:
+		return &Error{Message: value.Value, Value: value}
+	case nil:
+		return &Error{Message: "null", Value: &Null{}}
+	default:
+		return &Error{Message: value.Inspect(), Value: value}
+	}
+}
+
+// ToError Converts err to an error object, keeping the value and stack of an error
+// object that err is or wraps.
+func ToError(err error) *Error {
+	var errObj *Error
+	if errors.As(err, &errObj) {
+		return &Error{Message: err.Error(), Value: errObj.Value, Stack: append([]string(nil), errObj.Stack...)}
+	}
+	return &Error{Message: err.Error()}
+}
@@ -0,0 +1,58 @@
+package object
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestThrowAndCatch(t *testing.T) {
+	tests := []struct {
+		value   Object
+		message string
+		thrown  string
+	}{
+		{str("boom"), "boom", "boom"},
+		{integer(1), "1", "1"},
+		{array(integer(1)), "[1]", "[1]"},
+		{nil, "null", "null"},
+	}
+
+	for _, tt := range tests {
+		errObj := Throw(tt.value)
+		errObj.Stack = append(errObj.Stack, "f")
+
+		exception := Catch(errObj)
+		if exception.Inspect() != "Exception: "+tt.message {
+			t.Errorf("%v: wrong exception. got=%q", tt.value, exception.Inspect())
+		}
+		if value, _ := exception.Attribute("value"); value.Inspect() != tt.thrown {
+			t.Errorf("%v: wrong value. want=%q, got=%q", tt.value, tt.thrown, value.Inspect())
+		}
+		if stack, _ := exception.Attribute("stack"); stack.Inspect() != "[f]" {
+			t.Errorf("%v: wrong stack. got=%q", tt.value, stack.Inspect())
+		}
+
+		// Throwing the exception again keeps its value and stack.
+		rethrown := Throw(exception)
+		if rethrown.Message != tt.message || rethrown.Value != errObj.Value || len(rethrown.Stack) != 1 {
+			t.Errorf("%v: wrong rethrown error. got=%+v", tt.value, rethrown)
+		}
+	}
+
+	if value, _ := Catch(newError("failed")).Attribute("value"); value.Inspect() != "failed" {
+		t.Errorf("the value of a runtime error is not its message. got=%q", value.Inspect())
+	}
+}
+
+func TestToError(t *testing.T) {
+	thrown := Throw(integer(7))
+
+	errObj := ToError(fmt.Errorf("calling back: %w", thrown))
+	if errObj.Message != "calling back: 7" || errObj.Value != thrown.Value {
+		t.Errorf("wrong error. got=%+v", errObj)
+	}
+
+	if errObj := ToError(fmt.Errorf("plain")); errObj.Message != "plain" || errObj.Value != nil {
+		t.Errorf("wrong error. got=%+v", errObj)
+	}
+}
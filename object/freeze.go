@@ -0,0 +1,100 @@
+package object
+
+import "fmt"
+
+// FrozenError is returned when a frozen array or hash is changed.
+type FrozenError struct {
+	Type ObjectType
+}
+
+func (e *FrozenError) Error() string {
+	return fmt.Sprintf("cannot modify frozen %s", e.Type)
+}
+
+// Freeze makes obj, and the arrays and hashes it contains, immutable, and returns
+// it. Other objects are returned as they are.
+func Freeze(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		// An array already frozen has its elements frozen too, which also stops
+		// at arrays containing themselves.
+		if obj.Frozen {
+			return obj
+		}
+		obj.Frozen = true
+		for _, element := range obj.Elements {
+			Freeze(element)
+		}
+	case *Hash:
+		if obj.Frozen {
+			return obj
+		}
+		obj.Frozen = true
+		for _, pair := range obj.Pairs {
+			Freeze(pair.Value)
+		}
+	}
+	return obj
+}
+
+// Set Replaces the element at index.
+func (ao *Array) Set(index int, value Object) error {
+	if ao.Frozen {
+		return &FrozenError{Type: ARRAY}
+	}
+	if index < 0 || index >= len(ao.Elements) {
+		return fmt.Errorf("index %d out of range for ARRAY of length %d", index, len(ao.Elements))
+	}
+
+	ao.Elements[index] = value
+	return nil
+}
+
+// Append Adds values to the end of the array.
+func (ao *Array) Append(values ...Object) error {
+	if ao.Frozen {
+		return &FrozenError{Type: ARRAY}
+	}
+
+	ao.Elements = append(ao.Elements, values...)
+	return nil
+}
+
+// Set Stores value under key, which must be hashable.
+func (h *Hash) Set(key, value Object) error {
+	if h.Frozen {
+		return &FrozenError{Type: HASH}
+	}
+	hashable, ok := key.(Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+
+	if h.Pairs == nil {
+		h.Pairs = make(map[HashKey]HashPair)
+	}
+	h.Pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+	return nil
+}
+
+// Delete Removes key, if the hash has it.
+func (h *Hash) Delete(key Object) error {
+	if h.Frozen {
+		return &FrozenError{Type: HASH}
+	}
+	hashable, ok := key.(Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+
+	delete(h.Pairs, hashable.HashKey())
+	return nil
+}
+
+func freezeFunc(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	return Freeze(args[0])
+}
@@ -0,0 +1,86 @@
+package object
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	inner := array(integer(1))
+	hash := &Hash{}
+	if err := hash.Set(str("inner"), inner); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	outer := array(hash, integer(2))
+	// An array containing itself is frozen once.
+	outer.Elements = append(outer.Elements, outer)
+
+	if freezeFunc(outer) != outer {
+		t.Fatalf("freeze did not return its argument")
+	}
+	if !outer.Frozen || !hash.Frozen || !inner.Frozen {
+		t.Fatalf("not frozen deeply: outer=%t, hash=%t, inner=%t", outer.Frozen, hash.Frozen, inner.Frozen)
+	}
+
+	var frozenErr *FrozenError
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{inner.Set(0, integer(3)), "cannot modify frozen ARRAY"},
+		{inner.Append(integer(3)), "cannot modify frozen ARRAY"},
+		{hash.Set(str("x"), integer(3)), "cannot modify frozen HASH"},
+		{hash.Delete(str("inner")), "cannot modify frozen HASH"},
+	}
+	for _, tt := range tests {
+		if !errors.As(tt.err, &frozenErr) || tt.err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, tt.err)
+		}
+	}
+	if inner.Inspect() != "[1]" || hash.Inspect() != "{inner: [1]}" {
+		t.Errorf("frozen objects changed: %s, %s", inner.Inspect(), hash.Inspect())
+	}
+
+	// The rest of a frozen array shares its elements, so it is frozen too.
+	if rest := restFunc(nil, outer).(*Array); !rest.Frozen {
+		t.Errorf("rest of a frozen array is not frozen")
+	}
+	if pushed := pushFunc(nil, inner, integer(2)); pushed.Inspect() != "ERROR: cannot modify frozen ARRAY" {
+		t.Errorf("wrong push to a frozen array: %s", pushed.Inspect())
+	}
+	if pushed := pushFunc(nil, array(integer(1)), integer(2)).(*Array); pushed.Frozen || pushed.Inspect() != "[1, 2]" {
+		t.Errorf("wrong push to an array: %s, frozen=%t", pushed.Inspect(), pushed.Frozen)
+	}
+	if freezeFunc(integer(1)).Inspect() != "1" {
+		t.Errorf("freeze changed an integer")
+	}
+}
+
+func TestMutation(t *testing.T) {
+	arr := array(integer(1))
+	if err := arr.Set(0, integer(2)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := arr.Append(integer(3)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := arr.Set(2, integer(4)); err == nil || err.Error() != "index 2 out of range for ARRAY of length 2" {
+		t.Errorf("wrong error for an index out of range: %v", err)
+	}
+	if arr.Inspect() != "[2, 3]" {
+		t.Errorf("wrong array. got=%s", arr.Inspect())
+	}
+
+	hash := &Hash{}
+	if err := hash.Set(arr, integer(1)); err == nil || err.Error() != "unusable as hash key: ARRAY" {
+		t.Errorf("wrong error for an unhashable key: %v", err)
+	}
+	_ = hash.Set(str("a"), integer(1))
+	_ = hash.Set(str("b"), integer(2))
+	if err := hash.Delete(str("a")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hash.Inspect() != "{b: 2}" {
+		t.Errorf("wrong hash. got=%s", hash.Inspect())
+	}
+}
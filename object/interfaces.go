@@ -0,0 +1,82 @@
+package object
+
+// Objects defined by a host can implement the interfaces below to take part in the
+// language. The evaluator and the VM check for them wherever the builtin types get
+// special treatment, and turn the errors they return into runtime errors.
+
+// Indexable is implemented by objects that support index expressions, obj[index].
+type Indexable interface {
+	Object
+	Index(index Object) (Object, error)
+}
+
+// Callable is implemented by objects that can be called like functions.
+type Callable interface {
+	Object
+	Call(args ...Object) (Object, error)
+}
+
+// Attributable is implemented by objects with attributes, read with a member
+// expression, obj.name. It reports false if the object has no attribute called name.
+type Attributable interface {
+	Object
+	Attribute(name string) (Object, bool)
+}
+
+// Iterator yields the elements of an Iterable, reporting false once there are none
+// left.
+type Iterator interface {
+	Next() (Object, bool)
+}
+
+// Iterable is implemented by objects that are sequences of elements. The len,
+// first, last and rest builtins accept them.
+type Iterable interface {
+	Object
+	Iterator() Iterator
+}
+
+// Comparable is implemented by objects that can be compared with ==, !=, < and >.
+// Compare returns a negative number, zero or a positive number if the object is
+// less than, equal to or greater than other. It is used if either operand
+// implements it.
+type Comparable interface {
+	Object
+	Compare(other Object) (int, error)
+}
+
+// Arithmetic is implemented by objects that support the arithmetic operators +, -,
+// *, / and **. It is used if the left operand implements it, with op being the
+// operator.
+type Arithmetic interface {
+	Object
+	BinaryOperation(op string, right Object) (Object, error)
+}
+
+// Collect returns the elements of iterable.
+func Collect(iterable Iterable) []Object {
+	var elements []Object
+
+	iterator := iterable.Iterator()
+	for {
+		element, ok := iterator.Next()
+		if !ok {
+			return elements
+		}
+		elements = append(elements, element)
+	}
+}
+
+// Compare compares left and right if either implements Comparable. It reports
+// false if neither does.
+func Compare(left, right Object) (int, bool, error) {
+	if comparable, ok := left.(Comparable); ok {
+		result, err := comparable.Compare(right)
+		return result, true, err
+	}
+	if comparable, ok := right.(Comparable); ok {
+		result, err := comparable.Compare(left)
+		return -result, true, err
+	}
+	return 0, false, nil
+}
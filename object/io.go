@@ -0,0 +1,139 @@
+package object
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// IO The output the print builtins write to and the input the input builtins read
+// from.
+type IO struct {
+	Out io.Writer
+	In  *bufio.Reader
+}
+
+// NewIO Creates an IO writing to out and reading from in. A nil out discards the
+// output and a nil in has no input.
+func NewIO(out io.Writer, in io.Reader) *IO {
+	if out == nil {
+		out = io.Discard
+	}
+	if in == nil {
+		in = strings.NewReader("")
+	}
+	return &IO{Out: out, In: bufio.NewReader(in)}
+}
+
+// IORuntime is implemented by runtimes with their own IO. Builtins called by other
+// runtimes use the standard output and input of the process.
+type IORuntime interface {
+	Runtime
+	IO() *IO
+}
+
+var stdIO = NewIO(os.Stdout, os.Stdin)
+
+// runtimeIO returns the IO of rt.
+func runtimeIO(rt Runtime) *IO {
+	if rt, ok := rt.(IORuntime); ok {
+		if streams := rt.IO(); streams != nil {
+			return streams
+		}
+	}
+	return stdIO
+}
+
+// printFunc writes its arguments separated by spaces.
+func printFunc(rt Runtime, args ...Object) Object {
+	return write(rt, joinArguments(args))
+}
+
+// printlnFunc writes its arguments separated by spaces and followed by a newline.
+func printlnFunc(rt Runtime, args ...Object) Object {
+	return write(rt, joinArguments(args)+"\n")
+}
+
+// printfFunc writes its arguments formatted according to the format that is its
+// first argument, as strings.format does.
+func printfFunc(rt Runtime, args ...Object) Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want>=1")
+	}
+
+	format, ok := args[0].(*String)
+	if !ok {
+		return newError("argument 1 to `printf` must be STRING, got %s", objectTypeOf(args[0]))
+	}
+
+	s, err := Format(format.Value, args[1:]...)
+	if err != nil {
+		return newError("%s", err)
+	}
+	return write(rt, s)
+}
+
+// inputFunc writes the prompt given as its argument, if any, and reads a line.
+func inputFunc(rt Runtime, args ...Object) Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+
+	if len(args) == 1 {
+		prompt, ok := args[0].(*String)
+		if !ok {
+			return newError("argument to `input` must be STRING, got %s", objectTypeOf(args[0]))
+		}
+		if errObj := write(rt, prompt.Value); errObj != nil {
+			return errObj
+		}
+	}
+
+	return readLine(rt)
+}
+
+// readLineFunc reads a line.
+func readLineFunc(rt Runtime, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return readLine(rt)
+}
+
+// readLine reads a line without its line ending, or null once the input is
+// exhausted.
+func readLine(rt Runtime) Object {
+	line, err := runtimeIO(rt).In.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		if line == "" {
+			return &Null{}
+		}
+	} else if err != nil {
+		return newError("could not read input: %s", err)
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return &String{Value: line}
+}
+
+func write(rt Runtime, s string) Object {
+	if _, err := io.WriteString(runtimeIO(rt).Out, s); err != nil {
+		return newError("could not write output: %s", err)
+	}
+	return nil
+}
+
+func joinArguments(args []Object) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			parts[i] = "null"
+			continue
+		}
+		parts[i] = arg.Inspect()
+	}
+	return strings.Join(parts, " ")
+}
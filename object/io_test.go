@@ -0,0 +1,58 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// ioRuntime is a runtime with its own IO that can only call builtins.
+type ioRuntime struct {
+	Caller
+	io *IO
+}
+
+func (rt ioRuntime) IO() *IO {
+	return rt.io
+}
+
+func TestIOBuiltins(t *testing.T) {
+	var out bytes.Buffer
+	rt := ioRuntime{Caller: callBuiltin, io: NewIO(&out, strings.NewReader("first\r\nsecond\nlast"))}
+
+	tests := []struct {
+		builtin  string
+		args     []Object
+		expected string
+		output   string
+	}{
+		{"print", []Object{str("a"), integer(1), array(integer(2))}, "null", "a 1 [2]"},
+		{"println", []Object{}, "null", "\n"},
+		{"println", []Object{str("x"), &Boolean{Value: true}}, "null", "x true\n"},
+		{"printf", []Object{str("%s=%d;"), str("n"), integer(3)}, "null", "n=3;"},
+		{"printf", []Object{str("%d"), str("n")}, "ERROR: argument 1 for %d must be INTEGER, got STRING", ""},
+		{"printf", []Object{integer(1)}, "ERROR: argument 1 to `printf` must be STRING, got INTEGER", ""},
+		{"input", []Object{str("name? ")}, "first", "name? "},
+		{"readLine", []Object{}, "second", ""},
+		{"input", []Object{}, "last", ""},
+		{"readLine", []Object{}, "null", ""},
+		{"readLine", []Object{str("x")}, "ERROR: wrong number of arguments. got=1, want=0", ""},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+
+		result := GetBuiltinByName(tt.builtin).Invoke(rt, tt.args...)
+		inspected := "null"
+		if result != nil {
+			inspected = result.Inspect()
+		}
+
+		if inspected != tt.expected {
+			t.Errorf("%s%v: wrong result. want=%q, got=%q", tt.builtin, tt.args, tt.expected, inspected)
+		}
+		if out.String() != tt.output {
+			t.Errorf("%s%v: wrong output. want=%q, got=%q", tt.builtin, tt.args, tt.output, out.String())
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxJSONDepth The deepest nesting of arrays and objects the json module handles.
+const maxJSONDepth = 1000
+
+// jsonModule The json module, which converts between JSON text and objects.
+func jsonModule() *Module {
+	return &Module{Path: "json", Exports: map[string]Object{
+		"parse":     &Builtin{RuntimeFn: jsonParse},
+		"stringify": &Builtin{Fn: jsonStringify},
+	}}
+}
+
+// jsonParse parses JSON text. Objects become hashes, and numbers become integers if
+// they are whole numbers without a fraction or exponent, and floats otherwise. The
+// elements of arrays and objects count against the allocation limit of rt.
+func jsonParse(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	text, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `parse` must be STRING, got %s", objectTypeOf(args[0]))
+	}
+
+	obj, err := parseJSON(rt, text.Value)
+	if err != nil {
+		return ToError(err)
+	}
+	return obj
+}
+
+// ParseJSON parses the JSON text s as jsonParse does. Errors report the byte offset
+// they were found at.
+func ParseJSON(s string) (Object, error) {
+	return parseJSON(nil, s)
+}
+
+// parseJSON parses s, charging rt for the elements of arrays and objects.
+func parseJSON(rt Runtime, s string) (Object, error) {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+
+	obj, err := decodeJSON(rt, decoder, 0)
+	if IsAbort(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, jsonError(decoder, len(s), err)
+	}
+
+	// The offset of the data after the value is counted from where the value ends,
+	// as reading past it moves the decoder beyond the data.
+	end := decoder.InputOffset()
+	if _, err := decoder.Token(); err != io.EOF {
+		offset := len(s) - len(strings.TrimLeft(s[end:], " \t\r\n"))
+		return nil, fmt.Errorf("invalid JSON at offset %d: unexpected data after the value", offset)
+	}
+
+	return obj, nil
+}
+
+func jsonError(decoder *json.Decoder, length int, err error) error {
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("invalid JSON at offset %d: unexpected end of input", length)
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("invalid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr)
+	default:
+		return fmt.Errorf("invalid JSON at offset %d: %s", decoder.InputOffset(), err)
+	}
+}
+
+func decodeJSON(rt Runtime, decoder *json.Decoder, depth int) (Object, error) {
+	if depth > maxJSONDepth {
+		return nil, fmt.Errorf("nested deeper than %d levels", maxJSONDepth)
+	}
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token := token.(type) {
+	case nil:
+		return &Null{}, nil
+	case bool:
+		return &Boolean{Value: token}, nil
+	case string:
+		return &String{Value: token}, nil
+	case json.Number:
+		if i, err := strconv.ParseInt(token.String(), 10, 64); err == nil {
+			return &Integer{Value: i}, nil
+		}
+		f, err := token.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("number %s is out of range", token)
+		}
+		return &Float{Value: f}, nil
+	case json.Delim:
+		if token == '[' {
+			elements := []Object{}
+			for decoder.More() {
+				if err := allocate(rt, 1); err != nil {
+					return nil, err
+				}
+				element, err := decodeJSON(rt, decoder, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, element)
+			}
+			_, err := decoder.Token()
+			return &Array{Elements: elements}, err
+		}
+
+		pairs := make(map[HashKey]HashPair)
+		for decoder.More() {
+			if err := allocate(rt, 1); err != nil {
+				return nil, err
+			}
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := &String{Value: keyToken.(string)}
+
+			value, err := decodeJSON(rt, decoder, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		_, err := decoder.Token()
+		return &Hash{Pairs: pairs}, err
+	default:
+		return nil, fmt.Errorf("unexpected token %v", token)
+	}
+}
+
+// jsonStringify converts an object to JSON text. The optional second argument
+// indents the text, by a number of spaces or by a string.
+func jsonStringify(args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *Integer:
+			if arg.Value < 0 || arg.Value > 10 {
+				return newError("indent must be between 0 and 10 spaces, got %d", arg.Value)
+			}
+			indent = strings.Repeat(" ", int(arg.Value))
+		case *String:
+			indent = arg.Value
+		default:
+			return newError("argument 2 to `stringify` must be INTEGER or STRING, got %s", objectTypeOf(args[1]))
+		}
+	}
+
+	s, err := StringifyJSON(args[0], indent)
+	if err != nil {
+		return newError("%s", err)
+	}
+	return &String{Value: s}
+}
+
+// StringifyJSON converts obj to JSON text, indenting nested values by indent. Hash
+// keys are written in the order SortedPairs returns them.
+func StringifyJSON(obj Object, indent string) (string, error) {
+	var out bytes.Buffer
+	if err := encodeJSON(&out, obj, 0); err != nil {
+		return "", err
+	}
+
+	if indent == "" {
+		return out.String(), nil
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, out.Bytes(), "", indent); err != nil {
+		return "", err
+	}
+	return indented.String(), nil
+}
+
+func encodeJSON(out *bytes.Buffer, obj Object, depth int) error {
+	if depth > maxJSONDepth {
+		return fmt.Errorf("cannot convert to JSON: nested deeper than %d levels", maxJSONDepth)
+	}
+
+	switch obj := obj.(type) {
+	case nil, *Null:
+		out.WriteString("null")
+	case *Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return fmt.Errorf("cannot convert %s to JSON", obj.Inspect())
+		}
+		s := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			// Keep it a float when it is parsed again.
+			s += ".0"
+		}
+		out.WriteString(s)
+	case *String:
+		encodeJSONString(out, obj.Value)
+	case *Array:
+		out.WriteByte('[')
+		for i, element := range obj.Elements {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := encodeJSON(out, element, depth+1); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+	case *Hash:
+		out.WriteByte('{')
+		for i, pair := range SortedPairs(obj) {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			key := pair.Key.Inspect()
+			encodeJSONString(out, key)
+			out.WriteByte(':')
+			if err := encodeJSON(out, pair.Value, depth+1); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot convert %s to JSON", obj.Type())
+	}
+
+	return nil
+}
+
+func encodeJSONString(out *bytes.Buffer, s string) {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	// Encode ends the value with a newline.
+	out.Truncate(out.Len() - 1)
+}
@@ -0,0 +1,85 @@
+package object
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a": [1, 2.5, "x", null, true, false]}`, "{a: [1, 2.500000, x, null, true, false]}"},
+		{`"héllo\n"`, "héllo\n"},
+		{` 42 `, "42"},
+		{`9223372036854775808`, "9223372036854775808.000000"},
+		{`1e2`, "100.000000"},
+		{`[]`, "[]"},
+		{`{}`, "{}"},
+		{`[1,]`, "ERROR: invalid JSON at offset 3: invalid character ',' looking for beginning of value"},
+		{`{"a" 1}`, "ERROR: invalid JSON at offset 6: invalid character '1' after object key"},
+		{`[1, 2`, "ERROR: invalid JSON at offset 5: unexpected end of JSON input"},
+		{`"abc`, "ERROR: invalid JSON at offset 4: unexpected end of input"},
+		{`{"a": 1}  x`, "ERROR: invalid JSON at offset 10: unexpected data after the value"},
+		{`1 2`, "ERROR: invalid JSON at offset 2: unexpected data after the value"},
+		{`[1] 2`, "ERROR: invalid JSON at offset 4: unexpected data after the value"},
+		{`"a"  "b"`, "ERROR: invalid JSON at offset 5: unexpected data after the value"},
+		{``, "ERROR: invalid JSON at offset 0: unexpected end of input"},
+		{`[1e400]`, "ERROR: invalid JSON at offset 6: number 1e400 is out of range"},
+	}
+
+	for _, tt := range tests {
+		result := jsonParse(nil, str(tt.input))
+		if result.Inspect() != tt.expected {
+			t.Errorf("%q: wrong result. want=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestStringifyJSON(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, pair := range []HashPair{
+		{Key: str("name"), Value: str("<Ann & \"Bo\">")},
+		{Key: str("tags"), Value: array(integer(1), float(2), &Null{})},
+		{Key: integer(7), Value: &Boolean{Value: true}},
+	} {
+		hash.Pairs[pair.Key.(Hashable).HashKey()] = pair
+	}
+
+	tests := []struct {
+		args     []Object
+		expected string
+	}{
+		{[]Object{hash}, `{"7":true,"name":"<Ann & \"Bo\">","tags":[1,2.0,null]}`},
+		{[]Object{array(integer(1), array()), integer(2)}, "[\n  1,\n  []\n]"},
+		{[]Object{&Hash{Pairs: map[HashKey]HashPair{}}, str("\t")}, "{}"},
+		{[]Object{array(float(0.1), float(1e21))}, "[0.1,1e+21]"},
+		{[]Object{float(1), integer(-1)}, "ERROR: indent must be between 0 and 10 spaces, got -1"},
+		{[]Object{&Builtin{}}, "ERROR: cannot convert BUILTIN to JSON"},
+		{[]Object{array(float(1), &Float{Value: math.Inf(1)})}, "ERROR: cannot convert +Inf to JSON"},
+	}
+
+	for _, tt := range tests {
+		result := jsonStringify(tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%v: wrong result. want=%q, got=%q", tt.args, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := `{"a":[1,2.5,"x",null,true],"b":{"c":2.0}}`
+
+	obj, err := ParseJSON(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	output, err := StringifyJSON(obj, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if output != input {
+		t.Errorf("wrong output. want=%s, got=%s", input, output)
+	}
+}
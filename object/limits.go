@@ -0,0 +1,103 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxCallDepth is the number of nested function calls allowed when
+// Limits.MaxCallDepth is zero. Calls are always capped, so a runaway recursion
+// fails with a CallDepthError instead of overflowing the host's stack.
+const DefaultMaxCallDepth = 10000
+
+// Limits caps the resources a single execution may use. A zero field means no limit,
+// except for MaxCallDepth.
+type Limits struct {
+	// MaxInstructions is the number of instructions the VM, or evaluation steps
+	// the evaluator, may execute.
+	MaxInstructions int
+	// MaxCallDepth is the number of nested function calls, DefaultMaxCallDepth if
+	// it is zero.
+	MaxCallDepth int
+	// MaxAllocations is the number of objects that may be allocated.
+	MaxAllocations int
+}
+
+// CallDepth returns the call depth limit in effect.
+func (l Limits) CallDepth() int {
+	if l.MaxCallDepth > 0 {
+		return l.MaxCallDepth
+	}
+	return DefaultMaxCallDepth
+}
+
+// InstructionLimitError is returned when an execution exceeds Limits.MaxInstructions.
+type InstructionLimitError struct {
+	Limit int
+}
+
+func (e *InstructionLimitError) Error() string {
+	return fmt.Sprintf("instruction limit of %d exceeded", e.Limit)
+}
+
+// CallDepthError is returned when an execution exceeds Limits.MaxCallDepth.
+type CallDepthError struct {
+	Limit int
+}
+
+func (e *CallDepthError) Error() string {
+	return fmt.Sprintf("call depth limit of %d exceeded", e.Limit)
+}
+
+// AllocationLimitError is returned when an execution exceeds Limits.MaxAllocations.
+type AllocationLimitError struct {
+	Limit int
+}
+
+func (e *AllocationLimitError) Error() string {
+	return fmt.Sprintf("allocation limit of %d exceeded", e.Limit)
+}
+
+// CancelError is returned when an execution is aborted because its context was
+// canceled or its deadline passed. It unwraps to the context's error.
+type CancelError struct {
+	Err error
+}
+
+func (e *CancelError) Error() string {
+	return "execution canceled: " + e.Err.Error()
+}
+
+func (e *CancelError) Unwrap() error {
+	return e.Err
+}
+
+// IsAbort reports whether err is, or wraps, a limit or cancel error. These errors
+// abort an execution as a whole, even if they happen in a call made by a builtin.
+func IsAbort(err error) bool {
+	var (
+		instructions *InstructionLimitError
+		depth        *CallDepthError
+		allocations  *AllocationLimitError
+		cancel       *CancelError
+	)
+	return errors.As(err, &instructions) || errors.As(err, &depth) ||
+		errors.As(err, &allocations) || errors.As(err, &cancel)
+}
+
+// AllocatorRuntime is implemented by runtimes that count allocations against
+// Limits.MaxAllocations. Builtins that make collections charge it one allocation per
+// element before making them, so a single call can't get around the limit.
+type AllocatorRuntime interface {
+	Runtime
+	Allocate(n int) error
+}
+
+// allocate charges rt with n allocations if it counts them, and returns the error if
+// that exceeds its limit.
+func allocate(rt Runtime, n int) error {
+	if rt, ok := rt.(AllocatorRuntime); ok {
+		return rt.Allocate(n)
+	}
+	return nil
+}
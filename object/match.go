@@ -0,0 +1,191 @@
+package object
+
+import (
+	"fmt"
+
+	"Gengo/ast"
+)
+
+// Pattern A pattern of a match expression or of a destructuring binding, held in
+// the constants of compiled code.
+type Pattern struct {
+	Pattern ast.Pattern
+}
+
+// Type The object's type.
+func (p *Pattern) Type() ObjectType {
+	return PATTERN
+}
+
+// Inspect A string of the type.
+func (p *Pattern) Inspect() string {
+	return p.Pattern.String()
+}
+
+// Match Matches value against pattern. If it matches, it returns the values bound
+// to the identifiers ast.Bindings returns for the pattern, in the same order.
+func Match(pattern ast.Pattern, value Object) ([]Object, bool) {
+	var bound []Object
+	if !match(pattern, value, &bound) {
+		return nil, false
+	}
+	return bound, true
+}
+
+// Destructure Matches value against pattern like Match, but returns an error telling
+// why if it does not match.
+func Destructure(pattern ast.Pattern, value Object) ([]Object, *Error) {
+	if bound, ok := Match(pattern, value); ok {
+		return bound, nil
+	}
+	return nil, newError("cannot destructure %s with %s: %s", objectTypeOf(value), pattern, mismatch(pattern, value))
+}
+
+// mismatch Describes the first part of value that does not match pattern.
+func mismatch(pattern ast.Pattern, value Object) string {
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		array, ok := value.(*Array)
+		if !ok {
+			return fmt.Sprintf("want ARRAY, got %s", objectTypeOf(value))
+		}
+		if pattern.Rest != nil && len(array.Elements) < len(pattern.Elements) {
+			return fmt.Sprintf("want length >= %d, got %d", len(pattern.Elements), len(array.Elements))
+		}
+		if pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+			return fmt.Sprintf("want length %d, got %d", len(pattern.Elements), len(array.Elements))
+		}
+		for i, element := range pattern.Elements {
+			if _, ok := Match(element, array.Elements[i]); !ok {
+				return fmt.Sprintf("element %d: %s", i, mismatch(element, array.Elements[i]))
+			}
+		}
+
+	case *ast.HashPattern:
+		hash, ok := value.(*Hash)
+		if !ok {
+			return fmt.Sprintf("want HASH, got %s", objectTypeOf(value))
+		}
+		for _, pair := range pattern.Pairs {
+			key := literalObject(pair.Key).(Hashable)
+			found, ok := hash.Pairs[key.HashKey()]
+			if !ok {
+				return fmt.Sprintf("missing key %s", keyString(pair.Key))
+			}
+			if _, ok := Match(pair.Value, found.Value); !ok {
+				return fmt.Sprintf("key %s: %s", keyString(pair.Key), mismatch(pair.Value, found.Value))
+			}
+		}
+
+	case *ast.LiteralPattern:
+		if value == nil {
+			return fmt.Sprintf("want %s, got null", pattern)
+		}
+		return fmt.Sprintf("want %s, got %s", pattern, value.Inspect())
+	}
+
+	return "no match"
+}
+
+// keyString Quotes string keys, like patterns do.
+func keyString(key ast.Expression) string {
+	return (&ast.LiteralPattern{Value: key}).String()
+}
+
+func match(pattern ast.Pattern, value Object, bound *[]Object) bool {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return true
+
+	case *ast.Identifier:
+		*bound = append(*bound, value)
+		return true
+
+	case *ast.LiteralPattern:
+		return literalEqual(pattern.Value, value)
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*Array)
+		if !ok || len(array.Elements) < len(pattern.Elements) {
+			return false
+		}
+		if pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+			return false
+		}
+
+		for i, element := range pattern.Elements {
+			if !match(element, array.Elements[i], bound) {
+				return false
+			}
+		}
+
+		if pattern.Rest != nil {
+			// Like the rest builtin's, the rest of a frozen array is frozen.
+			rest := make([]Object, len(array.Elements)-len(pattern.Elements))
+			copy(rest, array.Elements[len(pattern.Elements):])
+			return match(pattern.Rest, &Array{Elements: rest, Frozen: array.Frozen}, bound)
+		}
+		return true
+
+	case *ast.HashPattern:
+		hash, ok := value.(*Hash)
+		if !ok {
+			return false
+		}
+
+		for _, pair := range pattern.Pairs {
+			key, ok := literalObject(pair.Key).(Hashable)
+			if !ok {
+				return false
+			}
+			found, ok := hash.Pairs[key.HashKey()]
+			if !ok || !match(pair.Value, found.Value, bound) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return false
+	}
+}
+
+// literalObject The object a literal of a pattern evaluates to.
+func literalObject(exp ast.Expression) Object {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return &Integer{Value: exp.Value}
+	case *ast.FloatLiteral:
+		return &Float{Value: exp.Value}
+	case *ast.StringLiteral:
+		return &String{Value: exp.Value}
+	case *ast.Boolean:
+		return &Boolean{Value: exp.Value}
+	case *ast.NullLiteral:
+		return &Null{}
+	default:
+		return nil
+	}
+}
+
+func literalEqual(exp ast.Expression, value Object) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		i, ok := value.(*Integer)
+		return ok && i.Value == exp.Value
+	case *ast.FloatLiteral:
+		f, ok := value.(*Float)
+		return ok && f.Value == exp.Value
+	case *ast.StringLiteral:
+		s, ok := value.(*String)
+		return ok && s.Value == exp.Value
+	case *ast.Boolean:
+		b, ok := value.(*Boolean)
+		return ok && b.Value == exp.Value
+	case *ast.NullLiteral:
+		_, ok := value.(*Null)
+		return ok
+	default:
+		return false
+	}
+}
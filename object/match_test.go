@@ -0,0 +1,104 @@
+package object
+
+import (
+	"testing"
+
+	"Gengo/ast"
+	"Gengo/lexer"
+	"Gengo/parser"
+)
+
+func parsePattern(t *testing.T, input string) ast.Pattern {
+	t.Helper()
+
+	p := parser.New(lexer.New("match (x) { " + input + " => 0 }"))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression).Arms[0].Pattern
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		value    Object
+		expected string
+	}{
+		{"_", integer(1), "[]"},
+		{"a", str("x"), "[x]"},
+		{"1", integer(1), "[]"},
+		{"1", float(1), ""},
+		{"-2.5", float(-2.5), "[]"},
+		{`"a"`, str("a"), "[]"},
+		{"true", &Boolean{Value: false}, ""},
+		{"[a, b]", array(integer(1), integer(2)), "[1, 2]"},
+		{"[a, b]", array(integer(1)), ""},
+		{"[a, ...r]", array(integer(1), integer(2), integer(3)), "[1, [2, 3]]"},
+		{"[a, ...r]", array(integer(1)), "[1, []]"},
+		{"[a, ...r]", array(), ""},
+		{"[a, [b, _]]", array(integer(1), array(integer(2), integer(3))), "[1, 2]"},
+		{"[a]", str("a"), ""},
+		{`{"y": y, "x": x}`, hashOf(str("x"), integer(1), str("y"), integer(2)), "[2, 1]"},
+		{`{"x": 1}`, hashOf(str("x"), integer(2)), ""},
+		{`{1: a}`, hashOf(str("x"), integer(2)), ""},
+	}
+
+	for _, tt := range tests {
+		pattern := parsePattern(t, tt.pattern)
+		bound, ok := Match(pattern, tt.value)
+		if tt.expected == "" {
+			if ok {
+				t.Errorf("%s matched %s", tt.pattern, tt.value.Inspect())
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s did not match %s", tt.pattern, tt.value.Inspect())
+			continue
+		}
+		if got := array(bound...).Inspect(); got != tt.expected {
+			t.Errorf("%s: wrong bindings. want=%s, got=%s", tt.pattern, tt.expected, got)
+		}
+		if len(ast.Bindings(pattern)) != len(bound) {
+			t.Errorf("%s: %d values bound for %d identifiers", tt.pattern, len(bound), len(ast.Bindings(pattern)))
+		}
+	}
+}
+
+func TestDestructure(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		value    Object
+		expected string
+	}{
+		{"[a, b]", array(integer(1), integer(2)), "[1, 2]"},
+		{"[a, b]", integer(1), "ERROR: cannot destructure INTEGER with [a, b]: want ARRAY, got INTEGER"},
+		{"[a, ...r]", array(), "ERROR: cannot destructure ARRAY with [a, ...r]: want length >= 1, got 0"},
+		{"[a, 1]", array(integer(1), integer(2)), "ERROR: cannot destructure ARRAY with [a, 1]: element 1: want 1, got 2"},
+		{"{name}", hashOf(str("name"), str("ada")), "[ada]"},
+		{"{name}", hashOf(str("age"), integer(1)), `ERROR: cannot destructure HASH with {name}: missing key "name"`},
+		{`{"a": {b}}`, hashOf(str("a"), hashOf()), `ERROR: cannot destructure HASH with {"a": {b}}: key "a": missing key "b"`},
+	}
+
+	for _, tt := range tests {
+		bound, errObj := Destructure(parsePattern(t, tt.pattern), tt.value)
+		var got string
+		if errObj != nil {
+			got = errObj.Inspect()
+		} else {
+			got = array(bound...).Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%s: wrong result. want=%q, got=%q", tt.pattern, tt.expected, got)
+		}
+	}
+}
+
+func hashOf(pairs ...Object) *Hash {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for i := 0; i < len(pairs); i += 2 {
+		hash.Pairs[pairs[i].(Hashable).HashKey()] = HashPair{Key: pairs[i], Value: pairs[i+1]}
+	}
+	return hash
+}
@@ -0,0 +1,307 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Random A source of random numbers for math.random and math.randomInt, which
+// math.seed seeds for repeatable results. Each interpreter has its own, so seeding
+// it doesn't change the numbers of others. It is safe for concurrent use.
+type Random struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewRandom Creates a source of random numbers seeded with seed.
+func NewRandom(seed int64) *Random {
+	return &Random{rand: rand.New(rand.NewSource(seed))}
+}
+
+// Float64 Returns a random float in [0, 1).
+func (r *Random) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Float64()
+}
+
+// Int63n Returns a random integer in [0, n). n must be positive.
+func (r *Random) Int63n(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Int63n(n)
+}
+
+// Seed Seeds the source, so the numbers repeat for the same seed.
+func (r *Random) Seed(seed int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rand.Seed(seed)
+}
+
+// RandomRuntime is implemented by runtimes with their own source of random numbers.
+// Builtins called by other runtimes share one.
+type RandomRuntime interface {
+	Runtime
+	Random() *Random
+}
+
+var sharedRandom = NewRandom(time.Now().UnixNano())
+
+// runtimeRandom returns the source of random numbers of rt.
+func runtimeRandom(rt Runtime) *Random {
+	if rt, ok := rt.(RandomRuntime); ok {
+		if random := rt.Random(); random != nil {
+			return random
+		}
+	}
+	return sharedRandom
+}
+
+// mathModule The math module. Its functions take integers and floats alike.
+func mathModule() *Module {
+	mod := nativeModule("math", map[string]interface{}{
+		"abs":       absFunc,
+		"min":       minFunc,
+		"max":       maxFunc,
+		"sqrt":      math.Sqrt,
+		"pow":       math.Pow,
+		"exp":       math.Exp,
+		"log":       math.Log,
+		"log2":      math.Log2,
+		"log10":     math.Log10,
+		"floor":     math.Floor,
+		"ceil":      math.Ceil,
+		"round":     math.Round,
+		"trunc":     math.Trunc,
+		"sin":       math.Sin,
+		"cos":       math.Cos,
+		"tan":       math.Tan,
+		"asin":      math.Asin,
+		"acos":      math.Acos,
+		"atan":      math.Atan,
+		"atan2":     math.Atan2,
+		"hypot":     math.Hypot,
+		"isNaN":     math.IsNaN,
+		"isInf":     func(f float64) bool { return math.IsInf(f, 0) },
+		"random":    randomFloat,
+		"randomInt": randomInt,
+		"seed":      seedRandom,
+	})
+
+	mod.Exports["pi"] = &Float{Value: math.Pi}
+	mod.Exports["e"] = &Float{Value: math.E}
+	mod.Exports["inf"] = &Float{Value: math.Inf(1)}
+	mod.Exports["nan"] = &Float{Value: math.NaN()}
+
+	return mod
+}
+
+func absFunc(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		if arg.Value == math.MinInt64 {
+			return newError("integer overflow: abs(%d)", arg.Value)
+		}
+		if arg.Value < 0 {
+			return &Integer{Value: -arg.Value}
+		}
+		return arg
+	case *Float:
+		return &Float{Value: math.Abs(arg.Value)}
+	default:
+		return newError("argument to `abs` must be INTEGER or FLOAT, got %s", objectTypeOf(args[0]))
+	}
+}
+
+func minFunc(args ...Object) Object {
+	return extremum("min", args, func(a, b float64) bool { return a < b })
+}
+
+func maxFunc(args ...Object) Object {
+	return extremum("max", args, func(a, b float64) bool { return a > b })
+}
+
+// extremum returns the argument that precedes all others according to precedes. The
+// result is an integer if all arguments are.
+func extremum(name string, args []Object, precedes func(a, b float64) bool) Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want>=1")
+	}
+
+	var result Object
+	var best float64
+	allIntegers := true
+
+	for i, arg := range args {
+		var value float64
+		switch arg := arg.(type) {
+		case *Integer:
+			value = float64(arg.Value)
+		case *Float:
+			value = arg.Value
+			allIntegers = false
+		default:
+			return newError("argument %d to `%s` must be INTEGER or FLOAT, got %s", i+1, name, objectTypeOf(arg))
+		}
+
+		if result == nil || precedes(value, best) || math.IsNaN(value) {
+			result, best = arg, value
+		}
+		if math.IsNaN(best) {
+			break
+		}
+	}
+
+	if _, ok := result.(*Float); ok || allIntegers {
+		return result
+	}
+	return &Float{Value: best}
+}
+
+func randomFloat(rt Runtime) float64 {
+	return runtimeRandom(rt).Float64()
+}
+
+// randomInt returns a random integer in [0, n).
+func randomInt(rt Runtime, n int64) (int64, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("randomInt bound must be positive, got %d", n)
+	}
+	return runtimeRandom(rt).Int63n(n), nil
+}
+
+// seedRandom seeds the source of random numbers, so they repeat for the same seed.
+func seedRandom(rt Runtime, seed int64) {
+	runtimeRandom(rt).Seed(seed)
+}
+
+// intFunc converts its argument to an integer. Floats are truncated towards zero.
+func intFunc(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		return arg
+	case *Float:
+		if math.IsNaN(arg.Value) || arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 {
+			return newError("%s does not fit in an integer", arg.Inspect())
+		}
+		return &Integer{Value: int64(arg.Value)}
+	case *Boolean:
+		if arg.Value {
+			return &Integer{Value: 1}
+		}
+		return &Integer{Value: 0}
+	case *String:
+		return parseInteger(arg.Value, 10)
+	default:
+		return newError("argument to `int` not supported, got %s", objectTypeOf(args[0]))
+	}
+}
+
+// floatFunc converts its argument to a float.
+func floatFunc(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		return &Float{Value: float64(arg.Value)}
+	case *Float:
+		return arg
+	case *Boolean:
+		if arg.Value {
+			return &Float{Value: 1}
+		}
+		return &Float{Value: 0}
+	case *String:
+		return parseFloat(arg.Value)
+	default:
+		return newError("argument to `float` not supported, got %s", objectTypeOf(args[0]))
+	}
+}
+
+// strFunc converts its argument to the string it is displayed as.
+func strFunc(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	if s, ok := args[0].(*String); ok {
+		return s
+	}
+	if args[0] == nil {
+		return &String{Value: "null"}
+	}
+	return &String{Value: args[0].Inspect()}
+}
+
+// parseIntFunc parses a string as an integer in base 10, or in the base given as the
+// second argument. Base 0 reads the base from a 0x, 0o or 0b prefix.
+func parseIntFunc(args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	s, ok := args[0].(*String)
+	if !ok {
+		return newError("argument 1 to `parseInt` must be STRING, got %s", objectTypeOf(args[0]))
+	}
+
+	base := int64(10)
+	if len(args) == 2 {
+		b, ok := args[1].(*Integer)
+		if !ok {
+			return newError("argument 2 to `parseInt` must be INTEGER, got %s", objectTypeOf(args[1]))
+		}
+		if b.Value != 0 && (b.Value < 2 || b.Value > 36) {
+			return newError("invalid base %d", b.Value)
+		}
+		base = b.Value
+	}
+
+	return parseInteger(s.Value, int(base))
+}
+
+// parseFloatFunc parses a string as a float.
+func parseFloatFunc(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	s, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `parseFloat` must be STRING, got %s", objectTypeOf(args[0]))
+	}
+
+	return parseFloat(s.Value)
+}
+
+func parseInteger(s string, base int) Object {
+	value, err := strconv.ParseInt(strings.TrimSpace(s), base, 64)
+	if err != nil {
+		return newError("could not parse %q as integer", s)
+	}
+	return &Integer{Value: value}
+}
+
+func parseFloat(s string) Object {
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return newError("could not parse %q as float", s)
+	}
+	return &Float{Value: value}
+}
@@ -0,0 +1,140 @@
+package object
+
+import "testing"
+
+func float(f float64) *Float { return &Float{Value: f} }
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		fn       string
+		args     []Object
+		expected string
+	}{
+		{"abs", []Object{integer(-3)}, "3"},
+		{"abs", []Object{float(-2.5)}, "2.500000"},
+		{"abs", []Object{str("x")}, "ERROR: argument to `abs` must be INTEGER or FLOAT, got STRING"},
+		{"min", []Object{integer(3), integer(1), integer(2)}, "1"},
+		{"min", []Object{integer(3), float(1.5)}, "1.500000"},
+		{"max", []Object{integer(3), float(1.5)}, "3.000000"},
+		{"max", []Object{}, "ERROR: wrong number of arguments. got=0, want>=1"},
+		{"max", []Object{integer(1), &Boolean{Value: true}}, "ERROR: argument 2 to `max` must be INTEGER or FLOAT, got BOOLEAN"},
+		{"sqrt", []Object{integer(16)}, "4.000000"},
+		{"pow", []Object{integer(2), integer(10)}, "1024.000000"},
+		{"floor", []Object{float(2.7)}, "2.000000"},
+		{"ceil", []Object{float(2.1)}, "3.000000"},
+		{"round", []Object{float(2.5)}, "3.000000"},
+		{"sin", []Object{integer(0)}, "0.000000"},
+		{"atan2", []Object{integer(1), integer(1)}, "0.785398"},
+		{"log", []Object{integer(1)}, "0.000000"},
+		{"isNaN", []Object{float(1)}, "false"},
+		{"randomInt", []Object{integer(0)}, "ERROR: randomInt bound must be positive, got 0"},
+	}
+
+	mod, ok := GetModule("math")
+	if !ok {
+		t.Fatalf("math module is not registered")
+	}
+
+	for _, tt := range tests {
+		fn, ok := mod.Attribute(tt.fn)
+		if !ok {
+			t.Fatalf("math.%s does not exist", tt.fn)
+		}
+
+		result := fn.(*Builtin).Invoke(nil, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("math.%s%v: wrong result. want=%q, got=%q", tt.fn, tt.args, tt.expected, result.Inspect())
+		}
+	}
+
+	for name, expected := range map[string]string{"pi": "3.141593", "e": "2.718282", "inf": "+Inf", "nan": "NaN"} {
+		constant, ok := mod.Attribute(name)
+		if !ok {
+			t.Fatalf("math.%s does not exist", name)
+		}
+		if constant.Inspect() != expected {
+			t.Errorf("math.%s: wrong value. want=%s, got=%s", name, expected, constant.Inspect())
+		}
+	}
+}
+
+// randomRuntime is a runtime with its own source of random numbers that can only
+// call builtins.
+type randomRuntime struct {
+	Caller
+	random *Random
+}
+
+func (rt randomRuntime) Random() *Random {
+	return rt.random
+}
+
+func TestRandomSeed(t *testing.T) {
+	draw := func(rt Runtime) []int64 {
+		var numbers []int64
+		for i := 0; i < 5; i++ {
+			n, err := randomInt(rt, 1000)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			numbers = append(numbers, n)
+		}
+		return numbers
+	}
+
+	rt := randomRuntime{Caller: callBuiltin, random: NewRandom(1)}
+	other := randomRuntime{Caller: callBuiltin, random: NewRandom(1)}
+
+	seedRandom(rt, 42)
+	first := draw(rt)
+	seedRandom(rt, 42)
+	seedRandom(other, 7)
+	second := draw(rt)
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("numbers differ for the same seed: %v, %v", first, second)
+		}
+	}
+
+	if f := randomFloat(rt); f < 0 || f >= 1 {
+		t.Errorf("random float out of range: %f", f)
+	}
+	if runtimeRandom(Caller(callBuiltin)) != sharedRandom {
+		t.Errorf("runtime without a source of its own doesn't use the shared one")
+	}
+}
+
+func TestConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		fn       BuiltinFunction
+		args     []Object
+		expected string
+	}{
+		{intFunc, []Object{float(-2.9)}, "-2"},
+		{intFunc, []Object{str("42")}, "42"},
+		{intFunc, []Object{&Boolean{Value: true}}, "1"},
+		{intFunc, []Object{str("4.2")}, `ERROR: could not parse "4.2" as integer`},
+		{intFunc, []Object{float(1e19)}, "ERROR: 10000000000000000000.000000 does not fit in an integer"},
+		{intFunc, []Object{&Array{}}, "ERROR: argument to `int` not supported, got ARRAY"},
+		{floatFunc, []Object{integer(3)}, "3.000000"},
+		{floatFunc, []Object{str(" 2.5 ")}, "2.500000"},
+		{floatFunc, []Object{str("abc")}, `ERROR: could not parse "abc" as float`},
+		{strFunc, []Object{integer(12)}, "12"},
+		{strFunc, []Object{&Array{Elements: []Object{integer(1), str("a")}}}, "[1, a]"},
+		{parseIntFunc, []Object{str("ff"), integer(16)}, "255"},
+		{parseIntFunc, []Object{str("0b101"), integer(0)}, "5"},
+		{parseIntFunc, []Object{str("12")}, "12"},
+		{parseIntFunc, []Object{str("12"), integer(1)}, "ERROR: invalid base 1"},
+		{parseIntFunc, []Object{str("9223372036854775808")}, `ERROR: could not parse "9223372036854775808" as integer`},
+		{parseFloatFunc, []Object{str("1e3")}, "1000.000000"},
+		{parseFloatFunc, []Object{integer(1)}, "ERROR: argument to `parseFloat` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		result := tt.fn(tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%v: wrong result. want=%q, got=%q", tt.args, tt.expected, result.Inspect())
+		}
+	}
+}
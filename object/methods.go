@@ -0,0 +1,145 @@
+package object
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// MethodFunction is a method of a type. receiver is the object the method was read
+// from, and args are the arguments of the call.
+type MethodFunction func(rt Runtime, receiver Object, args ...Object) Object
+
+// defaultMethods The methods of each type, called with obj.name(args). The functions
+// of the strings module that take a string first are methods of strings too.
+func defaultMethods() map[ObjectType]map[string]MethodFunction {
+	methods := map[ObjectType]map[string]MethodFunction{
+		STRING: {
+			"len": stringLen,
+		},
+		ARRAY: {
+			"len":     builtinMethod(&Builtin{Fn: lenFunc}, 0, 0),
+			"first":   builtinMethod(&Builtin{RuntimeFn: firstFunc}, 0, 0),
+			"last":    builtinMethod(&Builtin{RuntimeFn: lastFunc}, 0, 0),
+			"rest":    builtinMethod(&Builtin{RuntimeFn: restFunc}, 0, 0),
+			"push":    builtinMethod(&Builtin{RuntimeFn: pushFunc}, 1, 1),
+			"map":     builtinMethod(&Builtin{RuntimeFn: mapFunc}, 1, 1),
+			"filter":  builtinMethod(&Builtin{RuntimeFn: filterFunc}, 1, 1),
+			"reduce":  builtinMethod(&Builtin{RuntimeFn: reduceFunc}, 1, 2),
+			"sort":    builtinMethod(&Builtin{RuntimeFn: sortFunc}, 0, 1),
+			"reverse": builtinMethod(&Builtin{RuntimeFn: reverseFunc}, 0, 0),
+			"any":     builtinMethod(&Builtin{RuntimeFn: anyFunc}, 0, 1),
+			"all":     builtinMethod(&Builtin{RuntimeFn: allFunc}, 0, 1),
+		},
+	}
+
+	strings := stringsModule().Exports
+	for _, method := range []struct {
+		name             string
+		minArgs, maxArgs int
+	}{
+		{"split", 1, 1},
+		{"trim", 0, 1},
+		{"upper", 0, 0},
+		{"lower", 0, 0},
+		{"replace", 2, 2},
+		{"contains", 1, 1},
+		{"startsWith", 1, 1},
+		{"endsWith", 1, 1},
+		{"indexOf", 1, 1},
+		{"substring", 1, 2},
+		{"repeat", 1, 1},
+		{"format", 0, -1},
+		{"chars", 0, 0},
+		{"ord", 0, 0},
+	} {
+		builtin := strings[method.name].(*Builtin)
+		methods[STRING][method.name] = builtinMethod(builtin, method.minArgs, method.maxArgs)
+	}
+
+	return methods
+}
+
+// RegisterMethod adds the method name to the objects of type t in every program
+// whose interpreter doesn't have a registry of its own, and in the registries
+// created after it. See Registry.RegisterMethod.
+func RegisterMethod(t ObjectType, name string, fn MethodFunction) error {
+	return defaultRegistry.RegisterMethod(t, name, fn)
+}
+
+// GetMethod returns the method name of the objects of type t in the default
+// registry.
+func GetMethod(t ObjectType, name string) (MethodFunction, bool) {
+	return defaultRegistry.GetMethod(t, name)
+}
+
+// GetAttribute returns obj.name with the methods of the default registry. See
+// Registry.GetAttribute.
+func GetAttribute(obj Object, name string) (Object, error) {
+	return defaultRegistry.GetAttribute(obj, name)
+}
+
+// GetAttribute returns obj.name. It is the value of the key name of a hash, the
+// attribute of an Attributable, or a method of the object's type bound to the
+// object, tried in that order. A hash without the key or the method has null for
+// it.
+func (r *Registry) GetAttribute(obj Object, name string) (Object, error) {
+	if hash, ok := obj.(*Hash); ok {
+		key := &String{Value: name}
+		if pair, ok := hash.Pairs[key.HashKey()]; ok {
+			return pair.Value, nil
+		}
+	}
+
+	if attributable, ok := obj.(Attributable); ok {
+		if value, ok := attributable.Attribute(name); ok {
+			if value == nil {
+				return &Null{}, nil
+			}
+			return value, nil
+		}
+	}
+
+	if method, ok := r.GetMethod(obj.Type(), name); ok {
+		return bindMethod(obj, method), nil
+	}
+
+	switch obj.(type) {
+	case *Hash:
+		return &Null{}, nil
+	case Attributable:
+		return nil, fmt.Errorf("unknown attribute: %s.%s", obj.Type(), name)
+	}
+	if r.hasMethods(obj.Type()) {
+		return nil, fmt.Errorf("unknown attribute: %s.%s", obj.Type(), name)
+	}
+	return nil, fmt.Errorf("attribute access not supported: %s", obj.Type())
+}
+
+// bindMethod returns a builtin that calls method with receiver.
+func bindMethod(receiver Object, method MethodFunction) *Builtin {
+	return &Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+		return method(rt, receiver, args...)
+	}}
+}
+
+// builtinMethod turns a builtin taking the receiver and from minArgs to maxArgs more
+// arguments, or any number if maxArgs is negative, into a method.
+func builtinMethod(builtin *Builtin, minArgs, maxArgs int) MethodFunction {
+	return func(rt Runtime, receiver Object, args ...Object) Object {
+		switch {
+		case len(args) >= minArgs && (maxArgs < 0 || len(args) <= maxArgs):
+		case minArgs == maxArgs:
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), minArgs)
+		default:
+			return newError("wrong number of arguments. got=%d, want=%d or %d", len(args), minArgs, maxArgs)
+		}
+		return builtin.Invoke(rt, append([]Object{receiver}, args...)...)
+	}
+}
+
+func stringLen(rt Runtime, receiver Object, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &Integer{Value: int64(utf8.RuneCountInString(receiver.(*String).Value))}
+}
@@ -0,0 +1,101 @@
+package object
+
+import "testing"
+
+func TestGetAttribute(t *testing.T) {
+	name := &String{Value: "name"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{
+		name.HashKey(): {Key: name, Value: &String{Value: "gengo"}},
+	}}
+
+	tests := []struct {
+		obj      Object
+		name     string
+		expected string
+	}{
+		{hash, "name", "gengo"},
+		{hash, "missing", "null"},
+		{&String{Value: "abc"}, "upper", "builtin function"},
+	}
+
+	for _, tt := range tests {
+		result, err := GetAttribute(tt.obj, tt.name)
+		if err != nil {
+			t.Fatalf("%s.%s: unexpected error: %s", tt.obj.Inspect(), tt.name, err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s.%s: want=%s, got=%s", tt.obj.Inspect(), tt.name, tt.expected, result.Inspect())
+		}
+	}
+
+	upper, _ := GetAttribute(&String{Value: "abc"}, "upper")
+	if result := upper.(*Builtin).Invoke(nil); result.Inspect() != "ABC" {
+		t.Errorf("wrong result of bound method. want=ABC, got=%s", result.Inspect())
+	}
+
+	if _, err := GetAttribute(&Boolean{Value: true}, "x"); err == nil || err.Error() != "attribute access not supported: BOOLEAN" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestRegisterMethod(t *testing.T) {
+	r := NewRegistry()
+	err := r.RegisterMethod(INTEGER, "double", func(rt Runtime, receiver Object, args ...Object) Object {
+		return &Integer{Value: receiver.(*Integer).Value * 2}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	double, err := r.GetAttribute(&Integer{Value: 21}, "double")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result := double.(*Builtin).Invoke(nil); result.Inspect() != "42" {
+		t.Errorf("wrong result. want=42, got=%s", result.Inspect())
+	}
+
+	if _, err := r.GetAttribute(&Integer{Value: 1}, "triple"); err == nil || err.Error() != "unknown attribute: INTEGER.triple" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	// Neither the default registry nor the registries created from it see the method.
+	if _, err := GetAttribute(&Integer{Value: 1}, "double"); err == nil || err.Error() != "attribute access not supported: INTEGER" {
+		t.Errorf("wrong error from the default registry. got=%v", err)
+	}
+	if _, ok := NewRegistry().GetMethod(INTEGER, "double"); ok {
+		t.Errorf("method registered with one registry is in another")
+	}
+
+	if err := r.RegisterMethod(STRING, "upper", func(rt Runtime, receiver Object, args ...Object) Object { return nil }); err == nil {
+		t.Errorf("expected an error registering a method twice")
+	}
+}
+
+func TestStringMethods(t *testing.T) {
+	tests := []struct {
+		receiver string
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"a,b", "split", []Object{&String{Value: ","}}, "[a, b]"},
+		{"  a ", "trim", nil, "a"},
+		{"abc", "upper", nil, "ABC"},
+		{"abc", "contains", []Object{&String{Value: "b"}}, "true"},
+		{"héllo", "substring", []Object{&Integer{Value: 1}, &Integer{Value: 3}}, "él"},
+		{"%d-%d", "format", []Object{&Integer{Value: 1}, &Integer{Value: 2}}, "1-2"},
+		{"abc", "upper", []Object{&Integer{Value: 1}}, "ERROR: wrong number of arguments. got=1, want=0"},
+		{"abc", "replace", []Object{&String{Value: "b"}}, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		method, err := GetAttribute(&String{Value: tt.receiver}, tt.name)
+		if err != nil {
+			t.Fatalf("%q.%s: unexpected error: %s", tt.receiver, tt.name, err)
+		}
+		if result := method.(*Builtin).Invoke(nil, tt.args...); result.Inspect() != tt.expected {
+			t.Errorf("%q.%s: want=%s, got=%s", tt.receiver, tt.name, tt.expected, result.Inspect())
+		}
+	}
+}
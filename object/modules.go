@@ -0,0 +1,39 @@
+package object
+
+// defaultModules The modules implemented in Go. Programs import them by name, e.g.
+// import "strings" as strings, before looking for a file of that name.
+func defaultModules() map[string]*Module {
+	return map[string]*Module{
+		"strings": stringsModule(),
+		"math":    mathModule(),
+		"json":    jsonModule(),
+	}
+}
+
+// RegisterModule makes exports importable as the module name by every program whose
+// interpreter doesn't have a registry of its own, and by the registries created
+// after it. See Registry.RegisterModule.
+func RegisterModule(name string, exports map[string]Object) error {
+	return defaultRegistry.RegisterModule(name, exports)
+}
+
+// GetModule returns the module of the default registry registered as name.
+func GetModule(name string) (*Module, bool) {
+	return defaultRegistry.GetModule(name)
+}
+
+// nativeModule adapts the Go functions in fns with NewBuiltinFunc and returns a
+// module exporting them. It panics if a function can't be adapted.
+func nativeModule(name string, fns map[string]interface{}) *Module {
+	mod := &Module{Path: name, Exports: make(map[string]Object, len(fns))}
+
+	for export, fn := range fns {
+		builtin, err := NewBuiltinFunc(export, fn)
+		if err != nil {
+			panic(err)
+		}
+		mod.Exports[export] = builtin
+	}
+
+	return mod
+}
@@ -3,9 +3,11 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"strings"
 
 	"Gengo/ast"
+	"Gengo/code"
 )
 
 const (
@@ -19,16 +21,30 @@ const (
 	BOOLEAN = "BOOLEAN"
 	// ARRAY Represents an array object.
 	ARRAY = "ARRAY"
+	// HASH Represents a hash object.
+	HASH = "HASH"
 	// NULL Represents a null object.
 	NULL = "NULL"
 	// FUNCTION Represents a function.
 	FUNCTION = "FUNCTION"
 	// BUILTIN Represents a built-in function.
 	BUILTIN = "BUILTIN"
+	// COMPILED_FUNCTION Represents a function compiled to bytecode.
+	COMPILED_FUNCTION = "COMPILED_FUNCTION"
+	// CLOSURE Represents a compiled function with its free variables.
+	CLOSURE = "CLOSURE"
 	// RETURN_VALUE Represents the return value.
 	RETURN_VALUE = "RETURN_VALUE"
 	// ERROR Represents an error object.
 	ERROR = "ERROR"
+	// MODULE Represents an imported module.
+	MODULE = "MODULE"
+	// EXCEPTION Represents an error caught by a catch clause.
+	EXCEPTION = "EXCEPTION"
+	// PATTERN Represents a pattern of a match expression compiled to bytecode.
+	PATTERN = "PATTERN"
+	// RANGE Represents a range of integers.
+	RANGE = "RANGE"
 )
 
 // ObjectType The base object type.
@@ -116,6 +132,11 @@ func (rv *ReturnValue) Inspect() string {
 // Error type.
 type Error struct {
 	Message string
+	// Value is the value that was thrown, or nil for a runtime error.
+	Value Object
+	// Stack holds the functions the error was raised in and unwound from, innermost
+	// first.
+	Stack []string
 }
 
 // Type The object's type.
@@ -128,6 +149,11 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Error The message, so an error object can be returned as a Go error.
+func (e *Error) Error() string {
+	return e.Message
+}
+
 // String type.
 type String struct {
 	Value string
@@ -143,9 +169,13 @@ func (s *String) Inspect() string {
 	return s.Value
 }
 
-// Array type.
+// Array type. Arrays taken from another one, like the one rest returns, may share
+// its elements, so changing them in place changes both.
 type Array struct {
 	Elements []Object
+	// Frozen is set once the array was frozen with Freeze, after which Set, Append
+	// and the push builtin fail.
+	Frozen bool
 }
 
 // Type The object's type.
@@ -166,9 +196,14 @@ func (ao *Array) Inspect() string {
 
 // Function type.
 type Function struct {
-	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	// Name is the name the function was bound to when it was defined, if any.
+	Name       string
+	Parameters []ast.Pattern
+	// Defaults are the default values of the parameters, as in ast.FunctionLiteral.
+	Defaults []ast.Expression
+	Rest     *ast.Identifier
+	Body     *ast.BlockStatement
+	Env      *Environment
 }
 
 // Type The object's type.
@@ -179,13 +214,13 @@ func (f *Function) Type() ObjectType {
 // Inspect A string of the type.
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
-	var params []string
 
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
 	}
-	out.WriteString("fn(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString("(")
+	out.WriteString(ast.FormatParameters(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -196,9 +231,28 @@ func (f *Function) Inspect() string {
 // BuiltinFunction type.
 type BuiltinFunction func(args ...Object) Object
 
+// Runtime is the interpreter executing a builtin. Builtins that take functions as
+// arguments call them through it.
+type Runtime interface {
+	Call(fn Object, args ...Object) (Object, error)
+}
+
+// RuntimeFunction is a builtin function that needs the runtime executing it.
+type RuntimeFunction func(rt Runtime, args ...Object) Object
+
 // Builtin type.
 type Builtin struct {
 	Fn BuiltinFunction
+	// RuntimeFn is called instead of Fn if it is set.
+	RuntimeFn RuntimeFunction
+}
+
+// Invoke calls the builtin with args, on behalf of rt.
+func (b *Builtin) Invoke(rt Runtime, args ...Object) Object {
+	if b.RuntimeFn != nil {
+		return b.RuntimeFn(rt, args...)
+	}
+	return b.Fn(args...)
 }
 
 // Type The object's type.
@@ -210,3 +264,132 @@ func (b *Builtin) Type() ObjectType {
 func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
+
+// HashKey is used as the key of a hash.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by objects that can be used as hash keys.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+// HashKey The key used to store the value in a hash.
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+// HashKey The key used to store the value in a hash.
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// HashKey The key used to store the value in a hash.
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// HashPair A key and the value stored under it.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash type.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	// Frozen is set once the hash was frozen with Freeze, after which Set and
+	// Delete fail.
+	Frozen bool
+}
+
+// Type The object's type.
+func (h *Hash) Type() ObjectType {
+	return HASH
+}
+
+// Inspect A string of the type.
+func (h *Hash) Inspect() string {
+	var pairs []string
+
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// CompiledFunction A function compiled to bytecode.
+type CompiledFunction struct {
+	// Name is the name the function was bound to when it was defined, if any.
+	Name          string
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	// Signature describes the parameters for calls that don't pass exactly
+	// NumParameters arguments, or pass named ones. Without it the function takes
+	// NumParameters arguments that can't be named.
+	Signature *Signature
+}
+
+// Type The object's type.
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION
+}
+
+// Inspect A string of the type.
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure A compiled function and the free variables it closes over.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+// Type The object's type.
+func (c *Closure) Type() ObjectType {
+	return CLOSURE
+}
+
+// Inspect A string of the type, which names the function if it has a name.
+func (c *Closure) Inspect() string {
+	if c.Fn.Name != "" {
+		return fmt.Sprintf("Closure[%s]", c.Fn.Name)
+	}
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Module An imported module, whose exports are read as its attributes.
+type Module struct {
+	Path    string
+	Exports map[string]Object
+}
+
+// Type The object's type.
+func (m *Module) Type() ObjectType {
+	return MODULE
+}
+
+// Inspect A string of the type.
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("module(%s)", m.Path)
+}
+
+// Attribute The export called name.
+func (m *Module) Attribute(name string) (Object, bool) {
+	obj, ok := m.Exports[name]
+	return obj, ok
+}
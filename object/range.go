@@ -0,0 +1,125 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Range The integers from Start to Stop, Step apart, that a range expression like
+// 0..10 or 0..<10 step 2 makes. Stop is one of them if Inclusive is set and the
+// steps land on it. A range holds no elements; they are made as they are read.
+type Range struct {
+	Start, Stop, Step int64
+	Inclusive         bool
+}
+
+// NewRange Creates the range from start to stop, which must be integers, with
+// step, an integer other than zero, or nil or null for a step of 1. The range may be
+// of any length, since it holds no elements.
+func NewRange(start, stop, step Object, inclusive bool) (*Range, error) {
+	r := &Range{Step: 1, Inclusive: inclusive}
+
+	for _, bound := range []struct {
+		obj   Object
+		value *int64
+	}{{start, &r.Start}, {stop, &r.Stop}} {
+		n, ok := bound.obj.(*Integer)
+		if !ok {
+			return nil, fmt.Errorf("range bounds must be INTEGER, got %s", objectTypeOf(bound.obj))
+		}
+		*bound.value = n.Value
+	}
+
+	switch step := step.(type) {
+	case nil, *Null:
+	case *Integer:
+		if step.Value == 0 {
+			return nil, fmt.Errorf("range step must not be zero")
+		}
+		r.Step = step.Value
+	default:
+		return nil, fmt.Errorf("range step must be INTEGER, got %s", step.Type())
+	}
+
+	return r, nil
+}
+
+// Type The object's type.
+func (r *Range) Type() ObjectType {
+	return RANGE
+}
+
+// Inspect A string of the type.
+func (r *Range) Inspect() string {
+	operator := "..<"
+	if r.Inclusive {
+		operator = ".."
+	}
+
+	out := strconv.FormatInt(r.Start, 10) + operator + strconv.FormatInt(r.Stop, 10)
+	if r.Step != 1 {
+		out += " step " + strconv.FormatInt(r.Step, 10)
+	}
+	return out
+}
+
+// Len The number of integers in the range. The range of every int64 has one more
+// than a uint64 holds, so its length is math.MaxUint64.
+func (r *Range) Len() uint64 {
+	return rangeLength(r.Start, r.Stop, r.Step, r.Inclusive)
+}
+
+// Index Returns the integer at position index, or null past either end, like arrays
+// do.
+func (r *Range) Index(index Object) (Object, error) {
+	i, ok := index.(*Integer)
+	if !ok {
+		return nil, fmt.Errorf("range index must be INTEGER, got %s", objectTypeOf(index))
+	}
+	if i.Value < 0 || uint64(i.Value) >= r.Len() {
+		return nil, nil
+	}
+	return &Integer{Value: r.Start + i.Value*r.Step}, nil
+}
+
+// Iterator Yields the integers of the range in order.
+func (r *Range) Iterator() Iterator {
+	return &rangeIterator{r: r, remaining: r.Len(), next: r.Start}
+}
+
+type rangeIterator struct {
+	r         *Range
+	remaining uint64
+	next      int64
+}
+
+func (it *rangeIterator) Next() (Object, bool) {
+	if it.remaining == 0 {
+		return nil, false
+	}
+	value := it.next
+	it.remaining--
+	it.next += it.r.Step
+	return &Integer{Value: value}, true
+}
+
+// rangeLength The number of integers from start to stop, step apart, counting stop
+// if inclusive is set and the steps land on it.
+func rangeLength(start, stop, step int64, inclusive bool) uint64 {
+	var distance, stride uint64
+	switch {
+	case step > 0 && start <= stop:
+		distance, stride = uint64(stop-start), uint64(step)
+	case step < 0 && start >= stop:
+		distance, stride = uint64(start-stop), uint64(-step)
+	default:
+		return 0
+	}
+
+	length := distance / stride
+	if (inclusive || distance%stride != 0) && length < math.MaxUint64 {
+		length++
+	}
+	return length
+}
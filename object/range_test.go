@@ -0,0 +1,107 @@
+package object
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewRange(t *testing.T) {
+	tests := []struct {
+		start, stop, step Object
+		inclusive         bool
+		expected          string
+		elements          string
+	}{
+		{integer(0), integer(3), nil, true, "0..3", "[0, 1, 2, 3]"},
+		{integer(0), integer(3), nil, false, "0..<3", "[0, 1, 2]"},
+		{integer(0), integer(10), integer(3), true, "0..10 step 3", "[0, 3, 6, 9]"},
+		{integer(0), integer(9), integer(3), true, "0..9 step 3", "[0, 3, 6, 9]"},
+		{integer(0), integer(9), integer(3), false, "0..<9 step 3", "[0, 3, 6]"},
+		{integer(3), integer(0), &Null{}, true, "3..0", "[]"},
+		{integer(3), integer(0), integer(-1), true, "3..0 step -1", "[3, 2, 1, 0]"},
+		{integer(3), integer(3), nil, false, "3..<3", "[]"},
+		{integer(3), integer(3), nil, true, "3..3", "[3]"},
+		{str("a"), integer(3), nil, true, "range bounds must be INTEGER, got STRING", ""},
+		{integer(0), nil, nil, true, "range bounds must be INTEGER, got NULL", ""},
+		{integer(0), integer(3), &Float{Value: 1}, true, "range step must be INTEGER, got FLOAT", ""},
+		{integer(0), integer(3), integer(0), true, "range step must not be zero", ""},
+	}
+
+	for _, tt := range tests {
+		r, err := NewRange(tt.start, tt.stop, tt.step, tt.inclusive)
+		if err != nil {
+			if err.Error() != tt.expected {
+				t.Errorf("wrong error. want=%q, got=%q", tt.expected, err)
+			}
+			continue
+		}
+
+		if r.Inspect() != tt.expected {
+			t.Errorf("wrong range. want=%q, got=%q", tt.expected, r.Inspect())
+		}
+		elements := &Array{Elements: Collect(r)}
+		if elements.Inspect() != tt.elements {
+			t.Errorf("%s: wrong elements. want=%s, got=%s", tt.expected, tt.elements, elements.Inspect())
+		}
+		if r.Len() != uint64(len(elements.Elements)) {
+			t.Errorf("%s: wrong length. want=%d, got=%d", tt.expected, len(elements.Elements), r.Len())
+		}
+	}
+}
+
+func TestLongRanges(t *testing.T) {
+	tests := []struct {
+		r        *Range
+		length   uint64
+		elements string
+	}{
+		{&Range{Start: 0, Stop: 1 << 40, Step: 1}, 1 << 40, "ERROR: range of 1099511627776 elements exceeds the limit of 16777216"},
+		{&Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: 1, Inclusive: true}, math.MaxUint64, "ERROR: range of 18446744073709551615 elements exceeds the limit of 16777216"},
+		{&Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: math.MaxInt64, Inclusive: true}, 3, "[9223372036854775806, -1, -9223372036854775808]"},
+	}
+
+	for _, tt := range tests {
+		if tt.r.Len() != tt.length {
+			t.Errorf("%s: wrong length. want=%d, got=%d", tt.r.Inspect(), tt.length, tt.r.Len())
+		}
+		// A range only has to fit the limit once it becomes an array.
+		if result := reverseFunc(nil, tt.r); result.Inspect() != tt.elements {
+			t.Errorf("%s: wrong elements. want=%s, got=%s", tt.r.Inspect(), tt.elements, result.Inspect())
+		}
+	}
+
+	if _, err := NewRange(integer(0), integer(1<<40), nil, false); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestRangeIndex(t *testing.T) {
+	r := &Range{Start: 10, Stop: 0, Step: -2}
+
+	tests := []struct {
+		index    Object
+		expected string
+	}{
+		{integer(0), "10"},
+		{integer(4), "2"},
+		{integer(5), "null"},
+		{integer(-1), "null"},
+		{str("a"), "range index must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		result, err := r.Index(tt.index)
+		var actual string
+		switch {
+		case err != nil:
+			actual = err.Error()
+		case result == nil:
+			actual = "null"
+		default:
+			actual = result.Inspect()
+		}
+		if actual != tt.expected {
+			t.Errorf("index %s: want=%q, got=%q", tt.index.Inspect(), tt.expected, actual)
+		}
+	}
+}
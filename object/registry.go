@@ -0,0 +1,214 @@
+package object
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Registry The builtins, the modules implemented in Go and the methods of each type
+// that programs can use. The compiler refers to a builtin by its position in the
+// registry, so builtins are only ever appended. The methods of a nil Registry use the
+// default registry, which the package level functions register with.
+type Registry struct {
+	mu       sync.RWMutex
+	builtins []BuiltinDefinition
+	modules  map[string]*Module
+	methods  map[ObjectType]map[string]MethodFunction
+}
+
+var defaultRegistry = &Registry{builtins: defaultBuiltins(), modules: defaultModules(), methods: defaultMethods()}
+
+// NewRegistry Creates a registry holding the builtins, modules and methods of the
+// default registry. Registering with it changes neither the default registry nor any other.
+func NewRegistry() *Registry {
+	defaultRegistry.mu.RLock()
+	defer defaultRegistry.mu.RUnlock()
+
+	r := &Registry{
+		builtins: make([]BuiltinDefinition, len(defaultRegistry.builtins)),
+		modules:  make(map[string]*Module, len(defaultRegistry.modules)),
+		methods:  make(map[ObjectType]map[string]MethodFunction, len(defaultRegistry.methods)),
+	}
+	copy(r.builtins, defaultRegistry.builtins)
+	for name, mod := range defaultRegistry.modules {
+		r.modules[name] = mod
+	}
+	for t, methods := range defaultRegistry.methods {
+		r.methods[t] = make(map[string]MethodFunction, len(methods))
+		for name, fn := range methods {
+			r.methods[t][name] = fn
+		}
+	}
+	return r
+}
+
+// orDefault returns r, or the default registry if r is nil.
+func (r *Registry) orDefault() *Registry {
+	if r == nil {
+		return defaultRegistry
+	}
+	return r
+}
+
+// RegisterBuiltin makes fn available as name to the programs using the registry.
+// Programs compiled before the registration cannot see it. It is an error to
+// register a name twice.
+func (r *Registry) RegisterBuiltin(name string, fn BuiltinFunction) error {
+	if fn == nil {
+		return fmt.Errorf("builtin %s has no function", name)
+	}
+	return r.registerBuiltin(name, &Builtin{Fn: fn})
+}
+
+// RegisterFunc adapts fn with NewBuiltinFunc and registers it as name.
+func (r *Registry) RegisterFunc(name string, fn interface{}) error {
+	builtin, err := NewBuiltinFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	return r.registerBuiltin(name, builtin)
+}
+
+func (r *Registry) registerBuiltin(name string, builtin *Builtin) error {
+	if name == "" {
+		return fmt.Errorf("builtin name must not be empty")
+	}
+
+	r = r.orDefault()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, def := range r.builtins {
+		if def.Name == name {
+			return fmt.Errorf("builtin %s is already registered", name)
+		}
+	}
+	if len(r.builtins) >= MaxBuiltins {
+		return fmt.Errorf("too many builtins, %s exceeds the limit of %d", name, MaxBuiltins)
+	}
+
+	r.builtins = append(r.builtins, BuiltinDefinition{Name: name, Builtin: builtin})
+	return nil
+}
+
+// Builtins returns the registered builtins in registration order.
+func (r *Registry) Builtins() []BuiltinDefinition {
+	r = r.orDefault()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]BuiltinDefinition, len(r.builtins))
+	copy(definitions, r.builtins)
+	return definitions
+}
+
+// GetBuiltin returns the builtin at index, or nil if there is none.
+func (r *Registry) GetBuiltin(index int) *Builtin {
+	r = r.orDefault()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if index < 0 || index >= len(r.builtins) {
+		return nil
+	}
+	return r.builtins[index].Builtin
+}
+
+// GetBuiltinIndex returns the position of the builtin called name.
+func (r *Registry) GetBuiltinIndex(name string) (int, bool) {
+	r = r.orDefault()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i, def := range r.builtins {
+		if def.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// GetBuiltinByName returns the builtin called name, or nil if there is none.
+func (r *Registry) GetBuiltinByName(name string) *Builtin {
+	index, ok := r.GetBuiltinIndex(name)
+	if !ok {
+		return nil
+	}
+	return r.GetBuiltin(index)
+}
+
+// RegisterModule makes exports importable as the module name by the programs using
+// the registry. It is an error to register a name twice.
+func (r *Registry) RegisterModule(name string, exports map[string]Object) error {
+	if name == "" {
+		return fmt.Errorf("module name must not be empty")
+	}
+
+	r = r.orDefault()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.modules[name]; ok {
+		return fmt.Errorf("module %s is already registered", name)
+	}
+
+	mod := &Module{Path: name, Exports: make(map[string]Object, len(exports))}
+	for export, value := range exports {
+		mod.Exports[export] = value
+	}
+	r.modules[name] = mod
+	return nil
+}
+
+// GetModule returns the module registered as name.
+func (r *Registry) GetModule(name string) (*Module, bool) {
+	r = r.orDefault()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mod, ok := r.modules[name]
+	return mod, ok
+}
+
+// RegisterMethod adds the method name to the objects of type t in the programs using
+// the registry. It is an error to register a name twice for the same type.
+func (r *Registry) RegisterMethod(t ObjectType, name string, fn MethodFunction) error {
+	if name == "" {
+		return fmt.Errorf("method name must not be empty")
+	}
+	if fn == nil {
+		return fmt.Errorf("method %s.%s has no function", t, name)
+	}
+
+	r = r.orDefault()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.methods[t][name]; ok {
+		return fmt.Errorf("method %s.%s is already registered", t, name)
+	}
+	if r.methods[t] == nil {
+		r.methods[t] = make(map[string]MethodFunction)
+	}
+
+	r.methods[t][name] = fn
+	return nil
+}
+
+// GetMethod returns the method name of the objects of type t.
+func (r *Registry) GetMethod(t ObjectType, name string) (MethodFunction, bool) {
+	r = r.orDefault()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := r.methods[t][name]
+	return fn, ok
+}
+
+func (r *Registry) hasMethods(t ObjectType) bool {
+	r = r.orDefault()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.methods[t]) > 0
+}
@@ -0,0 +1,119 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+
+	"Gengo/ast"
+)
+
+// Signature The parameters of a function, which the arguments of a call are
+// arranged for.
+type Signature struct {
+	// Names are the names of the parameters, empty for those destructured with a
+	// pattern, which can't be passed by name.
+	Names []string
+	// Required is the number of parameters without a default value, which come
+	// before those with one.
+	Required int
+	// Rest is the name of the parameter the remaining arguments are passed in as an
+	// array, if any.
+	Rest string
+}
+
+// NewSignature Creates the signature of a function with the parameters, default
+// values and rest parameter of a function literal.
+func NewSignature(params []ast.Pattern, defaults []ast.Expression, rest *ast.Identifier) *Signature {
+	sig := &Signature{Names: make([]string, len(params)), Required: len(params)}
+
+	for i, param := range params {
+		if ident, ok := param.(*ast.Identifier); ok {
+			sig.Names[i] = ident.Value
+		}
+		if i < len(defaults) && defaults[i] != nil && i < sig.Required {
+			sig.Required = i
+		}
+	}
+	if rest != nil {
+		sig.Rest = rest.Value
+	}
+
+	return sig
+}
+
+// Arrange Arranges args and the named arguments for the parameters. The result has
+// an argument for each parameter, nil for those left to their default value, and
+// the array of the remaining arguments last if there is a rest parameter.
+func (s *Signature) Arrange(args []Object, named map[string]Object) ([]Object, error) {
+	numParams := len(s.Names)
+	if len(args) > numParams && s.Rest == "" || len(args) < s.Required && len(named) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments: want%s, got=%d", s.arity(), len(args))
+	}
+
+	arranged := make([]Object, numParams, numParams+1)
+	copy(arranged, args)
+
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		i := s.index(name)
+		if i < 0 {
+			return nil, fmt.Errorf("no parameter named %s", name)
+		}
+		if i < len(args) {
+			return nil, fmt.Errorf("multiple values for parameter %s", name)
+		}
+		arranged[i] = named[name]
+	}
+
+	for i := 0; i < s.Required; i++ {
+		if arranged[i] == nil {
+			return nil, fmt.Errorf("missing argument for parameter %s", s.name(i))
+		}
+	}
+
+	if s.Rest != "" {
+		rest := []Object{}
+		if len(args) > numParams {
+			rest = make([]Object, len(args)-numParams)
+			copy(rest, args[numParams:])
+		}
+		arranged = append(arranged, &Array{Elements: rest})
+	}
+
+	return arranged, nil
+}
+
+// arity Describes the number of arguments the function takes.
+func (s *Signature) arity() string {
+	switch {
+	case s.Rest != "":
+		return fmt.Sprintf(">=%d", s.Required)
+	case s.Required < len(s.Names):
+		return fmt.Sprintf("=%d to %d", s.Required, len(s.Names))
+	default:
+		return fmt.Sprintf("=%d", s.Required)
+	}
+}
+
+// name The name of the parameter at index i, or its position if it is destructured.
+func (s *Signature) name(i int) string {
+	if s.Names[i] == "" {
+		return fmt.Sprintf("%d", i+1)
+	}
+	return s.Names[i]
+}
+
+// index The index of the parameter called name, or -1 if there isn't one.
+func (s *Signature) index(name string) int {
+	for i, n := range s.Names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,42 @@
+package object
+
+import "testing"
+
+func TestArrange(t *testing.T) {
+	sig := &Signature{Names: []string{"a", "", "c"}, Required: 2, Rest: "rest"}
+	fixed := &Signature{Names: []string{"a", "b"}, Required: 2}
+
+	tests := []struct {
+		sig      *Signature
+		args     []Object
+		named    map[string]Object
+		expected string
+	}{
+		{fixed, []Object{integer(1), integer(2)}, nil, "1 2"},
+		{fixed, []Object{integer(2)}, map[string]Object{"b": integer(3)}, "2 3"},
+		{fixed, []Object{integer(1)}, nil, "wrong number of arguments: want=2, got=1"},
+		{fixed, nil, map[string]Object{"a": integer(1)}, "missing argument for parameter b"},
+		{fixed, []Object{integer(1)}, map[string]Object{"a": integer(1)}, "multiple values for parameter a"},
+		{fixed, nil, map[string]Object{"x": integer(1), "a": integer(1)}, "no parameter named x"},
+		{sig, []Object{integer(1), integer(2)}, nil, "1 2 null []"},
+		{sig, []Object{integer(1), integer(2), integer(3), integer(4)}, nil, "1 2 3 [4]"},
+		{sig, []Object{integer(1)}, nil, "wrong number of arguments: want>=2, got=1"},
+		{sig, []Object{integer(1)}, map[string]Object{"c": integer(3)}, "missing argument for parameter 2"},
+		{&Signature{Names: []string{"a", "b"}, Required: 0}, nil, nil, "null null"},
+		{&Signature{Names: []string{"a", "b"}, Required: 1}, []Object{integer(1), integer(2), integer(3)}, nil, "wrong number of arguments: want=1 to 2, got=3"},
+	}
+
+	for _, tt := range tests {
+		arranged, err := tt.sig.Arrange(tt.args, tt.named)
+		var got string
+		if err != nil {
+			got = err.Error()
+		} else {
+			// Arguments left to their default are nil, which this shows as null.
+			got = joinArguments(arranged)
+		}
+		if got != tt.expected {
+			t.Errorf("%v %v: wrong result. want=%q, got=%q", tt.args, tt.named, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringsModule The strings module. Its functions count positions and lengths in
+// characters, not bytes.
+func stringsModule() *Module {
+	return nativeModule("strings", map[string]interface{}{
+		"split":      strings.Split,
+		"join":       stringsJoin,
+		"trim":       stringsTrim,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"replace":    strings.ReplaceAll,
+		"contains":   strings.Contains,
+		"startsWith": strings.HasPrefix,
+		"endsWith":   strings.HasSuffix,
+		"indexOf":    stringsIndexOf,
+		"substring":  stringsSubstring,
+		"repeat":     stringsRepeat,
+		"format":     Format,
+		"sprintf":    Format,
+		"chars":      stringsChars,
+		"ord":        stringsOrd,
+		"chr":        stringsChr,
+	})
+}
+
+func stringsJoin(parts []string, sep string) string {
+	return strings.Join(parts, sep)
+}
+
+// stringsTrim removes the characters in cutset from both ends of s, or white space
+// without a cutset.
+func stringsTrim(s string, cutset ...string) (string, error) {
+	switch len(cutset) {
+	case 0:
+		return strings.TrimSpace(s), nil
+	case 1:
+		return strings.Trim(s, cutset[0]), nil
+	default:
+		return "", fmt.Errorf("wrong number of arguments. got=%d, want=1 or 2", len(cutset)+1)
+	}
+}
+
+// stringsIndexOf returns the position of the first sub in s, or -1 if s doesn't
+// contain it.
+func stringsIndexOf(s, sub string) int {
+	i := strings.Index(s, sub)
+	if i < 0 {
+		return -1
+	}
+	return utf8.RuneCountInString(s[:i])
+}
+
+// stringsSubstring returns the characters of s from start up to end, or up to the
+// end of s without end.
+func stringsSubstring(s string, start int, end ...int) (string, error) {
+	runes := []rune(s)
+
+	stop := len(runes)
+	switch len(end) {
+	case 0:
+	case 1:
+		stop = end[0]
+	default:
+		return "", fmt.Errorf("wrong number of arguments. got=%d, want=2 or 3", len(end)+2)
+	}
+
+	if start < 0 || stop < start || stop > len(runes) {
+		return "", fmt.Errorf("substring out of range [%d:%d] with length %d", start, stop, len(runes))
+	}
+	return string(runes[start:stop]), nil
+}
+
+func stringsRepeat(rt Runtime, s string, count int) (string, error) {
+	if count < 0 {
+		return "", fmt.Errorf("negative repeat count %d", count)
+	}
+	if count > 0 && len(s) > math.MaxInt32/count {
+		return "", fmt.Errorf("repeated string is too long")
+	}
+	if err := allocate(rt, utf8.RuneCountInString(s)*count); err != nil {
+		return "", err
+	}
+	return strings.Repeat(s, count), nil
+}
+
+func stringsChars(s string) []string {
+	chars := make([]string, 0, utf8.RuneCountInString(s))
+	for _, r := range s {
+		chars = append(chars, string(r))
+	}
+	return chars
+}
+
+// stringsOrd returns the code point of the character c.
+func stringsOrd(c string) (int, error) {
+	r, size := utf8.DecodeRuneInString(c)
+	if size == 0 || size != len(c) {
+		return 0, fmt.Errorf("expected a single character, got %q", c)
+	}
+	return int(r), nil
+}
+
+// stringsChr returns the character with the code point code.
+func stringsChr(code int64) (string, error) {
+	if code < 0 || code > utf8.MaxRune || !utf8.ValidRune(rune(code)) {
+		return "", fmt.Errorf("invalid code point %d", code)
+	}
+	return string(rune(code)), nil
+}
+
+// Format formats args according to format, like fmt.Sprintf. The verbs are %v and
+// %s for any value, %q for strings, %d, %x, %o, %b and %c for integers, %f, %e and %g
+// for numbers, %t for booleans and %% for a percent sign. Verbs take the flags, width
+// and precision fmt.Sprintf does. It is an error for the arguments not to match the
+// verbs.
+func Format(format string, args ...Object) (string, error) {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0123456789.", format[i]) >= 0 {
+			i++
+		}
+		if i == len(format) {
+			return "", fmt.Errorf("format %q ends in an incomplete verb", format)
+		}
+
+		verb := format[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+
+		if next == len(args) {
+			return "", fmt.Errorf("missing argument for %s", format[start:i+1])
+		}
+		value, err := formatArgument(verb, args[next])
+		if err != nil {
+			return "", fmt.Errorf("argument %d for %s %s", next+1, format[start:i+1], err)
+		}
+		next++
+
+		out.WriteString(fmt.Sprintf(format[start:i+1], value))
+	}
+
+	if next != len(args) {
+		return "", fmt.Errorf("too many arguments, the format uses %d of %d", next, len(args))
+	}
+	return out.String(), nil
+}
+
+// formatArgument returns the Go value verb formats arg as.
+func formatArgument(verb byte, arg Object) (interface{}, error) {
+	switch verb {
+	case 'v', 's':
+		if arg == nil {
+			return "null", nil
+		}
+		return arg.Inspect(), nil
+	case 'q':
+		if s, ok := arg.(*String); ok {
+			return s.Value, nil
+		}
+		return nil, fmt.Errorf("must be STRING, got %s", objectTypeOf(arg))
+	case 'd', 'x', 'X', 'o', 'b', 'c':
+		if i, ok := arg.(*Integer); ok {
+			return i.Value, nil
+		}
+		return nil, fmt.Errorf("must be INTEGER, got %s", objectTypeOf(arg))
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		switch arg := arg.(type) {
+		case *Float:
+			return arg.Value, nil
+		case *Integer:
+			return float64(arg.Value), nil
+		}
+		return nil, fmt.Errorf("must be FLOAT, got %s", objectTypeOf(arg))
+	case 't':
+		if b, ok := arg.(*Boolean); ok {
+			return b.Value, nil
+		}
+		return nil, fmt.Errorf("must be BOOLEAN, got %s", objectTypeOf(arg))
+	default:
+		return nil, fmt.Errorf("uses an unknown verb %s", strconv.QuoteRune(rune(verb)))
+	}
+}
@@ -0,0 +1,87 @@
+package object
+
+import "testing"
+
+func str(s string) *String { return &String{Value: s} }
+
+func integer(i int64) *Integer { return &Integer{Value: i} }
+
+func TestStringsModule(t *testing.T) {
+	tests := []struct {
+		fn       string
+		args     []Object
+		expected string
+	}{
+		{"split", []Object{str("a,b,c"), str(",")}, "[a, b, c]"},
+		{"split", []Object{str("héllo"), str("")}, "[h, é, l, l, o]"},
+		{"join", []Object{&Array{Elements: []Object{str("a"), str("b")}}, str("-")}, "a-b"},
+		{"join", []Object{&Array{Elements: []Object{str("a"), integer(1)}}, str("-")},
+			"ERROR: argument 1 to `join` element 1 must be STRING, got INTEGER"},
+		{"trim", []Object{str("  hi \n")}, "hi"},
+		{"trim", []Object{str("xxhixx"), str("x")}, "hi"},
+		{"trim", []Object{str("a"), str("b"), str("c")}, "ERROR: wrong number of arguments. got=3, want=1 or 2"},
+		{"upper", []Object{str("ñandú")}, "ÑANDÚ"},
+		{"lower", []Object{str("ÀB")}, "àb"},
+		{"replace", []Object{str("a-b-c"), str("-"), str("+")}, "a+b+c"},
+		{"contains", []Object{str("seafood"), str("foo")}, "true"},
+		{"startsWith", []Object{str("gengo"), str("gen")}, "true"},
+		{"endsWith", []Object{str("gengo"), str("gen")}, "false"},
+		{"indexOf", []Object{str("日本語"), str("語")}, "2"},
+		{"indexOf", []Object{str("abc"), str("z")}, "-1"},
+		{"substring", []Object{str("日本語です"), integer(1), integer(3)}, "本語"},
+		{"substring", []Object{str("日本語です"), integer(3)}, "です"},
+		{"substring", []Object{str("abc"), integer(2), integer(5)}, "ERROR: substring out of range [2:5] with length 3"},
+		{"repeat", []Object{str("ab"), integer(3)}, "ababab"},
+		{"repeat", []Object{str("ab"), integer(-1)}, "ERROR: negative repeat count -1"},
+		{"chars", []Object{str("añb")}, "[a, ñ, b]"},
+		{"chars", []Object{str("")}, "[]"},
+		{"ord", []Object{str("é")}, "233"},
+		{"ord", []Object{str("ab")}, `ERROR: expected a single character, got "ab"`},
+		{"chr", []Object{integer(0x1F600)}, "😀"},
+		{"chr", []Object{integer(-1)}, "ERROR: invalid code point -1"},
+		{"format", []Object{str("%s is %d years"), str("Ann"), integer(30)}, "Ann is 30 years"},
+		{"sprintf", []Object{str("%.2f|%5s|%-3d|%x|%t|%q|%v|%%"),
+			&Float{Value: 3.14159}, str("ab"), integer(7), integer(255), &Boolean{Value: true}, str("q"),
+			&Array{Elements: []Object{integer(1)}}}, `3.14|   ab|7  |ff|true|"q"|[1]|%`},
+		{"format", []Object{str("%d"), str("x")}, "ERROR: argument 1 for %d must be INTEGER, got STRING"},
+		{"format", []Object{str("%d %d"), integer(1)}, "ERROR: missing argument for %d"},
+		{"format", []Object{str("%d"), integer(1), integer(2)}, "ERROR: too many arguments, the format uses 1 of 2"},
+		{"format", []Object{str("100%")}, `ERROR: format "100%" ends in an incomplete verb`},
+	}
+
+	mod, ok := GetModule("strings")
+	if !ok {
+		t.Fatalf("strings module is not registered")
+	}
+
+	for _, tt := range tests {
+		fn, ok := mod.Attribute(tt.fn)
+		if !ok {
+			t.Fatalf("strings.%s does not exist", tt.fn)
+		}
+
+		result := fn.(*Builtin).Invoke(nil, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("strings.%s%v: wrong result. want=%q, got=%q", tt.fn, tt.args, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestRegisterModule(t *testing.T) {
+	err := RegisterModule("test/answers", map[string]Object{"answer": integer(42)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	mod, ok := GetModule("test/answers")
+	if !ok {
+		t.Fatalf("module was not registered")
+	}
+	if answer, _ := mod.Attribute("answer"); answer.Inspect() != "42" {
+		t.Errorf("wrong export. got=%v", answer)
+	}
+
+	if err := RegisterModule("strings", nil); err == nil || err.Error() != "module strings is already registered" {
+		t.Errorf("expected an error for a registered name, got=%v", err)
+	}
+}
@@ -14,10 +14,20 @@ const (
 	_ int = iota
 	// LOWEST precedence
 	LOWEST
+	// TERNARY X ? Y : Z
+	TERNARY
+	// COALESCE X ?? Y
+	COALESCE
 	// EQUALS ==
 	EQUALS
 	// LESSGREATER < or >
 	LESSGREATER
+	// PIPE X |> f()
+	PIPE
+	// COMPOSE f >> g
+	COMPOSE
+	// RANGE X..Y
+	RANGE
 	// SUM +
 	SUM
 	// PRODUCT *
@@ -47,12 +57,21 @@ var precedences = map[token.Type]int{
 	token.POW:      PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
+	token.QUESTION: TERNARY,
+	token.COALESCE: COALESCE,
+	token.OPTIONAL: INDEX,
+	token.PIPE:     PIPE,
+	token.COMPOSE:  COMPOSE,
+	token.RANGE:    RANGE,
+	token.UNTIL:    RANGE,
 }
 
 // Parser a parser
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+	l        *lexer.Lexer
+	errors   []string
+	warnings []string
 
 	curToken  token.Token
 	peekToken token.Token
@@ -76,8 +95,11 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
@@ -95,6 +117,14 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.QUESTION, p.parseTernaryExpression)
+	p.registerInfix(token.COALESCE, p.parseInfixExpression)
+	p.registerInfix(token.OPTIONAL, p.parseOptionalExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
+	p.registerInfix(token.COMPOSE, p.parseComposeExpression)
+	p.registerInfix(token.RANGE, p.parseRangeExpression)
+	p.registerInfix(token.UNTIL, p.parseRangeExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -123,6 +153,12 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Warnings returns a list of problems found in programs that parse, such as match
+// expressions that leave a boolean unmatched.
+func (p *Parser) Warnings() []string {
+	return p.warnings
+}
+
 func (p *Parser) registerPrefix(tokenType token.Type, fn prefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
@@ -170,10 +206,21 @@ func (p *Parser) peekPrecedence() int {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionDeclaration()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -230,19 +277,97 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil || !p.checkBindings(stmt.Pattern) {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.AS) {
+		return nil
+	}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	if !p.expectPeek(token.ASSIGN) {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	if p.peekTokenIs(token.FUNCTION) {
+		p.nextToken()
+		if !p.peekTokenIs(token.IDENT) {
+			p.peekError(token.IDENT)
+			return nil
+		}
+		decl, ok := p.parseFunctionDeclaration().(*ast.FunctionDeclaration)
+		if !ok {
+			return nil
+		}
+		stmt.Statement = decl
+		return stmt
+	}
+
+	if p.peekTokenIs(token.CONST) {
+		p.nextToken()
+	} else if !p.expectPeek(token.LET) {
 		return nil
 	}
 
+	let := p.parseLetStatement()
+	if let == nil {
+		return nil
+	}
+	stmt.Statement = let
+
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -306,6 +431,27 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
+func (p *Parser) parseTernaryExpression(condition ast.Expression) ast.Expression {
+	exp := &ast.TernaryExpression{Token: p.curToken, Condition: condition}
+
+	p.nextToken()
+	exp.Consequence = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	// Ternaries are right associative, so a ? b : c ? d : e is a ? b : (c ? d : e).
+	p.nextToken()
+	exp.Alternative = p.parseExpression(LOWEST)
+
+	return exp
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -350,6 +496,130 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			expression.Parameter = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		msg := fmt.Sprintf("expected catch or finally after try, got %s instead", p.peekToken.Type)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	p.checkExhaustive(expression)
+	return expression
+}
+
+// parseMatchArm parses `pattern => body` or `pattern if guard => body`. The body is
+// a block if it starts with a brace, so a hash literal has to be parenthesized.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil || !p.checkBindings(arm.Pattern) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	p.nextToken()
+	if p.curTokenIs(token.LBRACE) {
+		arm.Body = p.parseBlockStatement()
+		return arm
+	}
+
+	body := &ast.ExpressionStatement{Token: p.curToken, Expression: p.parseExpression(LOWEST)}
+	if body.Expression == nil {
+		return nil
+	}
+	arm.Body = &ast.BlockStatement{Token: body.Token, Statements: []ast.Statement{body}}
+
+	return arm
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -367,6 +637,27 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	return block
 }
 
+func (p *Parser) parseFunctionDeclaration() ast.Statement {
+	stmt := &ast.FunctionDeclaration{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	// The literal starts at the name, in place of the 'fn' token.
+	fn, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok {
+		return nil
+	}
+	fn.Token = stmt.Token
+	stmt.Function = fn
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
@@ -374,7 +665,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -385,39 +678,134 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	var identifiers []*ast.Identifier
-
+// parseFunctionParameters parses the parameters of lit. Parameters may have default
+// values, which all the following ones must have as well, and the last parameter
+// may be a rest parameter.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
-
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	var defaults []ast.Expression
+	hasDefaults := false
 
-	for p.peekTokenIs(token.COMMA) {
+	for {
 		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.peekTokenIs(token.RPAREN) {
+				p.errors = append(p.errors, fmt.Sprintf("rest parameter %s must be the last parameter", lit.Rest))
+				return false
+			}
+			break
+		}
+
+		param := p.parseFunctionParameter()
+		if param == nil {
+			return false
+		}
+		lit.Parameters = append(lit.Parameters, param)
+
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+			hasDefaults = true
+		} else if hasDefaults {
+			msg := fmt.Sprintf("parameter %s without a default value follows one with a default value", param)
+			p.errors = append(p.errors, msg)
+			return false
+		}
+		defaults = append(defaults, def)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		identifier := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, identifier)
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	if hasDefaults {
+		lit.Defaults = defaults
 	}
 
-	return identifiers
+	return p.expectPeek(token.RPAREN)
+}
+
+// parseFunctionParameter parses an identifier, or an array or hash pattern the
+// argument is destructured with.
+func (p *Parser) parseFunctionParameter() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.LBRACKET, token.LBRACE:
+		pattern := p.parsePattern()
+		if pattern == nil || !p.checkBindings(pattern) {
+			return nil
+		}
+		return pattern
+	default:
+		msg := fmt.Sprintf("expected a parameter, got %s instead", p.curToken.Type)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Arguments = p.parseCallArguments()
 	return exp
 }
 
+// parseCallArguments parses the arguments of a call. Besides expressions these are
+// spread arrays, ...xs, and named arguments, name: value, which come last.
+func (p *Parser) parseCallArguments() []ast.Expression {
+	var args []ast.Expression
+	named := make(map[string]bool)
+
+	for !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+
+		var arg ast.Expression
+		switch {
+		case p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON):
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if named[name.Value] {
+				p.errors = append(p.errors, fmt.Sprintf("argument %s is named more than once", name))
+				return nil
+			}
+			named[name.Value] = true
+
+			p.nextToken()
+			p.nextToken()
+			arg = &ast.NamedArgument{Token: name.Token, Name: name, Value: p.parseExpression(LOWEST)}
+		case len(named) > 0:
+			p.errors = append(p.errors, "positional argument follows named argument")
+			return nil
+		case p.curTokenIs(token.ELLIPSIS):
+			spread := &ast.SpreadExpression{Token: p.curToken}
+			p.nextToken()
+			spread.Value = p.parseExpression(LOWEST)
+			arg = spread
+		default:
+			arg = p.parseExpression(LOWEST)
+		}
+		args = append(args, arg)
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	return args
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -452,11 +840,30 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 	return list
 }
 
+// parseIndexExpression parses left[index], and the slices left[start:end], where
+// either bound may be left out.
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
 
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	var index ast.Expression
+	if !p.curTokenIs(token.COLON) {
+		index = p.parseExpression(LOWEST)
+
+		if !p.peekTokenIs(token.COLON) {
+			if !p.expectPeek(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+		}
+		p.nextToken()
+	}
+
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: index}
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -465,6 +872,158 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseRangeExpression parses start..end and start..<end, optionally followed by
+// step and the step. step is only special there, so it remains a valid identifier.
+func (p *Parser) parseRangeExpression(start ast.Expression) ast.Expression {
+	exp := &ast.RangeExpression{Token: p.curToken, Start: start, Inclusive: p.curTokenIs(token.RANGE)}
+
+	p.nextToken()
+	exp.End = p.parseExpression(RANGE)
+	if exp.End == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "step" {
+		p.nextToken()
+		p.nextToken()
+		exp.Step = p.parseExpression(RANGE)
+		if exp.Step == nil {
+			return nil
+		}
+	}
+
+	return exp
+}
+
+// parsePipeExpression parses value |> f(args) into the call f(value, args), and
+// value |> f, where f is not a call, into f(value). Pipelines are only syntax, so
+// the engines never see them.
+func (p *Parser) parsePipeExpression(value ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	p.nextToken()
+	right := p.parseExpression(PIPE)
+	if right == nil {
+		return nil
+	}
+
+	// Only calls written with parentheses take the value; those that pipelines and
+	// compositions are lowered to are called with it.
+	if call, ok := right.(*ast.CallExpression); ok && call.Token.Type == token.LPAREN {
+		call.Arguments = append([]ast.Expression{value}, call.Arguments...)
+		return call
+	}
+	// The same goes for a call that ends an optional chain, like obj?.f().
+	if chain, ok := right.(*ast.OptionalChain); ok {
+		if call, ok := chain.Expression.(*ast.CallExpression); ok && call.Token.Type == token.LPAREN {
+			call.Arguments = append([]ast.Expression{value}, call.Arguments...)
+			return chain
+		}
+	}
+
+	return &ast.CallExpression{Token: tok, Function: right, Arguments: []ast.Expression{value}}
+}
+
+// parseComposeExpression parses f >> g into
+//
+//	fn(f, g) { fn(...args) { g(f(...args)) } }(f, g)
+//
+// a function that passes its arguments to f and the result to g. The outer call
+// evaluates f and g once, when the function is made, like other operands.
+func (p *Parser) parseComposeExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	p.nextToken()
+	right := p.parseExpression(COMPOSE)
+	if right == nil {
+		return nil
+	}
+
+	ident := func(name string) *ast.Identifier {
+		return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+	call := func(function ast.Expression, args ...ast.Expression) *ast.CallExpression {
+		return &ast.CallExpression{Token: tok, Function: function, Arguments: args}
+	}
+	function := func(params []ast.Pattern, rest *ast.Identifier, body ast.Expression) *ast.FunctionLiteral {
+		return &ast.FunctionLiteral{
+			Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+			Parameters: params,
+			Rest:       rest,
+			Body: &ast.BlockStatement{
+				Token:      token.Token{Type: token.LBRACE, Literal: "{"},
+				Statements: []ast.Statement{&ast.ExpressionStatement{Token: tok, Expression: body}},
+			},
+		}
+	}
+
+	spread := &ast.SpreadExpression{Token: token.Token{Type: token.ELLIPSIS, Literal: "..."}, Value: ident("args")}
+	composed := function(nil, ident("args"), call(ident("g"), call(ident("f"), spread)))
+
+	return call(function([]ast.Pattern{ident("f"), ident("g")}, nil, composed), left, right)
+}
+
+// parseOptionalExpression parses the OptionalChain starting with left?.[index],
+// left?.[start:end] or left?.property, which goes on for as long as member, index,
+// slice and call expressions follow.
+func (p *Parser) parseOptionalExpression(left ast.Expression) ast.Expression {
+	chain := &ast.OptionalChain{Token: p.curToken}
+
+	exp := p.parseOptionalLink(left)
+	for exp != nil {
+		switch p.peekToken.Type {
+		case token.OPTIONAL:
+			p.nextToken()
+			exp = p.parseOptionalLink(exp)
+		case token.DOT, token.LBRACKET, token.LPAREN:
+			p.nextToken()
+			exp = p.infixParseFns[p.curToken.Type](exp)
+		default:
+			chain.Expression = exp
+			return chain
+		}
+	}
+
+	return nil
+}
+
+// parseOptionalLink parses left?.[index], left?.[start:end] and left?.property.
+func (p *Parser) parseOptionalLink(left ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	if p.peekTokenIs(token.LBRACKET) {
+		p.nextToken()
+		switch exp := p.parseIndexExpression(left).(type) {
+		case *ast.IndexExpression:
+			exp.Token, exp.Optional = tok, true
+			return exp
+		case *ast.SliceExpression:
+			exp.Token, exp.Optional = tok, true
+			return exp
+		default:
+			return nil
+		}
+	}
+
+	exp, ok := p.parseMemberExpression(left).(*ast.MemberExpression)
+	if !ok {
+		return nil
+	}
+	exp.Token, exp.Optional = tok, true
+	return exp
+}
+
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"Gengo/ast"
@@ -292,6 +293,42 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
 		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
 		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a.b.c", "((a.b).c)"},
+		{"-a.b * c", "((-(a.b)) * c)"},
+		{"a.b(c)[d]", "((a.b)(c)[d])"},
+		{"a ? b : c", "(a ? b : c)"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+		{"a ? b ? c : d : e", "(a ? (b ? c : d) : e)"},
+		{"a == b ? c + d : -e", "((a == b) ? (c + d) : (-e))"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b == c", "(a ?? (b == c))"},
+		{"a ?? b ? c : d", "((a ?? b) ? c : d)"},
+		{"a?.b?.[c] ?? d", "(((a?.b)?.[c]) ?? d)"},
+		{"-a?.b", "(-(a?.b))"},
+		{"a?.b(c)", "(a?.b)(c)"},
+		{"null ?? a", "(null ?? a)"},
+		{"xs |> f", "f(xs)"},
+		{"xs |> f(a) |> g(b, c)", "g(f(xs, a), b, c)"},
+		{"a + b |> f() == c", "(f((a + b)) == c)"},
+		{"xs |> m.f(a)", "(m.f)(xs, a)"},
+		{"xs |> f(...ys, n: 1)", "f(xs, ...ys, n: 1)"},
+		{"f >> g", "fn(f, g) { fn(...args) { g(f(...args)) } }(f, g)"},
+		{"f >> g >> h", "fn(f, g) { fn(...args) { g(f(...args)) } }(fn(f, g) { fn(...args) { g(f(...args)) } }(f, g), h)"},
+		{"xs |> f >> g", "fn(f, g) { fn(...args) { g(f(...args)) } }(f, g)(xs)"},
+		{"xs |> (ys |> f)", "f(ys)(xs)"},
+		{"0..10", "(0..10)"},
+		{"0..<n - 1", "(0..<(n - 1))"},
+		{"a..b step c * 2", "(a..b step (c * 2))"},
+		{"0..10 == r", "((0..10) == r)"},
+		{"0..n |> map(f)", "map((0..n), f)"},
+		{"let step = 2; 0..10 step step", "let step = 2;(0..10 step step)"},
+		{"xs[1:3]", "(xs[1:3])"},
+		{"xs[:n - 1]", "(xs[:(n - 1)])"},
+		{"xs[-2:]", "(xs[(-2):])"},
+		{"xs[:]", "(xs[:])"},
+		{"xs?.[1:]", "(xs?.[1:])"},
+		{"xs[c ? 1 : 2:]", "(xs[(c ? 1 : 2):])"},
+		{"xs[0..2]", "(xs[(0..2)])"},
 	}
 
 	for _, tt := range tests {
@@ -355,18 +392,44 @@ func TestParserErrors(t *testing.T) {
 	tests := []struct {
 		input string
 	}{
-		{"if (true { x }"},           // missing right paren in if statement
-		{"if true) { x }"},           // missing right paren in if statement
-		{"if (true) x }"},            // missing left bracket in if statement
-		{"if (true) { x } else x }"}, // missing left bracket in 'else'
-		{"fn a) { x }"},              // missing left paren in function literal
-		{"fn(a { x }"},               // missing right paren in function literal
-		{"fn(a)  x }"},               // missing left bracket in function literal
-		{"add(a, b"},                 // missing right paren in call expression
-		{"let 5"},                    // missing identifier after 'let'
-		{"let a 5"},                  // missing assignment after identifier
-		{"(1 + 2"},                   // missing right paren in grouped expression
-		{"9223372036854775808"},      // max int64 value + 1
+		{"if (true { x }"},            // missing right paren in if statement
+		{"if true) { x }"},            // missing right paren in if statement
+		{"if (true) x }"},             // missing left bracket in if statement
+		{"if (true) { x } else x }"},  // missing left bracket in 'else'
+		{"fn a) { x }"},               // missing left paren in function literal
+		{"fn(a { x }"},                // missing right paren in function literal
+		{"fn(a)  x }"},                // missing left bracket in function literal
+		{"add(a, b"},                  // missing right paren in call expression
+		{"let 5"},                     // missing identifier after 'let'
+		{"let a 5"},                   // missing assignment after identifier
+		{"(1 + 2"},                    // missing right paren in grouped expression
+		{"9223372036854775808"},       // max int64 value + 1
+		{"import math"},               // missing path in import
+		{`import "math.gg" m`},        // missing 'as' in import
+		{"export fn() {}"},            // export of something other than a let
+		{"try { x }"},                 // try without catch or finally
+		{"try { x } catch e { y }"},   // missing parens around the catch parameter
+		{"try { x } catch (1) { y }"}, // catch parameter that isn't an identifier
+		{"throw;"},                    // throw without a value
+		{"let [a, a] = x"},            // identifier bound twice in a pattern
+		{"let {a: } = x"},             // missing pattern for a hash key
+		{"fn(1) { x }"},               // parameter that isn't an identifier or pattern
+		{"fn([a, ...b, c]) { x }"},    // rest that isn't the last element
+		{"fn(...a, b) { x }"},         // rest parameter that isn't the last parameter
+		{"fn(a = 1, b) { x }"},        // parameter without a default after one with a default
+		{"f(a: 1, 2)"},                // positional argument after a named argument
+		{"f(a: 1, a: 2)"},             // argument named twice
+		{"fn f { x }"},                // missing parameters in function declaration
+		{"a ? b"},                     // missing alternative in ternary expression
+		{"a?.1"},                      // optional member access with a non-identifier
+		{"let null = 1"},              // null isn't an identifier
+		{"let {null: a} = x"},         // null as a hash pattern key
+		{"xs |>"},                     // missing function in pipeline
+		{"f >>"},                      // missing function in composition
+		{"0.."},                       // missing end of range
+		{"0..10 step"},                // missing step of range
+		{"xs[1:2"},                    // missing right bracket in slice
+		{"xs[1:2:3]"},                 // slice with more than two bounds
 	}
 
 	for _, tt := range tests {
@@ -459,8 +522,8 @@ func TestFunctionLiteralParsing(t *testing.T) {
 		t.Fatalf("function literal parameters wrong. want 2, got=%d\n", len(function.Parameters))
 	}
 
-	testLiteralExpression(t, function.Parameters[0], "x")
-	testLiteralExpression(t, function.Parameters[1], "y")
+	testLiteralExpression(t, function.Parameters[0].(*ast.Identifier), "x")
+	testLiteralExpression(t, function.Parameters[1].(*ast.Identifier), "y")
 
 	if len(function.Body.Statements) != 1 {
 		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n", len(function.Body.Statements))
@@ -498,7 +561,7 @@ func TestFunctionParameterParsing(t *testing.T) {
 		}
 
 		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
+			testLiteralExpression(t, function.Parameters[i].(*ast.Identifier), ident)
 		}
 	}
 }
@@ -538,6 +601,91 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestFunctionDeclaration(t *testing.T) {
+	input := "fn add(a, b = 1) { a + b }; add(1)"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	decl, ok := program.Statements[0].(*ast.FunctionDeclaration)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.FunctionDeclaration. got=%T", program.Statements[0])
+	}
+	if decl.Name.Value != "add" {
+		t.Errorf("wrong name. want=add, got=%s", decl.Name.Value)
+	}
+	if len(decl.Function.Parameters) != 2 {
+		t.Errorf("wrong number of parameters. want=2, got=%d", len(decl.Function.Parameters))
+	}
+	if decl.String() != "fn add(a, b = 1) { (a + b) }" {
+		t.Errorf("wrong string. got=%q", decl.String())
+	}
+
+	// A function literal is still an expression.
+	p = New(lexer.New("fn(x) { x }(1)"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if _, ok := program.Statements[0].(*ast.ExpressionStatement); !ok {
+		t.Errorf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+}
+
+func TestFunctionParameterDefaultsAndRest(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 2) { a }", "fn(a, b = 2) { a }"},
+		{"fn(a = 1, b = a * 2) { a }", "fn(a = 1, b = (a * 2)) { a }"},
+		{"fn(a, ...rest) { a }", "fn(a, ...rest) { a }"},
+		{"fn(...rest) { rest }", "fn(...rest) { rest }"},
+		{"fn([a, b] = [1, 2], ...rest) { a }", "fn([a, b] = [1, 2], ...rest) { a }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestCallArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"f(...xs)", []string{"*ast.SpreadExpression ...xs"}},
+		{"f(1, ...xs, 2)", []string{"*ast.IntegerLiteral 1", "*ast.SpreadExpression ...xs", "*ast.IntegerLiteral 2"}},
+		{"f(1, b: 2, c: x + 1)", []string{"*ast.IntegerLiteral 1", "*ast.NamedArgument b: 2", "*ast.NamedArgument c: (x + 1)"}},
+		{"f(...a, b: 1,)", []string{"*ast.SpreadExpression ...a", "*ast.NamedArgument b: 1"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+		if len(call.Arguments) != len(tt.expected) {
+			t.Fatalf("wrong number of arguments. want=%d, got=%d", len(tt.expected), len(call.Arguments))
+		}
+		for i, arg := range call.Arguments {
+			if got := fmt.Sprintf("%T %s", arg, arg); got != tt.expected[i] {
+				t.Errorf("argument %d: want=%q, got=%q", i, tt.expected[i], got)
+			}
+		}
+	}
+}
+
 func TestCallExpressionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -641,6 +789,426 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingMemberExpressions(t *testing.T) {
+	input := "row.name"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	memberExp, ok := stmt.Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("exp not ast.MemberExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, memberExp.Object, "row") {
+		return
+	}
+
+	if !testIdentifier(t, memberExp.Property, "name") {
+		return
+	}
+}
+
+func TestParsingOptionalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		optional bool
+	}{
+		{"a?.[0]", true},
+		{"a?.b", true},
+		{"a[0]", false},
+		{"a.b", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp := stmt.Expression
+		if chain, ok := exp.(*ast.OptionalChain); ok {
+			exp = chain.Expression
+		} else if tt.optional {
+			t.Fatalf("%s: exp not ast.OptionalChain. got=%T", tt.input, exp)
+		}
+
+		var optional bool
+		switch exp := exp.(type) {
+		case *ast.IndexExpression:
+			optional = exp.Optional
+		case *ast.MemberExpression:
+			optional = exp.Optional
+		default:
+			t.Fatalf("exp not ast.IndexExpression or ast.MemberExpression. got=%T", exp)
+		}
+
+		if optional != tt.optional {
+			t.Errorf("%s: wrong Optional. want=%t, got=%t", tt.input, tt.optional, optional)
+		}
+	}
+}
+
+func TestParsingOptionalChains(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// The chain takes in the member, index and call expressions after ?.
+		{"a?.b.c(d)[0] + 1", "((((a?.b).c)(d)[0]) + 1)"},
+		{"a?.b?.c", "((a?.b)?.c)"},
+		{"a.b?.c()", "((a.b)?.c)()"},
+		{"-a?.b", "(-(a?.b))"},
+		{"x |> a?.f(1)", "(a?.f)(x, 1)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%s: want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestImportStatements(t *testing.T) {
+	input := `import "lib/math.gg" as math;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Path.Value != "lib/math.gg" {
+		t.Errorf("stmt.Path.Value not %q. got=%q", "lib/math.gg", stmt.Path.Value)
+	}
+	if !testIdentifier(t, stmt.Name, "math") {
+		return
+	}
+	if stmt.String() != input {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", input, stmt.String())
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		names []string
+	}{
+		{"const x = 5;", []string{"x"}},
+		{"const [a, ...b] = xs;", []string{"a", "b"}},
+		{"export const answer = 42;", []string{"answer"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if export, isExport := program.Statements[0].(*ast.ExportStatement); isExport {
+			stmt, ok = export.Statement.(*ast.LetStatement)
+		}
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+		if !stmt.IsConst() {
+			t.Errorf("statement is not const: %s", stmt)
+		}
+		if program.String() != tt.input {
+			t.Errorf("wrong statement. want=%q, got=%q", tt.input, program.String())
+		}
+
+		var names []string
+		for _, ident := range stmt.Names() {
+			names = append(names, ident.Value)
+		}
+		if strings.Join(names, " ") != strings.Join(tt.names, " ") {
+			t.Errorf("wrong names. want=%v, got=%v", tt.names, names)
+		}
+	}
+}
+
+func TestExportStatements(t *testing.T) {
+	input := "export let answer = 42;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ExportStatement. got=%T", program.Statements[0])
+	}
+	let, ok := stmt.Statement.(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("stmt.Statement not *ast.LetStatement. got=%T", stmt.Statement)
+	}
+	if !testLetStatement(t, let, "answer") {
+		return
+	}
+	if !testLiteralExpression(t, let.Value, 42) {
+		return
+	}
+	if stmt.String() != input {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", input, stmt.String())
+	}
+}
+
+func TestExportFunctionDeclarations(t *testing.T) {
+	input := "export fn add(a, b) { (a + b) }"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ExportStatement. got=%T", program.Statements[0])
+	}
+	decl, ok := stmt.Statement.(*ast.FunctionDeclaration)
+	if !ok {
+		t.Fatalf("stmt.Statement not *ast.FunctionDeclaration. got=%T", stmt.Statement)
+	}
+	if decl.Name.Value != "add" {
+		t.Errorf("decl.Name.Value not %q. got=%q", "add", decl.Name.Value)
+	}
+	if len(stmt.Names()) != 1 || stmt.Names()[0].Value != "add" {
+		t.Errorf("wrong names. got=%v", stmt.Names())
+	}
+	if stmt.String() != input {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", input, stmt.String())
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		names    []string
+	}{
+		{"let [a, b, ...rest] = arr;", "let [a, b, ...rest] = arr;", []string{"a", "b", "rest"}},
+		{"let {name, age} = person;", "let {name, age} = person;", []string{"name", "age"}},
+		{`let {"x": [x, _], 1: y} = p;`, `let {"x": [x, _], 1: y} = p;`, []string{"x", "y"}},
+		{"let x = 1;", "let x = 1;", []string{"x"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("wrong statement. want=%q, got=%q", tt.expected, stmt.String())
+		}
+
+		var names []string
+		for _, name := range stmt.Names() {
+			names = append(names, name.Value)
+		}
+		if strings.Join(names, " ") != strings.Join(tt.names, " ") {
+			t.Errorf("wrong names. want=%v, got=%v", tt.names, names)
+		}
+	}
+
+	input := "fn([a, ...b], {c}, d) { a }"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	expected := []string{"[a, ...b]", "{c}", "d"}
+	if len(function.Parameters) != len(expected) {
+		t.Fatalf("wrong number of parameters. want=%d, got=%d", len(expected), len(function.Parameters))
+	}
+	for i, param := range function.Parameters {
+		if param.String() != expected[i] {
+			t.Errorf("parameter %d: want=%q, got=%q", i, expected[i], param.String())
+		}
+	}
+}
+
+func TestThrowStatements(t *testing.T) {
+	input := "throw err;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Value, "err") {
+		return
+	}
+	if stmt.String() != input {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", input, stmt.String())
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input     string
+		parameter string
+		catch     bool
+		finally   bool
+		expected  string
+	}{
+		{"try { x } catch (e) { e }", "e", true, false, "try { x } catch (e) { e }"},
+		{"try { x } catch { y }", "", true, false, "try { x } catch { y }"},
+		{"try { x } finally { y }", "", false, true, "try { x } finally { y }"},
+		{"try { x } catch (e) { e } finally { y }", "e", true, true, "try { x } catch (e) { e } finally { y }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression not *ast.TryExpression. got=%T", stmt.Expression)
+		}
+
+		if tt.parameter == "" && exp.Parameter != nil {
+			t.Errorf("exp.Parameter not nil. got=%s", exp.Parameter)
+		}
+		if tt.parameter != "" && !testIdentifier(t, exp.Parameter, tt.parameter) {
+			return
+		}
+		if (exp.Catch != nil) != tt.catch {
+			t.Errorf("exp.Catch wrong. want set=%t, got=%v", tt.catch, exp.Catch)
+		}
+		if (exp.Finally != nil) != tt.finally {
+			t.Errorf("exp.Finally wrong. want set=%t, got=%v", tt.finally, exp.Finally)
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("exp.String() wrong. want=%q, got=%q", tt.expected, exp.String())
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (x) {
+		0 => "zero",
+		-1 => "minus one",
+		[first, ...rest] if first > 0 => first,
+		{"name": name, "tags": [_, tag]} => { let n = name; n + tag },
+		[] => "empty",
+		_ => x,
+	}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.MatchExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Value, "x") {
+		return
+	}
+
+	expected := []struct {
+		pattern  string
+		guard    string
+		bindings string
+		body     string
+	}{
+		{"0", "", "", `zero`},
+		{"-1", "", "", "minus one"},
+		{"[first, ...rest]", "(first > 0)", "first rest", "first"},
+		{`{name, "tags": [_, tag]}`, "", "name tag", "let n = name;(n + tag)"},
+		{"[]", "", "", "empty"},
+		{"_", "", "", "x"},
+	}
+	if len(exp.Arms) != len(expected) {
+		t.Fatalf("exp.Arms does not contain %d arms. got=%d", len(expected), len(exp.Arms))
+	}
+
+	for i, tt := range expected {
+		arm := exp.Arms[i]
+		if arm.Pattern.String() != tt.pattern {
+			t.Errorf("arms[%d]: wrong pattern. want=%q, got=%q", i, tt.pattern, arm.Pattern.String())
+		}
+		guard := ""
+		if arm.Guard != nil {
+			guard = arm.Guard.String()
+		}
+		if guard != tt.guard {
+			t.Errorf("arms[%d]: wrong guard. want=%q, got=%q", i, tt.guard, guard)
+		}
+		var bindings []string
+		for _, ident := range ast.Bindings(arm.Pattern) {
+			bindings = append(bindings, ident.Value)
+		}
+		if strings.Join(bindings, " ") != tt.bindings {
+			t.Errorf("arms[%d]: wrong bindings. want=%q, got=%q", i, tt.bindings, bindings)
+		}
+		if arm.Body.String() != tt.body {
+			t.Errorf("arms[%d]: wrong body. want=%q, got=%q", i, tt.body, arm.Body.String())
+		}
+	}
+
+	if len(p.Warnings()) != 0 {
+		t.Errorf("unexpected warnings: %v", p.Warnings())
+	}
+}
+
+func TestMatchExhaustivenessWarnings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"match (b) { true => 1, false => 0 }", nil},
+		{"match (b) { true => 1 }", []string{"match (b) is not exhaustive: false is not matched"}},
+		{"match (b) { true => 1, false if x => 0 }", []string{"match (b) is not exhaustive: false is not matched"}},
+		{"match (b) { true => 1, _ => 0 }", nil},
+		{"match (b) { true => 1, 2 => 0 }", nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if strings.Join(p.Warnings(), "; ") != strings.Join(tt.expected, "; ") {
+			t.Errorf("%q: wrong warnings. want=%v, got=%v", tt.input, tt.expected, p.Warnings())
+		}
+	}
+}
+
 func TestParsingHashLiteralStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"fmt"
+
+	"Gengo/ast"
+	"Gengo/token"
+)
+
+// parsePattern parses the pattern starting at the current token.
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	default:
+		literal := p.parsePatternLiteral()
+		if literal == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Token: p.curToken, Value: literal}
+	}
+}
+
+// parsePatternLiteral parses the literal of a literal pattern or of a key of a hash
+// pattern. Numbers may be negative.
+func (p *Parser) parsePatternLiteral() ast.Expression {
+	switch p.curToken.Type {
+	case token.INT:
+		return p.parseIntegerLiteral()
+	case token.FLOAT:
+		return p.parseFloatLiteral()
+	case token.STRING:
+		return p.parseStringLiteral()
+	case token.TRUE, token.FALSE:
+		return p.parseBoolean()
+	case token.NULL:
+		return p.parseNullLiteral()
+	case token.MINUS:
+		if !p.peekTokenIs(token.INT) && !p.peekTokenIs(token.FLOAT) {
+			p.patternError(p.peekToken)
+			return nil
+		}
+		p.nextToken()
+		p.curToken.Literal = "-" + p.curToken.Literal
+		return p.parsePatternLiteral()
+	default:
+		p.patternError(p.curToken)
+		return nil
+	}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = p.parsePattern()
+			// The rest is always the last element.
+			break
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		// {name} is short for {"name": name}.
+		if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE)) {
+			ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			key := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			pattern.Pairs = append(pattern.Pairs, ast.HashPatternPair{Key: key, Value: ident})
+
+			if !p.peekTokenIs(token.RBRACE) {
+				p.nextToken()
+			}
+			continue
+		}
+
+		key := p.parsePatternLiteral()
+		if key == nil {
+			return nil
+		}
+		switch key.(type) {
+		case *ast.FloatLiteral, *ast.NullLiteral:
+			p.errors = append(p.errors, fmt.Sprintf("unusable as hash key: %s", key))
+			return nil
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parsePattern()
+		if value == nil {
+			return nil
+		}
+		pattern.Pairs = append(pattern.Pairs, ast.HashPatternPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
+// checkBindings reports an error if pattern binds an identifier more than once.
+func (p *Parser) checkBindings(pattern ast.Pattern) bool {
+	seen := make(map[string]bool)
+	for _, ident := range ast.Bindings(pattern) {
+		if seen[ident.Value] {
+			msg := fmt.Sprintf("%s is bound more than once in pattern %s", ident.Value, pattern)
+			p.errors = append(p.errors, msg)
+			return false
+		}
+		seen[ident.Value] = true
+	}
+	return true
+}
+
+func (p *Parser) patternError(t token.Token) {
+	msg := fmt.Sprintf("expected a pattern, got %s instead", t.Type)
+	p.errors = append(p.errors, msg)
+}
+
+// checkExhaustive warns about a match expression whose arms match booleans, if
+// true or false is not matched.
+func (p *Parser) checkExhaustive(me *ast.MatchExpression) {
+	matched := make(map[bool]bool)
+
+	for _, arm := range me.Arms {
+		switch pattern := arm.Pattern.(type) {
+		case *ast.Identifier, *ast.WildcardPattern:
+			if arm.Guard == nil {
+				return
+			}
+		case *ast.LiteralPattern:
+			boolean, ok := pattern.Value.(*ast.Boolean)
+			if !ok {
+				return
+			}
+			if arm.Guard == nil {
+				matched[boolean.Value] = true
+			}
+		default:
+			return
+		}
+	}
+
+	for _, value := range []bool{true, false} {
+		if !matched[value] {
+			msg := fmt.Sprintf("match (%s) is not exhaustive: %t is not matched", me.Value, value)
+			p.warnings = append(p.warnings, msg)
+		}
+	}
+}
@@ -2,102 +2,75 @@ package repl
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
-	"Gengo/compiler"
-	"Gengo/evaluator"
-	"Gengo/lexer"
-	"Gengo/object"
-	"Gengo/parser"
-	"Gengo/vm"
+	"Gengo/gengo"
 )
 
 const prompt = ">> "
 
 // StartVM a REPL with and use the VM.
 func StartVM(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	start(in, out, gengo.New(gengo.VM))
+}
+
+// StartEval the REPL
+//
+//goland:noinspection GoUnusedExportedFunction
+func StartEval(in io.Reader, out io.Writer) {
+	start(in, out, gengo.New(gengo.TreeWalker))
+}
 
-	var constants []object.Object
-	globals := make([]object.Object, vm.GlobalsSize)
-	symbolTable := compiler.NewSymbolTable()
+func start(in io.Reader, out io.Writer, interpreter *gengo.Interpreter) {
+	// Lines and the input builtins share one reader, so neither reads ahead of the other.
+	reader := bufio.NewReader(in)
+	interpreter.SetOutput(out)
+	interpreter.SetInput(reader)
 
 	for {
 		fmt.Print(prompt)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
-		}
-
-		comp := compiler.NewWithState(symbolTable, constants)
-		err := comp.Compile(program)
+		line = strings.TrimRight(line, "\r\n")
+		program, err := interpreter.Compile(line)
 		if err != nil {
-			_, _ = fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
+			printError(out, err)
 			continue
 		}
+		for _, warning := range program.Warnings() {
+			_, _ = fmt.Fprintf(out, "warning: %s\n", warning)
+		}
 
-		code := comp.Bytecode()
-		constants = code.Constants
-
-		machine := vm.NewWithGlobalsStore(code, globals)
-		err = machine.Run()
+		result, err := interpreter.Run(program)
 		if err != nil {
-			_, _ = fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
+			printError(out, err)
 			continue
 		}
 
-		lastPopped := machine.LastPoppedStackElem()
-		_, _ = io.WriteString(out, lastPopped.Inspect())
+		_, _ = io.WriteString(out, result.Inspect())
 		_, _ = io.WriteString(out, "\n")
 	}
 }
 
-// StartEval the REPL
-//
-//goland:noinspection GoUnusedExportedFunction
-func StartEval(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
-
-	for {
-		fmt.Print(prompt)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
-		}
-
-		line := scanner.Text()
-		l := lexer.New(line)
-
-		/*l2 := lexer.New(line)
-		for tok := l2.NextToken(); tok.Type != token.EOF; tok = l2.NextToken() {
-			_, _ = io.WriteString(out, fmt.Sprintf("%+v\n", tok))
-		}
-		_, _ = io.WriteString(out, "\n")*/
-
-		p := parser.New(l)
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
-		}
-
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			_, _ = io.WriteString(out, evaluated.Inspect())
-			_, _ = io.WriteString(out, "\n")
-		}
+func printError(out io.Writer, err error) {
+	var (
+		parseErr   *gengo.ParseError
+		compileErr *gengo.CompileError
+	)
+
+	switch {
+	case errors.As(err, &parseErr):
+		printParserErrors(out, parseErr.Errors)
+	case errors.As(err, &compileErr):
+		_, _ = fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", compileErr.Err)
+	default:
+		_, _ = fmt.Fprintf(out, "Woops! Execution failed:\n %s\n", err)
 	}
 }
 
@@ -53,6 +53,26 @@ const (
 	SEMICOLON = ";"
 	// COLON A token used for hash literals.
 	COLON = ":"
+	// DOT The token for member access.
+	DOT = "."
+	// ELLIPSIS The token for the rest of an array pattern.
+	ELLIPSIS = "..."
+	// ARROW The token between a pattern and its result in a match expression.
+	ARROW = "=>"
+	// QUESTION The token between the condition and the results of a ternary.
+	QUESTION = "?"
+	// COALESCE The token for the null-coalescing operator.
+	COALESCE = "??"
+	// OPTIONAL The token for optional chaining, which reads nothing from null.
+	OPTIONAL = "?."
+	// PIPE The token for passing a value to a function as its first argument.
+	PIPE = "|>"
+	// COMPOSE The token for composing two functions.
+	COMPOSE = ">>"
+	// RANGE The token for a range that includes its end.
+	RANGE = ".."
+	// UNTIL The token for a range that stops before its end.
+	UNTIL = "..<"
 
 	// LPAREN The token for an opening parenthesis.
 	LPAREN = "("
@@ -83,16 +103,46 @@ const (
 	ELSE = "ELSE"
 	// RETURN The token for the return of a function.
 	RETURN = "RETURN"
+	// IMPORT The token for importing a module.
+	IMPORT = "IMPORT"
+	// EXPORT The token for exporting a binding from a module.
+	EXPORT = "EXPORT"
+	// AS The token naming an imported module.
+	AS = "AS"
+	// THROW The token for throwing a value.
+	THROW = "THROW"
+	// TRY The token for a "try" expression.
+	TRY = "TRY"
+	// CATCH The token for the "catch" clause of a try expression.
+	CATCH = "CATCH"
+	// FINALLY The token for the "finally" clause of a try expression.
+	FINALLY = "FINALLY"
+	// MATCH The token for a "match" expression.
+	MATCH = "MATCH"
+	// CONST The token for binding an identifier that can't be bound again.
+	CONST = "CONST"
+	// NULL The token for the null value.
+	NULL = "NULL"
 )
 
 var keywords = map[string]Type{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"import":  IMPORT,
+	"export":  EXPORT,
+	"as":      AS,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"match":   MATCH,
+	"const":   CONST,
+	"null":    NULL,
 }
 
 // LookupIdent Convert a string to a TokenType
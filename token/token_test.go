@@ -10,12 +10,22 @@ func TestLookupIdent(t *testing.T) {
 		expected Type
 	}{
 		{input: "let", expected: LET},
+		{input: "const", expected: CONST},
 		{input: "fn", expected: FUNCTION},
 		{input: "true", expected: TRUE},
 		{input: "if", expected: IF},
 		{input: "false", expected: FALSE},
 		{input: "else", expected: ELSE},
 		{input: "return", expected: RETURN},
+		{input: "import", expected: IMPORT},
+		{input: "export", expected: EXPORT},
+		{input: "as", expected: AS},
+		{input: "throw", expected: THROW},
+		{input: "try", expected: TRY},
+		{input: "catch", expected: CATCH},
+		{input: "finally", expected: FINALLY},
+		{input: "match", expected: MATCH},
+		{input: "null", expected: NULL},
 		{input: "fooBar", expected: IDENT},
 	}
 
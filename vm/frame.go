@@ -0,0 +1,33 @@
+package vm
+
+import (
+	"Gengo/code"
+	"Gengo/object"
+)
+
+// Frame holds the execution state of a single function call.
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{
+		cl:          cl,
+		ip:          -1,
+		basePointer: basePointer,
+	}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// handler is a try expression being executed. An error unwinds the frames above
+// framesIndex and the stack above sp, and execution resumes at ip.
+type handler struct {
+	ip          int
+	sp          int
+	framesIndex int
+}
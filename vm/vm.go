@@ -1,23 +1,58 @@
 package vm
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"time"
 
+	"Gengo/ast"
 	"Gengo/code"
 	"Gengo/compiler"
 	"Gengo/object"
 )
 
+// StackSize is the initial size of the stack, which grows as calls need it. The
+// call depth limit bounds it.
 const StackSize = 2048
 const GlobalsSize = 65536
 
+// initialFrames is the initial number of frames, which grows like the stack.
+const initialFrames = 64
+
 type VM struct {
-	globals      []object.Object
-	constants    []object.Object
-	instructions code.Instructions
+	globals   []object.Object
+	constants []object.Object
+	// globalNames holds the names of the globals by index, for errors.
+	globalNames []string
 
 	stack []object.Object
 	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	// handlers is the exception table: the try expressions being executed,
+	// innermost last.
+	handlers []handler
+
+	limits      object.Limits
+	executed    int
+	allocations int
+
+	ctx  context.Context
+	done <-chan struct{}
+
+	// err is a limit or cancel error that happened in a call made by a builtin. It
+	// aborts the execution once the builtin returns.
+	err error
+
+	// io is where builtins print to and read from.
+	io *object.IO
+	// random is the source of random numbers of the math module.
+	random *object.Random
+	// registry holds the builtins the compiled program refers to.
+	registry *object.Registry
 }
 
 var Null = &object.Null{}
@@ -25,13 +60,23 @@ var True = &object.Boolean{Value: true}
 var False = &object.Boolean{Value: false}
 
 func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, initialFrames)
+	frames[0] = mainFrame
+
 	return &VM{
-		globals:      make([]object.Object, GlobalsSize),
-		constants:    bytecode.Constants,
-		instructions: bytecode.Instructions,
+		globals:     make([]object.Object, GlobalsSize),
+		constants:   bytecode.Constants,
+		globalNames: bytecode.Globals,
 
 		stack: make([]object.Object, StackSize),
 		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
 	}
 }
 
@@ -41,44 +86,320 @@ func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
 	return vm
 }
 
+// Globals returns the globals store. It starts out as the one the VM was created
+// with, but is replaced by a larger one if a program sets a global past its end.
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// global returns the global with the given index, or nil if it was never set.
+func (vm *VM) global(index int) object.Object {
+	if index >= len(vm.globals) {
+		return nil
+	}
+	return vm.globals[index]
+}
+
+// pushGlobal pushes the global with the given index. Like in the evaluator, using
+// a global before it is set, as a function can do with a global defined by a let
+// statement after it, is an error.
+func (vm *VM) pushGlobal(index int) error {
+	value := vm.global(index)
+	if value == nil {
+		name := fmt.Sprintf("global %d", index)
+		if index < len(vm.globalNames) && vm.globalNames[index] != "" {
+			name = vm.globalNames[index]
+		}
+		return fmt.Errorf("identifier not found: %s", name)
+	}
+	return vm.push(value)
+}
+
+// setGlobal sets the global with the given index, growing the globals store if it
+// is too small to hold it.
+func (vm *VM) setGlobal(index int, value object.Object) {
+	if index >= len(vm.globals) {
+		size := 2 * len(vm.globals)
+		if size <= index {
+			size = index + 1
+		}
+		globals := make([]object.Object, size)
+		copy(globals, vm.globals)
+		vm.globals = globals
+	}
+	vm.globals[index] = value
+}
+
+// SetLimits sets the limits enforced by Run. A zero field means no limit.
+func (vm *VM) SetLimits(limits object.Limits) {
+	vm.limits = limits
+}
+
+// SetIO sets where builtins print to and read from. Without it they use the
+// standard output and input of the process.
+func (vm *VM) SetIO(io *object.IO) {
+	vm.io = io
+}
+
+// SetRandom sets the source of random numbers of the math module. Without it the VM
+// makes one of its own when it is first needed.
+func (vm *VM) SetRandom(random *object.Random) {
+	vm.random = random
+}
+
+// SetRegistry sets the registry of the builtins, which must be the one the program
+// was compiled with. Without it the VM uses the default registry.
+func (vm *VM) SetRegistry(registry *object.Registry) {
+	vm.registry = registry
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if limit := vm.limits.CallDepth(); vm.framesIndex > limit {
+		return &object.CallDepthError{Limit: limit}
+	}
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, make([]*Frame, len(vm.frames))...)
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
 func (vm *VM) Run() error {
-	for ip := 0; ip < len(vm.instructions); ip++ {
-		op := code.Opcode(vm.instructions[ip])
+	return vm.RunContext(context.Background())
+}
+
+// RunContext is like Run but aborts with a *object.CancelError once ctx is done.
+// Cancellation is checked before the first instruction, at every backward jump and
+// at every call, so the VM always stops between two instructions and every global
+// holds either its old or its new value.
+func (vm *VM) RunContext(ctx context.Context) error {
+	vm.executed, vm.allocations, vm.err = 0, 0, nil
+	vm.ctx, vm.done = ctx, ctx.Done()
+	vm.handlers = vm.handlers[:0]
+
+	if err := vm.checkCanceled(); err != nil {
+		return err
+	}
+
+	return vm.run(0)
+}
+
+// run executes instructions until the frame count drops to stop, or the main
+// function ends. An error in one of the frames above stop resumes execution at the
+// innermost try expression in them that catches it.
+func (vm *VM) run(stop int) error {
+	for {
+		err := vm.execute(stop)
+		if err == nil || object.IsAbort(err) {
+			return err
+		}
+
+		thrown := object.ToError(err)
+		if !vm.unwind(thrown, stop) {
+			return thrown
+		}
+	}
+}
+
+// unwind pops the frames and the stack down to the innermost handler installed in
+// the frames above stop, and pushes the exception for err for its catch code. The
+// frames popped are added to the stack of err. It reports false, leaving the VM as
+// it is, if none of the frames handles err, and adds all of them to the stack.
+func (vm *VM) unwind(err *object.Error, stop int) bool {
+	n := len(vm.handlers)
+	if n == 0 || vm.handlers[n-1].framesIndex <= stop {
+		// The main function isn't named in the stack.
+		for i := vm.framesIndex - 1; i >= stop && i > 0; i-- {
+			err.Stack = append(err.Stack, object.FrameName(vm.frames[i].cl.Fn.Name))
+		}
+		return false
+	}
+
+	h := vm.handlers[n-1]
+	vm.handlers = vm.handlers[:n-1]
+	for vm.framesIndex > h.framesIndex {
+		err.Stack = append(err.Stack, object.FrameName(vm.currentFrame().cl.Fn.Name))
+		vm.popFrame()
+	}
+
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.ip - 1
+	return vm.push(object.Catch(err)) == nil
+}
+
+// leaveFrame removes the handlers installed in the current frame, which is
+// returning.
+func (vm *VM) leaveFrame() {
+	for n := len(vm.handlers); n > 0 && vm.handlers[n-1].framesIndex >= vm.framesIndex; n-- {
+		vm.handlers = vm.handlers[:n-1]
+	}
+}
+
+// execute runs instructions like run, returning the first error.
+func (vm *VM) execute(stop int) error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.framesIndex > stop && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		vm.executed++
+		if vm.limits.MaxInstructions > 0 && vm.executed > vm.limits.MaxInstructions {
+			return &object.InstructionLimitError{Limit: vm.limits.MaxInstructions}
+		}
 
 		switch op {
 		case code.OpSetGlobal:
-			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
-			ip += 2
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.setGlobal(int(globalIndex), vm.pop())
+		case code.OpSetGlobalWide:
+			globalIndex := code.ReadUint32(ins[ip+1:])
+			vm.currentFrame().ip += 4
 
-			vm.globals[globalIndex] = vm.pop()
+			vm.setGlobal(int(globalIndex), vm.pop())
 		case code.OpGetGlobal:
-			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
-			ip += 2
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err := vm.pushGlobal(int(globalIndex))
+			if err != nil {
+				return err
+			}
+		case code.OpGetGlobalWide:
+			globalIndex := code.ReadUint32(ins[ip+1:])
+			vm.currentFrame().ip += 4
+
+			err := vm.pushGlobal(int(globalIndex))
+			if err != nil {
+				return err
+			}
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
 
-			err := vm.push(vm.globals[globalIndex])
+			frame := vm.currentFrame()
+			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+			if err != nil {
+				return err
+			}
+		case code.OpMissing:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			err := vm.push(nativeBoolToBooleanObject(vm.stack[frame.basePointer+int(localIndex)] == nil))
+			if err != nil {
+				return err
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err := vm.push(vm.registry.GetBuiltin(int(builtinIndex)))
+			if err != nil {
+				return err
+			}
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpPatchFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			value := vm.pop()
+			closure := vm.pop().(*object.Closure)
+			closure.Free[freeIndex] = value
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure)
 			if err != nil {
 				return err
 			}
 		case code.OpConstant:
-			constIndex := code.ReadUint16(vm.instructions[ip+1:])
-			ip += 2
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
 
 			err := vm.push(vm.constants[constIndex])
 			if err != nil {
 				return err
 			}
+		case code.OpConstantWide:
+			constIndex := code.ReadUint32(ins[ip+1:])
+			vm.currentFrame().ip += 4
+
+			err := vm.push(vm.constants[constIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpClosure:
+			constIndex := code.ReadUint32(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+5:])
+			vm.currentFrame().ip += 5
+
+			err := vm.pushClosure(int(constIndex), int(numFree))
+			if err != nil {
+				return err
+			}
 		case code.OpJump:
-			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip = pos - 1
+			pos := int(code.ReadUint32(ins[ip+1:]))
+			if pos <= ip {
+				if err := vm.checkCanceled(); err != nil {
+					return err
+				}
+			}
+			vm.currentFrame().ip = pos - 1
 		case code.OpJumpNotTruthy:
-			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip += 2
+			pos := int(code.ReadUint32(ins[ip+1:]))
+			vm.currentFrame().ip += 4
 
 			condition := vm.pop()
 			if !isTruthy(condition) {
-				ip = pos - 1
+				if pos <= ip {
+					if err := vm.checkCanceled(); err != nil {
+						return err
+					}
+				}
+				vm.currentFrame().ip = pos - 1
 			}
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpJumpNull, code.OpJumpNotNull:
+			pos := int(code.ReadUint32(ins[ip+1:]))
+			vm.currentFrame().ip += 4
+
+			_, null := vm.stack[vm.sp-1].(*object.Null)
+			if null == (op == code.OpJumpNull) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpPow:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
@@ -98,6 +419,199 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err := vm.pushAllocated(array)
+			if err != nil {
+				return err
+			}
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			err = vm.pushAllocated(hash)
+			if err != nil {
+				return err
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeIndexExpression(left, index)
+			if err != nil {
+				return err
+			}
+		case code.OpRange:
+			inclusive := code.ReadUint8(ins[ip+1:]) == 1
+			vm.currentFrame().ip += 1
+
+			step := vm.pop()
+			end := vm.pop()
+			start := vm.pop()
+
+			r, err := object.NewRange(start, end, step, inclusive)
+			if err != nil {
+				return err
+			}
+			err = vm.pushAllocated(r)
+			if err != nil {
+				return err
+			}
+		case code.OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			err := vm.pushHostResult(object.Slice(left, start, end))
+			if err != nil {
+				return err
+			}
+		case code.OpModule:
+			numExports := int(code.ReadUint32(ins[ip+1:]))
+			vm.currentFrame().ip += 4
+
+			start := vm.sp - 2*numExports - 1
+			mod := vm.buildModule(start, vm.sp)
+			vm.sp = start
+
+			err := vm.pushAllocated(mod)
+			if err != nil {
+				return err
+			}
+		case code.OpTry:
+			pos := int(code.ReadUint32(ins[ip+1:]))
+			vm.currentFrame().ip += 4
+
+			vm.handlers = append(vm.handlers, handler{ip: pos, sp: vm.sp, framesIndex: vm.framesIndex})
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpThrow:
+			return object.Throw(vm.pop())
+		case code.OpUndefined:
+			constIndex := code.ReadUint32(ins[ip+1:])
+			vm.currentFrame().ip += 4
+
+			return fmt.Errorf("identifier not found: %s", vm.constants[constIndex].(*object.String).Value)
+		case code.OpMatch:
+			constIndex := code.ReadUint32(ins[ip+1:])
+			vm.currentFrame().ip += 4
+
+			pattern := vm.constants[constIndex].(*object.Pattern)
+			err := vm.executeMatch(pattern.Pattern, vm.stack[vm.sp-1])
+			if err != nil {
+				return err
+			}
+		case code.OpDestructure:
+			constIndex := code.ReadUint32(ins[ip+1:])
+			vm.currentFrame().ip += 4
+
+			pattern := vm.constants[constIndex].(*object.Pattern)
+			bound, errObj := object.Destructure(pattern.Pattern, vm.pop())
+			if errObj != nil {
+				return errObj
+			}
+			for _, obj := range bound {
+				err := vm.push(obj)
+				if err != nil {
+					return err
+				}
+			}
+		case code.OpGetAttribute:
+			name := vm.pop()
+			obj := vm.pop()
+
+			err := vm.executeGetAttribute(obj, name.(*object.String).Value)
+			if err != nil {
+				return err
+			}
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.checkCanceled(); err != nil {
+				return err
+			}
+
+			err := vm.executeCall(int(numArgs), nil)
+			if err != nil {
+				return err
+			}
+		case code.OpApply:
+			if err := vm.checkCanceled(); err != nil {
+				return err
+			}
+
+			numArgs, named, err := vm.pushApplyArguments()
+			if err != nil {
+				return err
+			}
+			err = vm.executeCall(numArgs, named)
+			if err != nil {
+				return err
+			}
+		case code.OpTailCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.checkCanceled(); err != nil {
+				return err
+			}
+
+			err := vm.executeTailCall(int(numArgs), nil)
+			if err != nil {
+				return err
+			}
+		case code.OpTailApply:
+			if err := vm.checkCanceled(); err != nil {
+				return err
+			}
+
+			numArgs, named, err := vm.pushApplyArguments()
+			if err != nil {
+				return err
+			}
+			err = vm.executeTailCall(numArgs, named)
+			if err != nil {
+				return err
+			}
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.framesIndex == 1 {
+				// A return outside of a function ends the program. The value was just
+				// popped, so it is what LastPoppedStackElem reports.
+				vm.currentFrame().ip = len(ins) - 1
+				continue
+			}
+
+			vm.leaveFrame()
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(returnValue)
+			if err != nil {
+				return err
+			}
+		case code.OpReturn:
+			vm.leaveFrame()
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(Null)
+			if err != nil {
+				return err
+			}
 		case code.OpNull:
 			err := vm.push(Null)
 			if err != nil {
@@ -121,39 +635,464 @@ func (vm *VM) Run() error {
 	return nil
 }
 
+// Call calls fn, a closure or a builtin, with args and returns its result. Globals
+// set by earlier runs are visible to fn.
+func (vm *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	return vm.CallContext(context.Background(), fn, args...)
+}
+
+// CallContext is like Call but aborts with a *object.CancelError once ctx is done.
+func (vm *VM) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	if !code.Fits(1, len(args)) {
+		return nil, fmt.Errorf("too many arguments in call, the limit is %d", code.MaxUint8)
+	}
+
+	// Run a main function that consists of nothing but the call, with the callee
+	// and its arguments already on the stack.
+	mainFn := &object.CompiledFunction{Instructions: code.Make(code.OpCall, len(args))}
+	vm.frames[0] = NewFrame(&object.Closure{Fn: mainFn}, 0)
+	vm.framesIndex = 1
+	vm.sp = 0
+
+	err := vm.push(fn)
+	if err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		err = vm.push(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = vm.RunContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.stack[vm.sp-1], nil
+}
+
 func (vm *VM) LastPoppedStackElem() object.Object {
 	return vm.stack[vm.sp]
 }
 
-func (vm *VM) push(obj object.Object) error {
-	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+// growStack makes room for size elements on the stack.
+func (vm *VM) growStack(size int) {
+	if size <= len(vm.stack) {
+		return
 	}
 
+	grown := 2 * len(vm.stack)
+	if grown < size {
+		grown = size
+	}
+	stack := make([]object.Object, grown)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+func (vm *VM) push(obj object.Object) error {
+	vm.growStack(vm.sp + 1)
+
 	vm.stack[vm.sp] = obj
 	vm.sp++
 
 	return nil
 }
 
+// pushAllocated pushes an object created by the VM, counting it against the allocation limit.
+func (vm *VM) pushAllocated(obj object.Object) error {
+	if err := vm.allocate(1); err != nil {
+		return err
+	}
+
+	return vm.push(obj)
+}
+
+// allocate counts n allocations against the allocation limit.
+func (vm *VM) allocate(n int) error {
+	vm.allocations += n
+	if vm.limits.MaxAllocations > 0 && vm.allocations > vm.limits.MaxAllocations {
+		return &object.AllocationLimitError{Limit: vm.limits.MaxAllocations}
+	}
+
+	return nil
+}
+
 func (vm *VM) pop() object.Object {
 	obj := vm.stack[vm.sp-1]
 	vm.sp--
 	return obj
 }
 
+// pushApplyArguments pushes the positional arguments OpApply passes, and returns
+// how many there are and the named ones.
+func (vm *VM) pushApplyArguments() (int, map[string]object.Object, error) {
+	namedArgs := vm.pop()
+	groups := vm.pop().(*object.Array)
+
+	start := vm.sp
+	for _, group := range groups.Elements {
+		args, ok := group.(*object.Array)
+		if !ok {
+			return 0, nil, fmt.Errorf("spread argument must be ARRAY, got %s", group.Type())
+		}
+		for _, arg := range args.Elements {
+			err := vm.push(arg)
+			if err != nil {
+				return 0, nil, err
+			}
+		}
+	}
+	numArgs := vm.sp - start
+
+	var named map[string]object.Object
+	if hash, ok := namedArgs.(*object.Hash); ok {
+		named = make(map[string]object.Object, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			named[pair.Key.(*object.String).Value] = pair.Value
+		}
+	}
+
+	return numArgs, named, nil
+}
+
+// executeTailCall is executeCall for a call whose value the current function
+// returns. A closure called takes the place of the current frame, so tail recursive
+// functions run in constant stack.
+func (vm *VM) executeTailCall(numArgs int, named map[string]object.Object) error {
+	start := vm.sp - 1 - numArgs
+
+	if _, ok := vm.stack[start].(*object.Closure); ok && vm.framesIndex > 1 {
+		vm.leaveFrame()
+		frame := vm.popFrame()
+
+		copy(vm.stack[frame.basePointer-1:], vm.stack[start:vm.sp])
+		vm.sp = frame.basePointer + numArgs
+	}
+
+	return vm.executeCall(numArgs, named)
+}
+
+// executeCall calls the function below the numArgs arguments on top of the stack.
+// Only closures take named arguments.
+func (vm *VM) executeCall(numArgs int, named map[string]object.Object) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	if _, ok := callee.(*object.Closure); !ok && len(named) > 0 {
+		return fmt.Errorf("named arguments are not supported by %s", callee.Type())
+	}
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs, named)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	case object.Callable:
+		// The host may keep the arguments, so it gets a copy rather than the stack.
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		result, err := callee.Call(args...)
+		vm.sp = vm.sp - numArgs - 1
+		return vm.pushHostResult(result, err)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int, named map[string]object.Object) error {
+	sig := cl.Fn.Signature
+	if numArgs != cl.Fn.NumParameters || named != nil || sig != nil && sig.Rest != "" {
+		if sig == nil {
+			return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+		}
+
+		base := vm.sp - numArgs
+		args, err := sig.Arrange(vm.stack[base:vm.sp], named)
+		if err != nil {
+			return err
+		}
+		vm.growStack(base + len(args))
+		copy(vm.stack[base:], args)
+		vm.sp, numArgs = base+len(args), len(args)
+	}
+
+	// The frame plays the part of the evaluator's function environment.
+	if err := vm.allocate(1); err != nil {
+		return err
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	vm.growStack(frame.basePointer + cl.Fn.NumLocals)
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	// Builtins registered by the host may keep the arguments, so they get a copy
+	// rather than the stack.
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Invoke(runtime{vm}, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if vm.err != nil {
+		return vm.err
+	}
+
+	switch result := result.(type) {
+	case nil:
+		return vm.push(Null)
+	case *object.Error:
+		return result
+	default:
+		return vm.pushAllocated(result)
+	}
+}
+
+// runtime lets builtins call functions while the VM is running.
+type runtime struct {
+	vm *VM
+}
+
+// Call calls fn with args on top of the stack and runs the VM until the call
+// returns. Errors are returned as Go errors, and a limit or cancel error also
+// aborts the execution once the builtin returns.
+func (r runtime) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	vm := r.vm
+	sp, framesIndex, handlers := vm.sp, vm.framesIndex, len(vm.handlers)
+
+	err := vm.callFromBuiltin(fn, args)
+	if err != nil {
+		// Unwind whatever the failed call left behind, so the builtin can carry on.
+		vm.sp, vm.framesIndex, vm.handlers = sp, framesIndex, vm.handlers[:handlers]
+		if object.IsAbort(err) {
+			vm.err = err
+		}
+		return nil, err
+	}
+
+	return vm.pop(), nil
+}
+
+// IO returns the IO of the VM.
+func (r runtime) IO() *object.IO {
+	return r.vm.io
+}
+
+// Allocate counts n allocations made by a builtin against the allocation limit. If
+// that exceeds it, the execution is aborted once the builtin returns.
+func (r runtime) Allocate(n int) error {
+	err := r.vm.allocate(n)
+	if err != nil {
+		r.vm.err = err
+	}
+	return err
+}
+
+// Random returns the source of random numbers of the VM.
+func (r runtime) Random() *object.Random {
+	if r.vm.random == nil {
+		r.vm.random = object.NewRandom(time.Now().UnixNano())
+	}
+	return r.vm.random
+}
+
+func (vm *VM) executeMatch(pattern ast.Pattern, value object.Object) error {
+	bound, ok := object.Match(pattern, value)
+	if !ok {
+		return vm.push(False)
+	}
+
+	for _, obj := range bound {
+		err := vm.push(obj)
+		if err != nil {
+			return err
+		}
+	}
+	return vm.push(True)
+}
+
+func (vm *VM) callFromBuiltin(fn object.Object, args []object.Object) error {
+	if !code.Fits(1, len(args)) {
+		return fmt.Errorf("too many arguments in call, the limit is %d", code.MaxUint8)
+	}
+
+	stop := vm.framesIndex
+
+	err := vm.push(fn)
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		err = vm.push(arg)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = vm.executeCall(len(args), nil)
+	if err != nil {
+		return err
+	}
+
+	if vm.framesIndex > stop {
+		return vm.run(stop)
+	}
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.pushAllocated(closure)
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hashedPairs := make(map[object.HashKey]object.HashPair)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		pair := object.HashPair{Key: key, Value: value}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		hashedPairs[hashKey.HashKey()] = pair
+	}
+
+	return &object.Hash{Pairs: hashedPairs}, nil
+}
+
+// buildModule builds a module out of the path at startIndex followed by pairs of
+// export names and values.
+func (vm *VM) buildModule(startIndex, endIndex int) object.Object {
+	mod := &object.Module{
+		Path:    vm.stack[startIndex].(*object.String).Value,
+		Exports: make(map[string]object.Object),
+	}
+
+	for i := startIndex + 1; i < endIndex; i += 2 {
+		mod.Exports[vm.stack[i].(*object.String).Value] = vm.stack[i+1]
+	}
+
+	return mod
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HASH:
+		return vm.executeHashIndex(left, index)
+	default:
+		if indexable, ok := left.(object.Indexable); ok {
+			return vm.pushHostResult(indexable.Index(index))
+		}
+		return fmt.Errorf("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeGetAttribute(obj object.Object, name string) error {
+	return vm.pushHostResult(vm.registry.GetAttribute(obj, name))
+}
+
+// pushHostResult pushes the result of a method of a host defined object, or returns
+// its error.
+func (vm *VM) pushHostResult(obj object.Object, err error) error {
+	if err != nil {
+		return err
+	}
+	if obj == nil {
+		return vm.push(Null)
+	}
+	return vm.pushAllocated(obj)
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	i := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if i < 0 || i > max {
+		return vm.push(Null)
+	}
+
+	return vm.push(arrayObject.Elements[i])
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(pair.Value)
+}
+
 func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
+	if arithmetic, ok := left.(object.Arithmetic); ok {
+		return vm.pushHostResult(arithmetic.BinaryOperation(operators[op], right))
+	}
+
 	leftType := left.Type()
 	rightType := right.Type()
 
-	if leftType == object.INTEGER && rightType == object.INTEGER {
+	switch {
+	case leftType == object.INTEGER && rightType == object.INTEGER:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case leftType == object.FLOAT && rightType == object.FLOAT:
+		return vm.executeBinaryFloatOperation(op, left, right)
+	case leftType == object.STRING && rightType == object.STRING:
+		return vm.executeBinaryStringOperation(op, left, right)
+	case leftType != rightType:
+		return fmt.Errorf("type mismatch: %s %s %s", leftType, operators[op], rightType)
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s", leftType, operators[op], rightType)
 	}
-
-	return fmt.Errorf("unsupported types for binary operation: %s %s", leftType, rightType)
 }
 
 func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left object.Object, right object.Object) error {
@@ -170,29 +1109,107 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left object.Object,
 	case code.OpMul:
 		result = leftValue * rightValue
 	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
 		result = leftValue / rightValue
+	case code.OpPow:
+		result = int64(math.Pow(float64(leftValue), float64(rightValue)))
 	default:
 		return fmt.Errorf("unknown integer operator: %d", op)
 	}
 
-	return vm.push(&object.Integer{Value: result})
+	return vm.pushAllocated(&object.Integer{Value: result})
+}
+
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left object.Object, right object.Object) error {
+	leftValue := left.(*object.Float).Value
+	rightValue := right.(*object.Float).Value
+
+	var result float64
+
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		result = leftValue / rightValue
+	case code.OpPow:
+		result = math.Pow(leftValue, rightValue)
+	default:
+		return fmt.Errorf("unknown float operator: %d", op)
+	}
+
+	return vm.pushAllocated(&object.Float{Value: result})
+}
+
+func (vm *VM) executeBinaryStringOperation(op code.Opcode, left object.Object, right object.Object) error {
+	if op != code.OpAdd {
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	return vm.pushAllocated(&object.String{Value: leftValue + rightValue})
 }
 
 func (vm *VM) executeComparison(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
-	if left.Type() == object.INTEGER && right.Type() == object.INTEGER {
+	result, ok, err := object.Compare(left, right)
+	if err != nil {
+		return err
+	}
+	if ok {
+		switch op {
+		case code.OpEqual:
+			return vm.push(nativeBoolToBooleanObject(result == 0))
+		case code.OpNotEqual:
+			return vm.push(nativeBoolToBooleanObject(result != 0))
+		default:
+			return vm.push(nativeBoolToBooleanObject(result > 0))
+		}
+	}
+
+	switch {
+	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
 		return vm.executeIntegerComparison(op, left, right)
+	case left.Type() == object.FLOAT && right.Type() == object.FLOAT:
+		return vm.executeFloatComparison(op, left, right)
+	case left.Type() == object.STRING && right.Type() == object.STRING:
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
 	}
 
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(right == left))
+		return vm.push(nativeBoolToBooleanObject(objectsEqual(left, right)))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(right != left))
+		return vm.push(nativeBoolToBooleanObject(!objectsEqual(left, right)))
+	default:
+		if left.Type() != right.Type() {
+			return fmt.Errorf("type mismatch: %s %s %s", left.Type(), operators[op], right.Type())
+		}
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+// objectsEqual compares booleans and nulls by value, so those created outside the
+// VM are equal to its own, and everything else by identity.
+func objectsEqual(left, right object.Object) bool {
+	switch left := left.(type) {
+	case *object.Boolean:
+		right, ok := right.(*object.Boolean)
+		return ok && left.Value == right.Value
+	case *object.Null:
+		_, ok := right.(*object.Null)
+		return ok
 	default:
-		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
+		return left == right
 	}
 }
 
@@ -212,30 +1229,62 @@ func (vm *VM) executeIntegerComparison(op code.Opcode, left object.Object, right
 	}
 }
 
-func (vm *VM) executeBangOperator() error {
-	operand := vm.pop()
+func (vm *VM) executeFloatComparison(op code.Opcode, left object.Object, right object.Object) error {
+	leftValue := left.(*object.Float).Value
+	rightValue := right.(*object.Float).Value
 
-	switch operand {
-	case True:
-		return vm.push(False)
-	case False:
-		return vm.push(True)
-	case Null:
-		return vm.push(True)
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
 	default:
-		return vm.push(False)
+		return fmt.Errorf("unknown operator: %d", op)
 	}
 }
 
+func (vm *VM) executeBangOperator() error {
+	operand := vm.pop()
+
+	// Booleans returned by builtins aren't the shared True and False, so go by value.
+	return vm.push(nativeBoolToBooleanObject(!isTruthy(operand)))
+}
+
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 
-	if operand.Type() != object.INTEGER {
-		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+	switch operand := operand.(type) {
+	case *object.Integer:
+		return vm.pushAllocated(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		return vm.pushAllocated(&object.Float{Value: -operand.Value})
+	default:
+		return fmt.Errorf("unknown operator: -%s", operand.Type())
 	}
+}
+
+// operators maps opcodes to the operators they were compiled from, for error messages.
+var operators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpPow:         "**",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+}
 
-	value := operand.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -value})
+// checkCanceled returns a *object.CancelError if the context of the run is done.
+func (vm *VM) checkCanceled() error {
+	select {
+	case <-vm.done:
+		return &object.CancelError{Err: vm.ctx.Err()}
+	default:
+		return nil
+	}
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -1,10 +1,16 @@
 package vm
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"Gengo/ast"
+	"Gengo/code"
 	"Gengo/compiler"
 	"Gengo/lexer"
 	"Gengo/object"
@@ -98,6 +104,739 @@ func TestGlobalLetStatements(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5 + 3.5", 5.0},
+		{"-2.5 * 2.0", -5.0},
+		{"2.0 ** 3.0", 8.0},
+		{"2 ** 8", 256},
+		{"1.5 < 2.5", true},
+		{"1.5 == 1.5", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`"gengo"`, "gengo"},
+		{`"gen" + "go"`, "gengo"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArrayAndHashLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1, 2, 3]", []int{1, 2, 3}},
+		{"[1 + 2, 3 * 4]", []int{3, 12}},
+		{"{}", map[object.HashKey]int64{}},
+		{"{1: 2, 2: 3}", map[object.HashKey]int64{
+			(&object.Integer{Value: 1}).HashKey(): 2,
+			(&object.Integer{Value: 2}).HashKey(): 3,
+		}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[][0]", Null},
+		{"[1][-1]", Null},
+		{"{1: 1, 2: 2}[2]", 2},
+		{"{1: 1}[0]", Null},
+		{`{"a": 5}["a"]`, 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn() { 5 + 10; }; f();", 15},
+		{"let one = fn() { 1; }; let two = fn() { one() + 1 }; two();", 2},
+		{"let f = fn() { return 99; 100; }; f();", 99},
+		{"let f = fn() { }; f();", Null},
+		{"let f = fn() { let a = 1; }; f();", Null},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2);", 3},
+		{"let g = 10; let f = fn(a) { let b = 2; a * b + g }; f(3) + f(4);", 34},
+		{"if (true) { let a = 1; }", Null},
+		{"return 1; 2;", 1},
+		{"if (true) { return 3; }; 4;", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []vmTestCase{
+		{"fn add(a, b) { a + b } add(1, 2)", 3},
+		{"let x = double(2); fn double(n) { n * 2 } x", 4},
+		{"fn even(n) { if (n == 0) { true } else { odd(n - 1) } } fn odd(n) { if (n == 0) { false } else { even(n - 1) } } even(10)", true},
+		{"let f = fn(x) { fn even(n) { if (n == 0) { 1 } else { odd(n - 1) } } fn odd(n) { if (n == 0) { 0 } else { even(n - 1) } } [even(x), odd(x)] }; f(7)", []int{0, 1}},
+		{"let k = 5; let f = fn() { fn a() { b() + k } fn b() { k } a() }; f()", 10},
+		{"let f = fn() { let a = g(); fn g() { 7 } a }; f()", 7},
+		{"if (true) { let r = h(); fn h() { 3 } r }", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"const x = 5; x * 2", 10},
+		{"const [a, b] = [1, 2]; a + b", 3},
+		{"const x = 1; let f = fn() { let x = 2; x }; f() + x", 3},
+		{"const x = 1; let f = fn(x) { x }; f(2)", 2},
+		{"let x = 1; const x = 2; x", 2},
+		{"let f = fn() { const n = 3; n * n }; f()", 9},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{"let sum = fn(xs, acc) { if (len(xs) == 0) { acc } else { sum(rest(xs), acc + first(xs)) } }; sum(range(100000), 0)", 4999950000},
+		{"let count = fn(n) { if (n == 0) { return 0 } return count(n - 1) }; count(100000)", 0},
+		{"fn even(n) { if (n == 0) { 1 } else { odd(n - 1) } } fn odd(n) { if (n == 0) { 0 } else { even(n - 1) } } even(100001)", 0},
+		{"let f = fn(n) { match (n) { 0 => 7, _ => f(n - 1) } }; f(100000)", 7},
+		{"let f = fn(n) { try { throw n } catch (e) { if (e.value == 0) { 7 } else { f(e.value - 1) } } }; f(100000)", 7},
+		{"let f = fn(n, acc) { if (n == 0) { acc } else { f(...[n - 1], acc: acc + 1) } }; f(100000, 0)", 100000},
+		{"let f = fn(n) { try { if (n == 0) { 1 } else { return f(n - 1) } } finally { 2 } }; f(100)", 1},
+		{"let f = fn(n) { n == 0 ? 7 : f(n - 1) }; f(100000)", 7},
+		{"let f = fn(n) { if (n == 0) { 7 } else { null ?? f(n - 1) } }; f(100000)", 7},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn(a, b = 2) { a * b }; f(3)", 6},
+		{"let f = fn(a, b = 2) { a * b }; f(3, 3)", 9},
+		{"let f = fn(a, b = a + 1) { let c = 1; a * b + c }; f(3)", 13},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", []int{2, 3}},
+		{"let f = fn(...rest) { len(rest) }; f()", 0},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(...[1, 2], 3)", 123},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(1, ...[], ...[2, 3])", 123},
+		{"let f = fn(a, b = 0, c = 0) { a * 100 + b * 10 + c }; f(1, c: 3)", 103},
+		{"let f = fn(a, b) { a - b }; f(b: 1, a: 5)", 4},
+		{"let f = fn([a, b] = [1, 2], c = a + b) { c }; f()", 3},
+		{"let n = 10; let f = fn(a = n) { fn(b = a) { a + b } }; f()()", 20},
+		{"len(...[[1, 2, 3]])", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("four")`, 4},
+		{`len("héllo wörld")`, 11},
+		{`len([1, 2, 3])`, 3},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, Null},
+		{`last([1, 2, 3])`, 3},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`push([], 1)`, []int{1}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMemberExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`{"name": "gengo"}.name`, "gengo"},
+		{`{"name": "gengo"}.missing`, Null},
+		{`let user = {"address": {"city": "Oslo"}}; user.address.city`, "Oslo"},
+		{`"abc".upper()`, "ABC"},
+		{`"ABC".lower().len()`, 3},
+		{`"ÄBÇ".len()`, 3},
+		{`"a,b".split(",").len()`, 2},
+		{`" gengo ".trim().startsWith("gen")`, true},
+		{`"x".repeat(3)`, "xxx"},
+		{`[1, 2, 3].len()`, 3},
+		{`[1, 2].push(3)`, []int{1, 2, 3}},
+		{`[1, 2, 3].map(fn(x) { x * 2 })`, []int{2, 4, 6}},
+		{`let offset = 10; [1, 2].map(fn(x) { x + offset }).map(fn(x) { [x].map(fn(y) { y * 2 })[0] })`, []int{22, 24}},
+		{`["a", "bc"].map(len)`, []int{1, 2}},
+		{`[1, 2, 3, 4].filter(fn(x) { x > 2 })`, []int{3, 4}},
+		{`[1, 2, 3].reduce(fn(acc, x) { acc + x })`, 6},
+		{`[1, 2, 3].reduce(fn(acc, x) { acc + x }, 10)`, 16},
+		{`[3, 1, 2].sort()`, []int{1, 2, 3}},
+		{`[3, 1, 2].sort(fn(a, b) { b - a }).reverse()`, []int{1, 2, 3}},
+		{`[1, 2].any(fn(x) { x > 1 })`, true},
+		{`[1, 2].all(fn(x) { x > 1 })`, false},
+		{`let h = {"len": 7}; h.len`, 7},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionalOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"true ? 1 : 2", 1},
+		{"false ? 1 : 2", 2},
+		{"null ? 1 : 2", 2},
+		{"1 > 2 ? 1 : 2 > 1 ? 2 : 3", 2},
+		{`let f = fn() { throw "boom" }; false ? f() : 2`, 2},
+		{"null ?? 1", 1},
+		{"2 ?? 1", 2},
+		{"false ?? 1", false},
+		{"null ?? null", Null},
+		{"null ?? null ?? 3", 3},
+		{`let f = fn() { throw "boom" }; 1 ?? f()`, 1},
+		{`{"a": 1}.b ?? 2`, 2},
+		{"let xs = null; xs?.[0]", Null},
+		{"let xs = [1]; xs?.[0]", 1},
+		{"let f = fn() { throw 1 }; let xs = null; xs?.[f()]", Null},
+		{"let h = null; h?.name", Null},
+		{`let h = {"name": {"first": "a"}}; h?.name?.first`, "a"},
+		{`let h = {}; h.name?.first ?? "default"`, "default"},
+		{"let xs = null; xs?.[0] == null", true},
+		{"let f = fn(h) { let x = h?.a ?? 1; x + 1 }; f(null)", 2},
+		// A null before ?. skips the rest of the chain, calls included.
+		{"let h = null; h?.name.first", Null},
+		{"let x = null; x?.foo()", Null},
+		{"let x = null; x?.foo().bar[0]", Null},
+		{"let f = fn() { throw 1 }; let x = null; x?.foo(f())", Null},
+		{"let x = null; x?.len() ?? 0", 0},
+		{`let x = "ab"; x?.len() + 1`, 3},
+		{"let f = fn(x) { x?.a?.b(x?.c) }; f(null)", Null},
+		{"match (null) { null => 1, _ => 2 }", 1},
+		{"match (0) { null => 1, _ => 2 }", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestPipelines(t *testing.T) {
+	tests := []vmTestCase{
+		{"let double = fn(x) { x * 2 }; 3 |> double", 6},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3)", 7},
+		{"[1, 2, 3] |> map(fn(x) { x * 2 }) |> filter(fn(x) { x > 2 })", []int{4, 6}},
+		{"let f = fn(a, b = 1, c = 1) { a * b * c }; 2 |> f(c: 5)", 10},
+		{"let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; (inc >> double)(3)", 8},
+		{"let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; 3 |> double >> inc", 7},
+		{"let add = fn(a, b) { a + b }; let double = fn(x) { x * 2 }; (add >> double)(1, 2)", 6},
+		{"let f = fn(x) { x + 1 }; let g = f >> f >> f; let f = fn(x) { x }; g(0)", 3},
+		{"[[1], [2, 3]] |> map(len >> fn(n) { n * 10 })", []int{10, 20}},
+		{"let f = fn() { let inc = fn(x) { x + 1 }; inc >> inc }; let h = f(); 1 |> h", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRangesAndSlices(t *testing.T) {
+	tests := []vmTestCase{
+		{"len(1..10)", 10},
+		{"len(0..<10 step 3)", 4},
+		{"(0..10 step 5)[2]", 10},
+		{"(0..10)[11]", Null},
+		{"reduce(1..100, fn(acc, x) { acc + x }, 0)", 5050},
+		{"map(3..1 step -1, fn(x) { x })", []int{3, 2, 1}},
+		{"map((1..5)[1:-1], fn(x) { x })", []int{2, 3, 4}},
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:-3]", []int{1}},
+		{"[1, 2][:]", []int{1, 2}},
+		{`"hello"[:2]`, "he"},
+		{`"hello"[-3:]`, "llo"},
+		{"let xs = null; xs?.[1:]", Null},
+		{"let f = fn() { throw 1 }; let xs = null; xs?.[f():]", Null},
+		{"let xs = [1, 2, 3]; xs[first(xs):len(xs)]", []int{2, 3}},
+		{"let f = fn(xs) { if (len(xs) == 0) { 0 } else { xs[0] + f(xs[1:]) } }; f(1..<10)", 45},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{"let newClosure = fn(a) { fn() { a; }; }; let closure = newClosure(99); closure();", 99},
+		{"let adder = fn(a) { fn(b) { fn(c) { a + b + c } } }; adder(1)(2)(3);", 6},
+		{`
+		let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } };
+		countDown(10);
+		`, 0},
+		{`
+		let wrapper = fn() {
+			let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } };
+			countDown(1);
+		};
+		wrapper();
+		`, 0},
+		{`
+		let fibonacci = fn(x) {
+			if (x == 0) { return 0; }
+			if (x == 1) { return 1; }
+			fibonacci(x - 1) + fibonacci(x - 2);
+		};
+		fibonacci(15);
+		`, 610},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"try { 1 } catch { 2 }", 1},
+		{"try { 1 + true } catch { 2 }", 2},
+		{"try { throw 3 } catch (e) { e.value }", 3},
+		{"let f = fn(x) { let a = 1; a + try { throw x } catch (e) { e.value + 1 } }; f(1)", 3},
+		{"let f = fn(x) { x + 1 }; f(try { fn() { throw 1 }() } catch { 10 })", 11},
+		{"let f = fn() { try { try { return 1 } finally { throw 2 } } catch (e) { e.value + 10 } }; f()", 12},
+		{"let f = fn(n) { try { if (n > 0) { f(n - 1) } else { throw 0 } } catch (e) { e.value + 1 } }; f(3)", 1},
+		{"let f = fn() { try { return 1 } catch { 2 } }; f() + try { throw 2 } catch (e) { e.value }", 3},
+		{"let f = fn() { try { throw 1 } finally { 2 } }; try { f() } catch (e) { e.value }", 1},
+		{"try { try { throw 1 } catch (e) { throw e.value + 1 } } catch (e) { e.value }", 2},
+		{`try { 1 / 0 } catch (e) { e.message }`, "division by zero"},
+		{"let e = 1; try { throw 2 } catch (e) { 3 }; e", 1},
+		{"let f = fn() { let e = 1; try { throw 2 } catch (e) { 3 }; e }; f()", 1},
+		{"const e = 1; try { throw 2 } catch (e) { e.value }", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"match (1) { 1 => 10, _ => 20 }", 10},
+		{"match (2) { 1 => 10, _ => 20 }", 20},
+		{"match (3) { 1 => 10 }", Null},
+		{"match (-1) { -1 => true, _ => false }", true},
+		{`match ("a") { "b" => 1, "a" => 2 }`, 2},
+		{"match ([1, 2, 3]) { [a, ...r] => r }", []int{2, 3}},
+		{"match ([1, 2]) { [a] => a, [a, b] => a + b }", 3},
+		{"match ([1, 2, 3]) { [1, _, c] => c }", 3},
+		{`match ({"x": 1, "y": 2}) { {"x": x, "y": y} => x * 10 + y }`, 12},
+		{`match ({"x": 1}) { {"y": y} => y, _ => 0 }`, 0},
+		{"match (5) { n if n > 10 => 1, n if n > 3 => 2, _ => 3 }", 2},
+		{"match (5) { n => { let m = n * 2; m + 1 } }", 11},
+		{"let f = fn(x) { let a = 1; match (x) { [b, c] => a + b + c, _ => a } }; f([2, 3]) + f(1)", 7},
+		{"let f = fn(x) { match (x) { 0 => { return 1 }, _ => 2 }; 3 }; f(0) + f(1)", 4},
+		{"let f = fn(n) { match (n) { 0 => 1, _ => n * f(n - 1) } }; f(5)", 120},
+		{"1 + match (true) { true => 1, false => 2 }", 2},
+		{"let x = 1; match (5) { x if false => 0, _ => x }", 1},
+		{"let x = 1; match (5) { x => x }; x", 1},
+		{"let f = fn() { let x = 1; match (5) { x if false => 0, _ => x } }; f()", 1},
+		{"const x = 1; match (2) { x => x }", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []vmTestCase{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, ...rest] = [1, 2, 3]; rest", []int{2, 3}},
+		{`let {x, "y": [y, _]} = {"x": 1, "y": [2, 3]}; x * 10 + y`, 12},
+		{"let f = fn([a, b], c) { a + b + c }; f([1, 2], 3)", 6},
+		{`let f = fn({x}) { let [a, ...r] = x; len(r) + a }; f({"x": [1, 2, 3]})`, 3},
+		{"let f = fn(n) { fn([a, b]) { a + b + n } }; f(1)([2, 3])", 6},
+		{"let f = fn() { let [a, b] = [1, 2]; let c = 3; a + b + c }; f()", 6},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a) { a }();", "wrong number of arguments: want=1, got=0"},
+		{"fn(a, b = 1) { a }(1, 2, 3)", "wrong number of arguments: want=1 to 2, got=3"},
+		{"fn(a, ...b) { a }()", "wrong number of arguments: want>=1, got=0"},
+		{"fn(a) { a }(b: 1)", "no parameter named b"},
+		{"fn(a, b = 1) { a }(b: 2)", "missing argument for parameter a"},
+		{"fn(a) { a }(...1)", "spread argument must be ARRAY, got INTEGER"},
+		{"len(x: 1)", "named arguments are not supported by BUILTIN"},
+		{"1 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+		{"1();", "not a function: INTEGER"},
+		{"foobar", "identifier not found: foobar"},
+		{"fn() { foobar }()", "identifier not found: foobar"},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{"{[1]: 2}", "unusable as hash key: ARRAY"},
+		{"1[0]", "index operator not supported: INTEGER"},
+		{`throw "boom"`, "boom"},
+		{"try { throw 1 } finally { 2 }", "1"},
+		{"let [a, b] = [1];", "cannot destructure ARRAY with [a, b]: want length 2, got 1"},
+		{`let {name} = 1;`, "cannot destructure INTEGER with {name}: want HASH, got INTEGER"},
+		{`fn({name}) { name }({"age": 1})`, `cannot destructure HASH with {name}: missing key "name"`},
+		{`let h = {"name": null}; h?.name.first`, "attribute access not supported: NULL"},
+		{`0.."a"`, "range bounds must be INTEGER, got STRING"},
+		{"0..10 step 0", "range step must not be zero"},
+		{`[1, 2]["a":]`, "slice bounds must be INTEGER, got STRING"},
+		{"[1, 2][2:1]", "slice bounds out of range [2:1]"},
+		{"1[0:1]", "slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err = New(comp.Bytecode()).Run()
+		if err == nil {
+			t.Errorf("expected VM error %q for %q, got none", tt.expected, tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error for %q. want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parse("let base = 10; let add = fn(a, b) { base + a + b };"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	globals := make([]object.Object, GlobalsSize)
+	vm := NewWithGlobalsStore(comp.Bytecode(), globals)
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	result, err := vm.Call(globals[1], &object.Integer{Value: 1}, &object.Integer{Value: 2})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 13, result)
+
+	result, err = vm.Call(object.GetBuiltinByName("len"), &object.String{Value: "four"})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 4, result)
+}
+
+// packer is a callable that keeps its arguments.
+type packer struct{}
+
+func (p *packer) Type() object.ObjectType { return "PACKER" }
+func (p *packer) Inspect() string         { return "packer" }
+
+func (p *packer) Call(args ...object.Object) (object.Object, error) {
+	return &object.Array{Elements: args}, nil
+}
+
+func TestCallableArguments(t *testing.T) {
+	symbolTable := compiler.NewSymbolTableWithBuiltins()
+	pack := symbolTable.Define("pack")
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	err := comp.Compile(parse("let a = pack(1, 2); let b = pack(3, 4); [a, b]"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	globals := make([]object.Object, GlobalsSize)
+	globals[pack.Index] = &packer{}
+	vm := NewWithGlobalsStore(comp.Bytecode(), globals)
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	// The arguments kept by the callable must not change with the stack.
+	if result := vm.LastPoppedStackElem().Inspect(); result != "[[1, 2], [3, 4]]" {
+		t.Errorf("wrong result. want=%s, got=%s", "[[1, 2], [3, 4]]", result)
+	}
+}
+
+func TestRegisteredFuncArguments(t *testing.T) {
+	registry := object.NewRegistry()
+	err := registry.RegisterFunc("packf", func(args ...object.Object) object.Object {
+		return &object.Array{Elements: args}
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %s", err)
+	}
+
+	comp := compiler.NewWithState(compiler.NewSymbolTableWithRegistry(registry), []object.Object{})
+	err = comp.Compile(parse("let c = packf(1, 2); let d = packf(3, 4); [c, d]"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	vm.SetRegistry(registry)
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	// The arguments kept by the builtin must not change with the stack.
+	if result := vm.LastPoppedStackElem().Inspect(); result != "[[1, 2], [3, 4]]" {
+		t.Errorf("wrong result. want=%s, got=%s", "[[1, 2], [3, 4]]", result)
+	}
+}
+
+func TestWideConstants(t *testing.T) {
+	constants := make([]object.Object, code.MaxUint16+1)
+	comp := compiler.NewWithState(compiler.NewSymbolTable(), constants)
+
+	err := comp.Compile(parse("1 + 2"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	testExpectedObject(t, 3, vm.LastPoppedStackElem())
+}
+
+func TestWideOperands(t *testing.T) {
+	// A function whose body jumps over more than 64KiB, made after more than 65536
+	// constants.
+	input := "let f = fn(x) { if (x) { " + strings.Repeat("1; ", code.MaxUint16+1) + "2 } }; let g = fn() { 3 }; [f(true), f(false), g()]"
+	program := parse(input)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if result := vm.LastPoppedStackElem().Inspect(); result != "[2, null, 3]" {
+		t.Errorf("wrong result. want=[2, null, 3], got=%s", result)
+	}
+
+	// Globals past the store the VM was created with grow it.
+	symbolTable := compiler.NewSymbolTable()
+	for i := 0; i < GlobalsSize; i++ {
+		symbolTable.Define(fmt.Sprintf("g%d", i))
+	}
+	comp = compiler.NewWithState(symbolTable, []object.Object{})
+	err = comp.Compile(parse("let a = 1; let b = 2; a + b"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm = NewWithGlobalsStore(comp.Bytecode(), make([]object.Object, GlobalsSize))
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 3, vm.LastPoppedStackElem())
+	if len(vm.Globals()) <= GlobalsSize+1 {
+		t.Errorf("globals store not grown. got=%d", len(vm.Globals()))
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   object.Limits
+		expected error
+	}{
+		{
+			"1 + 2 + 3 + 4 + 5",
+			object.Limits{MaxInstructions: 5},
+			&object.InstructionLimitError{Limit: 5},
+		},
+		{
+			"1 + 2 + 3 + 4 + 5",
+			object.Limits{MaxAllocations: 3},
+			&object.AllocationLimitError{Limit: 3},
+		},
+		{
+			"1 + 2 + 3 + 4 + 5",
+			object.Limits{MaxInstructions: 10, MaxAllocations: 4},
+			nil,
+		},
+		{
+			"let f = fn(x) { 1 + f(x) }; f(1);",
+			object.Limits{MaxCallDepth: 100},
+			&object.CallDepthError{Limit: 100},
+		},
+		{
+			"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000);",
+			object.Limits{MaxCallDepth: 10},
+			nil,
+		},
+		// The limits hold in closures, in calls made by builtins and in try
+		// expressions, which can't catch them.
+		{
+			"let f = fn(x) { fn() { 1 + f(x) }() }; f(1);",
+			object.Limits{MaxCallDepth: 50},
+			&object.CallDepthError{Limit: 50},
+		},
+		{
+			"let f = fn(x) { map([x], f) }; f(1);",
+			object.Limits{MaxCallDepth: 50},
+			&object.CallDepthError{Limit: 50},
+		},
+		{
+			"let f = fn(x, y = 1) { 1 + f(x, y: y) }; try { f(1) } catch { 0 };",
+			object.Limits{MaxCallDepth: 50},
+			&object.CallDepthError{Limit: 50},
+		},
+		{
+			"let f = fn(x) { f(x) }; map([1], fn(x) { f(x) });",
+			object.Limits{MaxInstructions: 1000},
+			&object.InstructionLimitError{Limit: 1000},
+		},
+		{
+			"let f = fn(xs) { f(push(xs, 1)) }; try { f([]) } catch { 0 };",
+			object.Limits{MaxAllocations: 100},
+			&object.AllocationLimitError{Limit: 100},
+		},
+		{
+			`let f = fn(x) { [x, {"x": x}, fn() { x }] }; map(range(0, 100), f);`,
+			object.Limits{MaxAllocations: 100},
+			&object.AllocationLimitError{Limit: 100},
+		},
+		// Builtins that make collections count their elements.
+		{
+			"try { range(0, 10000000) } catch { 0 };",
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		{
+			`import "strings" as strings; try { strings.repeat("ab", 1000000) } catch { 0 };`,
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		{
+			"let xs = range(0, 500); zip(xs, xs);",
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		// Ranges are lazy, so only the arrays made of them count.
+		{
+			"let r = 0..<1099511627776; r[1000] + len(r);",
+			object.Limits{MaxAllocations: 1000},
+			nil,
+		},
+		{
+			"try { first(0..<100000) } catch { 0 };",
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		{
+			`import "json" as json; try { json.parse("[` + strings.Repeat("1, ", 2000) + `1]") } catch { 0 };`,
+			object.Limits{MaxAllocations: 1000},
+			&object.AllocationLimitError{Limit: 1000},
+		},
+		{
+			"len(range(0, 500));",
+			object.Limits{MaxAllocations: 1000},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		vm.SetLimits(tt.limits)
+		err = vm.Run()
+		if tt.expected == nil {
+			if err != nil {
+				t.Errorf("unexpected error for %q: %s", tt.input, err)
+			}
+			continue
+		}
+
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.expected) || err.Error() != tt.expected.Error() {
+			t.Errorf("wrong error for %q. want=%T(%v), got=%T(%v)", tt.input, tt.expected, tt.expected, err, err)
+		}
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	// An endless loop: the VM can only leave it through cancellation.
+	bytecode := &compiler.Bytecode{
+		Instructions: concatInstructions([]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpJump, 0),
+		}),
+		Constants: []object.Object{&object.Integer{Value: 1}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	vm := New(bytecode)
+	err := vm.RunContext(ctx)
+
+	var cancelErr *object.CancelError
+	if !errors.As(err, &cancelErr) {
+		t.Fatalf("error is not CancelError. got=%T (%v)", err, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error does not wrap context.DeadlineExceeded. got=%v", err)
+	}
+
+	err = testIntegerObject(1, vm.globals[0])
+	if err != nil {
+		t.Errorf("global has wrong value: %s", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	err = New(bytecode).RunContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled context did not stop the VM. got=%v", err)
+	}
+
+	// An endless loop of tail calls, made by a builtin in a try expression.
+	comp := compiler.New()
+	err = comp.Compile(parse("let f = fn() { f() }; try { map([1], fn(x) { f() }) } catch { 0 };"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = New(comp.Bytecode()).RunContext(ctx)
+	if !errors.As(err, &cancelErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error is not CancelError wrapping context.DeadlineExceeded. got=%T (%v)", err, err)
+	}
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+
+	return out
+}
+
 func runVmTests(t *testing.T, tests []vmTestCase) {
 	t.Helper()
 
@@ -119,6 +858,23 @@ func runVmTests(t *testing.T, tests []vmTestCase) {
 		stackElem := vm.LastPoppedStackElem()
 
 		testExpectedObject(t, tt.expected, stackElem)
+
+		// The optimized bytecode, of the functions as well as the program, has to
+		// produce the same result.
+		comp = compiler.New()
+		comp.SetOptimize(true)
+		err = comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error (optimized): %s", err)
+		}
+
+		vm = New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error (optimized): %s", err)
+		}
+
+		testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
 	}
 }
 
@@ -136,6 +892,61 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 		if err != nil {
 			t.Errorf("testBooleanObject failed: %s", err)
 		}
+	case float64:
+		result, ok := actual.(*object.Float)
+		if !ok {
+			t.Errorf("object is not Float. got=%T (%+v)", actual, actual)
+			return
+		}
+		if result.Value != expected {
+			t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		}
+	case string:
+		result, ok := actual.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", actual, actual)
+			return
+		}
+		if result.Value != expected {
+			t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array. got=%T (%+v)", actual, actual)
+			return
+		}
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong number of elements. want=%d, got=%d", len(expected), len(array.Elements))
+			return
+		}
+		for i, expectedElem := range expected {
+			err := testIntegerObject(int64(expectedElem), array.Elements[i])
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
+	case map[object.HashKey]int64:
+		hash, ok := actual.(*object.Hash)
+		if !ok {
+			t.Errorf("object is not Hash. got=%T (%+v)", actual, actual)
+			return
+		}
+		if len(hash.Pairs) != len(expected) {
+			t.Errorf("hash has wrong number of pairs. want=%d, got=%d", len(expected), len(hash.Pairs))
+			return
+		}
+		for expectedKey, expectedValue := range expected {
+			pair, ok := hash.Pairs[expectedKey]
+			if !ok {
+				t.Errorf("no pair for given key in pairs")
+				continue
+			}
+			err := testIntegerObject(expectedValue, pair.Value)
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
 	case *object.Null:
 		if actual != Null {
 			t.Errorf("object is not Null: %T (%+v)", actual, actual)